@@ -60,7 +60,7 @@ func (app *Config) AdminApproveInventory(w http.ResponseWriter, r *http.Request)
 	defer cancel()
 
 	// get the user rating and count
-	err := app.Repo.AdminApproveInventory(timeoutCtx, id)
+	err := app.Repo.AdminApproveInventory(data.WithChangeActor(timeoutCtx, "", data.ChangeSourceAdmin), id)
 	if err != nil {
 		log.Fatal("error approving inventory: %w", err)
 	}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// inventoryEventCleanupInterval is how often the events of earlier days are deleted
+	inventoryEventCleanupInterval = time.Hour
+	// maxAnalyticsDays is the longest period owner analytics cover
+	maxAnalyticsDays = 365
+	// maxEventInventories is how many listings one event can be recorded on, e.g. a page of search results
+	maxEventInventories = 100
+)
+
+var (
+	botUserAgentPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|preview|facebookexternalhit|headless|lighthouse|curl|wget|python-requests|go-http-client`)
+	uuidPattern         = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// visitorKey hashes a visitor id so the ids themselves are not stored with the events
+func visitorKey(visitorID string) string {
+	sum := sha256.Sum256([]byte(visitorID))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordInventoryEvent counts an event by a visitor on listings. It runs after the response is
+// built, so failures are only logged.
+func (app *Config) recordInventoryEvent(kind, visitorID string, inventoryIDs ...string) {
+	if visitorID == "" || len(inventoryIDs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.RecordInventoryEvents(ctx, kind, visitorKey(visitorID), inventoryIDs); err != nil {
+		log.Printf("failed to record %s events: %v", kind, err)
+	}
+}
+
+// grpcVisitor returns the visitor a gRPC request was made for, from the x-visitor-id and
+// x-user-agent metadata the gateway forwards. It is empty when there is no visitor id or the
+// user agent is a bot, and then nothing is counted.
+func grpcVisitor(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if agents := md.Get("x-user-agent"); len(agents) > 0 && botUserAgentPattern.MatchString(agents[0]) {
+		return ""
+	}
+	if ids := md.Get("x-visitor-id"); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// runInventoryEventCleanup deletes the events of earlier days, once every inventoryEventCleanupInterval
+func (app *Config) runInventoryEventCleanup() {
+	ticker := time.NewTicker(inventoryEventCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		count, err := app.Repo.PruneInventoryEvents(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("failed to clean up inventory events: %v", err)
+		} else if count > 0 {
+			log.Printf("cleaned up %d inventory events", count)
+		}
+
+		<-ticker.C
+	}
+}
+
+type InventoryEventPayload struct {
+	InventoryIds []string `json:"inventory_ids"`
+	Kind         string   `json:"kind"`       // view, impression, save, chat or booking
+	VisitorId    string   `json:"visitor_id"` // the signed in user, or an anonymous id the client keeps
+	UserAgent    string   `json:"user_agent"` // of the visitor's browser; the request's own when empty
+}
+
+// InventoryEvents records events only the client sees, such as a chat started from a listing.
+// Bots are ignored and a visitor counts once a day per listing and kind.
+func (app *Config) InventoryEvents(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryEventPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	switch requestPayload.Kind {
+	case data.InventoryEventView, data.InventoryEventImpression, data.InventoryEventSave,
+		data.InventoryEventChat, data.InventoryEventBooking:
+	default:
+		app.errorJSON(w, errors.New("kind must be view, impression, save, chat or booking"), nil)
+		return
+	}
+	if requestPayload.VisitorId == "" {
+		app.errorJSON(w, errors.New("missing visitor_id"), nil)
+		return
+	}
+	if len(requestPayload.InventoryIds) == 0 || len(requestPayload.InventoryIds) > maxEventInventories {
+		app.errorJSON(w, errors.New("inventory_ids must have between 1 and 100 ids"), nil)
+		return
+	}
+	for _, id := range requestPayload.InventoryIds {
+		if !uuidPattern.MatchString(id) {
+			app.errorJSON(w, errors.New("inventory_ids must be inventory ids"), nil)
+			return
+		}
+	}
+
+	userAgent := requestPayload.UserAgent
+	if userAgent == "" {
+		userAgent = r.UserAgent()
+	}
+	if botUserAgentPattern.MatchString(userAgent) {
+		app.writeJSON(w, http.StatusAccepted, jsonResponse{
+			Error:      false,
+			StatusCode: http.StatusAccepted,
+			Message:    "event ignored",
+		})
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = app.Repo.RecordInventoryEvents(timeoutCtx, requestPayload.Kind, visitorKey(requestPayload.VisitorId), requestPayload.InventoryIds)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "event recorded",
+	})
+}
+
+type funnelStep struct {
+	Step  string  `json:"step"`
+	Count int64   `json:"count"`
+	Rate  float64 `json:"rate"` // share of the step before, from 0 to 1; 1 for the first step
+}
+
+// inventoryFunnel follows visitors from seeing a listing in search to trying to book it.
+// Saves and chats both count as interest.
+func inventoryFunnel(stats data.InventoryStats) []funnelStep {
+	steps := []funnelStep{
+		{Step: "impressions", Count: stats.Impressions, Rate: 1},
+		{Step: "views", Count: stats.Views},
+		{Step: "interested", Count: stats.Saves + stats.Chats},
+		{Step: "bookings", Count: stats.Bookings},
+	}
+	for i := 1; i < len(steps); i++ {
+		if steps[i-1].Count > 0 {
+			steps[i].Rate = float64(steps[i].Count) / float64(steps[i-1].Count)
+		}
+	}
+	return steps
+}
+
+func addInventoryStats(total *data.InventoryStats, stats data.InventoryStats) {
+	total.Views += stats.Views
+	total.Impressions += stats.Impressions
+	total.Saves += stats.Saves
+	total.Chats += stats.Chats
+	total.Bookings += stats.Bookings
+}
+
+// analyticsPeriod returns the first day of the last days days, today included
+func analyticsPeriod(days int) time.Time {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return today.AddDate(0, 0, -(days - 1))
+}
+
+type InventoryAnalyticsPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"` // for inventory-analytics
+	Days        int    `json:"days"`         // default 30, at most 365
+}
+
+// InventoryAnalytics shows an owner a listing's stats for each of the last Days days, with the
+// totals and funnel of the period and the totals of the period before to compare against
+func (app *Config) InventoryAnalytics(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryAnalyticsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.Days <= 0 || requestPayload.Days > maxAnalyticsDays {
+		requestPayload.Days = 30
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	from := analyticsPeriod(requestPayload.Days)
+	stats, err := app.Repo.GetInventoryDailyStats(timeoutCtx, inv.ID, from.AddDate(0, 0, -requestPayload.Days))
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	// days are compared as dates, as the database may be in another time zone
+	start := from.Format("2006-01-02")
+	daily := []data.InventoryDailyStats{}
+	var totals, previous data.InventoryStats
+	for _, day := range stats {
+		if day.Day.Format("2006-01-02") < start {
+			addInventoryStats(&previous, day.InventoryStats)
+			continue
+		}
+		addInventoryStats(&totals, day.InventoryStats)
+		daily = append(daily, day)
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory analytics retrieved successfully",
+		Data: map[string]any{
+			"inventory_id":    inv.ID,
+			"days":            requestPayload.Days,
+			"daily":           daily,
+			"totals":          totals,
+			"previous_totals": previous,
+			"funnel":          inventoryFunnel(totals),
+		},
+	})
+}
+
+type inventoryAnalyticsSummary struct {
+	data.InventoryStatsSummary
+	Funnel []funnelStep `json:"funnel"`
+}
+
+// MyInventoryAnalytics lists each of the owner's listings with its totals and funnel for the last Days days
+func (app *Config) MyInventoryAnalytics(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryAnalyticsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+	if requestPayload.Days <= 0 || requestPayload.Days > maxAnalyticsDays {
+		requestPayload.Days = 30
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stats, err := app.Repo.GetUserInventoryStats(timeoutCtx, requestPayload.UserId, analyticsPeriod(requestPayload.Days))
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]inventoryAnalyticsSummary, 0, len(stats))
+	for _, s := range stats {
+		summaries = append(summaries, inventoryAnalyticsSummary{InventoryStatsSummary: s, Funnel: inventoryFunnel(s.InventoryStats)})
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory analytics retrieved successfully",
+		Data:       summaries,
+	})
+}
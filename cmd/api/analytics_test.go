@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func TestInventoryFunnel(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats data.InventoryStats
+		want  []funnelStep
+	}{
+		{"no activity", data.InventoryStats{}, []funnelStep{
+			{Step: "impressions", Count: 0, Rate: 1},
+			{Step: "views", Count: 0, Rate: 0},
+			{Step: "interested", Count: 0, Rate: 0},
+			{Step: "bookings", Count: 0, Rate: 0},
+		}},
+		{"saves and chats are interest", data.InventoryStats{Impressions: 200, Views: 50, Saves: 10, Chats: 15, Bookings: 5}, []funnelStep{
+			{Step: "impressions", Count: 200, Rate: 1},
+			{Step: "views", Count: 50, Rate: 0.25},
+			{Step: "interested", Count: 25, Rate: 0.5},
+			{Step: "bookings", Count: 5, Rate: 0.2},
+		}},
+		{"views without impressions", data.InventoryStats{Views: 4, Bookings: 2}, []funnelStep{
+			{Step: "impressions", Count: 0, Rate: 1},
+			{Step: "views", Count: 4, Rate: 0},
+			{Step: "interested", Count: 0, Rate: 0},
+			{Step: "bookings", Count: 2, Rate: 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := inventoryFunnel(tt.stats); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInventoryEvents(t *testing.T) {
+	const listing = "1f426485-e2ad-4f1b-839f-5714dea928ff"
+
+	repo := data.NewPostgresTestRepository(nil)
+	app := Config{Repo: repo}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		events int
+	}{
+		{"recorded", `{"kind": "chat", "visitor_id": "visitor", "inventory_ids": ["` + listing + `"]}`, http.StatusAccepted, 1},
+		{"bots are ignored", `{"kind": "chat", "visitor_id": "visitor", "user_agent": "Googlebot/2.1", "inventory_ids": ["` + listing + `"]}`, http.StatusAccepted, 1},
+		{"unknown kind", `{"kind": "share", "visitor_id": "visitor", "inventory_ids": ["` + listing + `"]}`, http.StatusBadRequest, 1},
+		{"no visitor", `{"kind": "view", "inventory_ids": ["` + listing + `"]}`, http.StatusBadRequest, 1},
+		{"not an inventory id", `{"kind": "view", "visitor_id": "visitor", "inventory_ids": ["sofa"]}`, http.StatusBadRequest, 1},
+		{"no listings", `{"kind": "view", "visitor_id": "visitor", "inventory_ids": []}`, http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		status, _ := postJSON(t, app.InventoryEvents, tt.body)
+		if status != tt.status || len(repo.RecordedEvents()) != tt.events {
+			t.Errorf("%s: got status %d and %d events; want %d and %d", tt.name, status, len(repo.RecordedEvents()), tt.status, tt.events)
+		}
+	}
+
+	// the visitor id is stored only as its hash
+	if event := repo.RecordedEvents()[0]; event.Kind != data.InventoryEventChat || event.VisitorKey != visitorKey("visitor") {
+		t.Errorf("got %+v", event)
+	}
+}
+
+func TestInventoryAnalytics_ComparesWithThePeriodBefore(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	app := Config{Repo: repo}
+
+	today := time.Now()
+	repo.DailyStats = []data.InventoryDailyStats{
+		{Day: today.AddDate(0, 0, -40), InventoryStats: data.InventoryStats{Views: 7}},
+		{Day: today.AddDate(0, 0, -29), InventoryStats: data.InventoryStats{Impressions: 10, Views: 2}},
+		{Day: today, InventoryStats: data.InventoryStats{Impressions: 30, Views: 3, Saves: 1}},
+	}
+
+	// the test repository's listing belongs to this user
+	status, response := postJSON(t, app.InventoryAnalytics, `{"user_id": "7a937e9d-1dc2-4e6d-ba38-d1648b05730c", "inventory_id": "1f426485-e2ad-4f1b-839f-5714dea928ff", "days": 30}`)
+	if status != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", status, response.Message)
+	}
+
+	body := response.Data.(map[string]any)
+	totals := body["totals"].(map[string]any)
+	previous := body["previous_totals"].(map[string]any)
+	if totals["impressions"] != 40.0 || totals["views"] != 5.0 || previous["views"] != 7.0 {
+		t.Errorf("got totals %v and previous totals %v", totals, previous)
+	}
+	if daily := body["daily"].([]any); len(daily) != 2 {
+		t.Errorf("got %d days; want 2", len(daily))
+	}
+
+	// someone else's listing is not theirs to see
+	if status, _ := postJSON(t, app.InventoryAnalytics, `{"user_id": "someone", "inventory_id": "1f426485-e2ad-4f1b-839f-5714dea928ff"}`); status != http.StatusForbidden {
+		t.Errorf("got status %d; want %d", status, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/obynonwane/inventory-service/data"
+)
+
+var (
+	attributeNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,99}$`)
+	attributeFilterPattern = regexp.MustCompile(`^attr\.([a-z][a-z0-9_]*)(>=|<=|!=|=|>|<)(.+)$`)
+)
+
+// validateInventoryAttributes checks a listing's metadata against the attributes of its
+// subcategory and returns the defined ones, typed, as JSON for the attributes column
+func (app *Config) validateInventoryAttributes(ctx context.Context, subcategoryID, metadata string) (string, error) {
+	definitions, err := app.Repo.GetSubcategoryAttributes(ctx, subcategoryID)
+	if err != nil {
+		return "", err
+	}
+
+	attributes, err := validateMetadata(definitions, metadata)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode attributes: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// validateMetadata parses metadata, a json object, and checks every defined attribute in it.
+// Keys without a definition stay in the metadata but are not returned.
+func validateMetadata(definitions []data.SubcategoryAttribute, metadata string) (map[string]any, error) {
+	values := map[string]any{}
+	if strings.TrimSpace(metadata) != "" {
+		if err := json.Unmarshal([]byte(metadata), &values); err != nil {
+			return nil, errors.New("metadata must be a json object")
+		}
+	}
+
+	attributes := make(map[string]any)
+	for _, def := range definitions {
+		raw, ok := values[def.Name]
+		if !ok || raw == nil || raw == "" {
+			if def.Required {
+				return nil, fmt.Errorf("%s is required", def.Label)
+			}
+			continue
+		}
+
+		value, err := attributeValue(def, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", def.Label, err)
+		}
+		attributes[def.Name] = value
+	}
+
+	return attributes, nil
+}
+
+// attributeValue converts raw to the attribute's type. Numbers and booleans may also be sent as strings.
+func attributeValue(def data.SubcategoryAttribute, raw any) (any, error) {
+	switch def.Type {
+	case data.AttributeNumber:
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, errors.New("must be a number")
+			}
+			return n, nil
+		}
+		return nil, errors.New("must be a number")
+
+	case data.AttributeBoolean:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes":
+				return true, nil
+			case "false", "no":
+				return false, nil
+			}
+		}
+		return nil, errors.New("must be yes or no")
+
+	case data.AttributeEnum, data.AttributeText:
+		v, ok := raw.(string)
+		if !ok {
+			return nil, errors.New("must be text")
+		}
+		v = strings.TrimSpace(v)
+		if def.Type == data.AttributeEnum || len(def.AllowedValues) > 0 {
+			for _, allowed := range def.AllowedValues {
+				if strings.EqualFold(allowed, v) {
+					return allowed, nil
+				}
+			}
+			return nil, fmt.Errorf("must be one of %s", strings.Join(def.AllowedValues, ", "))
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("has unknown type %q", def.Type)
+}
+
+// parseAttributeFilters takes attr.name>=value terms out of a search text and returns them with
+// the rest of the text. Values are read the way attributeValue reads them, so attr.bedrooms=3
+// matches 3.0 and attr.furnished=yes matches true. Range comparisons need a number.
+func parseAttributeFilters(text string) ([]data.AttributeFilter, string, error) {
+	var filters []data.AttributeFilter
+	var rest []string
+	for _, term := range strings.Fields(text) {
+		m := attributeFilterPattern.FindStringSubmatch(term)
+		if m == nil {
+			rest = append(rest, term)
+			continue
+		}
+
+		filter := data.AttributeFilter{Name: m[1], Op: m[2], Value: m[3]}
+		if n, err := attributeValue(data.SubcategoryAttribute{Type: data.AttributeNumber}, filter.Value); err == nil {
+			if n := n.(float64); !math.IsInf(n, 0) && !math.IsNaN(n) {
+				filter.Number = &n
+			}
+		}
+		if b, err := attributeValue(data.SubcategoryAttribute{Type: data.AttributeBoolean}, filter.Value); err == nil {
+			b := b.(bool)
+			filter.Bool = &b
+		}
+		if filter.Op != "=" && filter.Op != "!=" && filter.Number == nil {
+			return nil, "", fmt.Errorf("attr.%s%s needs a number", filter.Name, filter.Op)
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, strings.Join(rest, " "), nil
+}
+
+// GetSubcategoryAttributes lists the attributes listings in a subcategory can have
+func (app *Config) GetSubcategoryAttributes(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		app.errorJSON(w, errors.New("id parameter is missing"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	attributes, err := app.Repo.GetSubcategoryAttributes(timeoutCtx, id)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "attributes retrieved successfully",
+		Data:       attributes,
+	})
+}
+
+type SubcategoryAttributePayload struct {
+	SubcategoryId string   `json:"subcategory_id"`
+	Name          string   `json:"name"`
+	Label         string   `json:"label"`
+	Type          string   `json:"type"`
+	Unit          *string  `json:"unit"`
+	AllowedValues []string `json:"allowed_values"`
+	Required      bool     `json:"required"`
+}
+
+// AdminSaveSubcategoryAttribute adds an attribute to a subcategory or replaces the one with the same name
+func (app *Config) AdminSaveSubcategoryAttribute(w http.ResponseWriter, r *http.Request) {
+	var requestPayload SubcategoryAttributePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.SubcategoryId == "" {
+		app.errorJSON(w, errors.New("missing subcategory_id"), nil)
+		return
+	}
+	if !attributeNamePattern.MatchString(requestPayload.Name) {
+		app.errorJSON(w, errors.New("name must be lowercase letters, digits and underscores, starting with a letter"), nil)
+		return
+	}
+	if strings.TrimSpace(requestPayload.Label) == "" {
+		requestPayload.Label = requestPayload.Name
+	}
+	switch requestPayload.Type {
+	case data.AttributeText, data.AttributeNumber, data.AttributeBoolean:
+	case data.AttributeEnum:
+		if len(requestPayload.AllowedValues) == 0 {
+			app.errorJSON(w, errors.New("an enum attribute needs allowed_values"), nil)
+			return
+		}
+	default:
+		app.errorJSON(w, errors.New("type must be text, number, boolean or enum"), nil)
+		return
+	}
+	if requestPayload.Type != data.AttributeEnum && requestPayload.Type != data.AttributeText && len(requestPayload.AllowedValues) > 0 {
+		app.errorJSON(w, errors.New("allowed_values only applies to text and enum attributes"), nil)
+		return
+	}
+	if requestPayload.AllowedValues == nil {
+		requestPayload.AllowedValues = []string{}
+	}
+	slices.Sort(requestPayload.AllowedValues)
+	requestPayload.AllowedValues = slices.Compact(requestPayload.AllowedValues)
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := app.Repo.GetSubcategoryByID(timeoutCtx, requestPayload.SubcategoryId); err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+
+	saved, err := app.Repo.SaveSubcategoryAttribute(timeoutCtx, &data.SubcategoryAttribute{
+		SubcategoryID: requestPayload.SubcategoryId,
+		Name:          requestPayload.Name,
+		Label:         strings.TrimSpace(requestPayload.Label),
+		Type:          requestPayload.Type,
+		Unit:          requestPayload.Unit,
+		AllowedValues: requestPayload.AllowedValues,
+		Required:      requestPayload.Required,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "attribute saved successfully",
+		Data:       saved,
+	})
+}
+
+// AdminDeleteSubcategoryAttribute removes an attribute from a subcategory
+func (app *Config) AdminDeleteSubcategoryAttribute(w http.ResponseWriter, r *http.Request) {
+	var requestPayload SubcategoryAttributePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.DeleteSubcategoryAttribute(timeoutCtx, requestPayload.SubcategoryId, requestPayload.Name); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "attribute deleted successfully",
+	})
+}
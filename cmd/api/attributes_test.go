@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+var testAttributes = []data.SubcategoryAttribute{
+	{Name: "bedrooms", Label: "Bedrooms", Type: data.AttributeNumber, Required: true},
+	{Name: "furnished", Label: "Furnished", Type: data.AttributeBoolean},
+	{Name: "fuel", Label: "Fuel", Type: data.AttributeEnum, AllowedValues: []string{"Petrol", "Diesel"}},
+	{Name: "colour", Label: "Colour", Type: data.AttributeText},
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		want     map[string]any
+		err      string
+	}{
+		{"typed values", `{"bedrooms": 3, "furnished": true, "fuel": "Diesel", "colour": " red "}`,
+			map[string]any{"bedrooms": 3.0, "furnished": true, "fuel": "Diesel", "colour": "red"}, ""},
+		{"values sent as strings", `{"bedrooms": "2.5", "furnished": "no", "fuel": "petrol"}`,
+			map[string]any{"bedrooms": 2.5, "furnished": false, "fuel": "Petrol"}, ""},
+		{"undefined keys are left out", `{"bedrooms": 1, "pool": true}`, map[string]any{"bedrooms": 1.0}, ""},
+		{"required missing", `{"furnished": true}`, nil, "Bedrooms is required"},
+		{"required empty", `{"bedrooms": ""}`, nil, "Bedrooms is required"},
+		{"required with no metadata", ``, nil, "Bedrooms is required"},
+		{"not a number", `{"bedrooms": "many"}`, nil, "Bedrooms must be a number"},
+		{"not a boolean", `{"bedrooms": 1, "furnished": "maybe"}`, nil, "Furnished must be yes or no"},
+		{"not an allowed value", `{"bedrooms": 1, "fuel": "Electric"}`, nil, "Fuel must be one of Petrol, Diesel"},
+		{"text that is not text", `{"bedrooms": 1, "colour": 4}`, nil, "Colour must be text"},
+		{"not an object", `[1, 2]`, nil, "metadata must be a json object"},
+	}
+
+	for _, tt := range tests {
+		got, err := validateMetadata(testAttributes, tt.metadata)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: got error %v; want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// a listing is saved with only its subcategory's attributes, typed, whatever else its metadata holds
+func TestValidateInventoryAttributes(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	repo.Attributes = testAttributes
+	app := Config{Repo: repo}
+
+	got, err := app.validateInventoryAttributes(context.Background(), "subcategory", `{"bedrooms": "3", "fuel": "diesel", "note": "x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"bedrooms":3,"fuel":"Diesel"}`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	if _, err := app.validateInventoryAttributes(context.Background(), "subcategory", `{"fuel": "Petrol"}`); err == nil {
+		t.Error("expected a listing without a required attribute to be rejected")
+	}
+
+	// a subcategory without attributes takes any metadata
+	repo.Attributes = nil
+	if got, err := app.validateInventoryAttributes(context.Background(), "subcategory", `{"note": "x"}`); err != nil || got != "{}" {
+		t.Errorf("got %s, %v; want {}", got, err)
+	}
+}
+
+func TestParseAttributeFilters(t *testing.T) {
+	three, yes := 3.0, true
+
+	tests := []struct {
+		name    string
+		text    string
+		filters []data.AttributeFilter
+		rest    string
+		wantErr bool
+	}{
+		{"no filters", "red sofa", nil, "red sofa", false},
+		{"numbers are typed", "flat attr.bedrooms>=3 lagos", []data.AttributeFilter{{Name: "bedrooms", Op: ">=", Value: "3", Number: &three}}, "flat lagos", false},
+		{"so are yes and no", "attr.furnished=yes", []data.AttributeFilter{{Name: "furnished", Op: "=", Value: "yes", Bool: &yes}}, "", false},
+		{"text stays text", "attr.fuel!=Diesel car", []data.AttributeFilter{{Name: "fuel", Op: "!=", Value: "Diesel"}}, "car", false},
+		{"range needs a number", "attr.fuel>diesel", nil, "", true},
+		{"infinity is not a number", "attr.bedrooms<Inf", nil, "", true},
+		{"not a filter", "attr.Bad=1", nil, "attr.Bad=1", false},
+	}
+
+	for _, tt := range tests {
+		filters, rest, err := parseAttributeFilters(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if !tt.wantErr && (!reflect.DeepEqual(filters, tt.filters) || rest != tt.rest) {
+			t.Errorf("%s: got %+v, %q; want %+v, %q", tt.name, filters, rest, tt.filters, tt.rest)
+		}
+	}
+}
+
+// searchServer is an InventoryServer over a fresh test repository, for checking what a search asks the repository for
+func searchServer() (*InventoryServer, *data.PostgresTestRepository) {
+	repo := data.NewPostgresTestRepository(nil)
+	return &InventoryServer{Models: repo, App: &Config{Repo: repo}}, repo
+}
+
+func TestSearchInventory_AttributeFilters(t *testing.T) {
+	server, repo := searchServer()
+
+	_, err := server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: "flat attr.bedrooms>=3 lagos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Searches) != 1 {
+		t.Fatalf("got %d searches", len(repo.Searches))
+	}
+	search := repo.Searches[0]
+	if search.Text != "flat lagos" || len(search.Attributes) != 1 || search.Attributes[0].Name != "bedrooms" {
+		t.Errorf("got text %q and attributes %+v", search.Text, search.Attributes)
+	}
+
+	// a bad filter is the caller's mistake and never reaches the repository
+	_, err = server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: "attr.fuel>diesel"})
+	if status.Code(err) != codes.InvalidArgument || len(repo.Searches) != 1 {
+		t.Errorf("got %v after %d searches; want InvalidArgument", err, len(repo.Searches))
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 type CreateBookingPayload struct {
 	InventoryId       string  `json:"inventory_id" binding:"required"`
+	VariantId         string  `json:"variant_id"` // required when the inventory has variants
 	RenterId          string  `json:"renter_id"`
 	OwnerId           string  `json:"owner_id"`
 	RentalType        string  `json:"rental_type" binding:"required"`     // e.g., "hourly", "daily"
@@ -56,25 +57,36 @@ func (app *Config) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// every attempt counts in the owner's analytics, whether or not it goes through
+	go app.recordInventoryEvent(data.InventoryEventBooking, requestPayload.RenterId, inv.ID)
+
 	// check if item is for sale
 	if inv.ProductPurpose != "rental" {
 		app.errorJSON(w, errors.New(fmt.Sprintf("item is only for rental not for sale")), nil, http.StatusBadRequest)
 		return
 	}
+
+	// price and stock come from the chosen variant when the inventory has variants
+	terms, err := resolveVariantTerms(inv, requestPayload.VariantId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+
 	// check check the offer price is not less than stipulated price
-	if requestPayload.OfferPricePerUnit < inv.MinimumPrice {
-		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be less than minimum price: %v", inv.MinimumPrice)), nil, http.StatusBadRequest)
+	if requestPayload.OfferPricePerUnit < terms.MinimumPrice {
+		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be less than minimum price: %v", terms.MinimumPrice)), nil, http.StatusBadRequest)
 		return
 	}
 
 	// check the offer price is not more than stipulated price
-	if requestPayload.OfferPricePerUnit > inv.OfferPrice {
-		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be more than stipulated price: %v", inv.OfferPrice)), nil, http.StatusBadRequest)
+	if requestPayload.OfferPricePerUnit > terms.OfferPrice {
+		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be more than stipulated price: %v", terms.OfferPrice)), nil, http.StatusBadRequest)
 		return
 	}
-	// check the quantity needed is met
-	if requestPayload.Quantity > inv.Quantity {
-		app.errorJSON(w, errors.New(fmt.Sprintf("the stipulated quantity is not available, only: %v is available", inv.Quantity)), nil, http.StatusBadRequest)
+	// check the quantity needed is met; a bundle is checked against its components for the dates
+	if len(inv.Components) == 0 && requestPayload.Quantity > terms.Quantity {
+		app.errorJSON(w, errors.New(fmt.Sprintf("the stipulated quantity is not available, only: %v is available", terms.Quantity)), nil, http.StatusBadRequest)
 		return
 	}
 	// check that the inventory is for the rental type
@@ -122,10 +134,17 @@ func (app *Config) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	// calculate the total amount (quantity * offer_price_per_unit)
 	totalPrice := float64(requestPayload.Quantity) * requestPayload.OfferPricePerUnit * float64(requestPayload.RentalDuration)
 
-	bookings, err := app.Repo.CreateBooking(timeoutCtx, &data.CreateBookingPayload{
+	// booking a bundle holds every component's stock as well
+	createBooking := app.Repo.CreateBooking
+	if len(inv.Components) > 0 {
+		createBooking = app.Repo.CreateBundleBooking
+	}
+
+	bookings, err := createBooking(timeoutCtx, &data.CreateBookingPayload{
 		OwnerId:           inv.UserId,
 		RenterId:          requestPayload.RenterId,
 		InventoryId:       inv.ID,
+		VariantId:         terms.VariantId,
 		RentalType:        requestPayload.RentalType,
 		RentalDuration:    int32(requestPayload.RentalDuration),
 		SecurityDeposit:   requestPayload.SecurityDeposit,
@@ -138,10 +157,16 @@ func (app *Config) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		StartTime:         requestPayload.StartTime,
 	})
 	if err != nil {
-		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		app.errorJSON(w, err, nil, bundleErrorStatus(err))
 		return
 	}
 
+	// the booking holds stock until it ends
+	go app.checkInventoryStock(inv.ID)
+	for _, c := range inv.Components {
+		go app.checkInventoryStock(c.ComponentID)
+	}
+
 	// send sms & email notification to both owner and renter
 
 	payload := jsonResponse{
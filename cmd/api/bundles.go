@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// maxBundleComponents is how many listings one bundle can be made up of
+const maxBundleComponents = 50
+
+// bundleErrors are returned by the repository for requests that can not be met
+var bundleErrors = []error{data.ErrInventoryNotFound, data.ErrBundleNotAllowed, data.ErrBundleComponentInvalid, data.ErrBundleUnavailable, data.ErrComponentUnavailable}
+
+// bundleErrorStatus returns the status to respond to a bundle error with
+func bundleErrorStatus(err error) int {
+	for _, target := range bundleErrors {
+		if errors.Is(err, target) {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// sendBundleHeaders sends the components of a bundle with its details. The proto has no field for
+// them, so they travel as response metadata; nothing is sent for other listings.
+func sendBundleHeaders(ctx context.Context, inv *data.Inventory) {
+	if len(inv.Components) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(inv.Components)
+	if err != nil {
+		log.Printf("failed to encode components of bundle %s: %v", inv.ID, err)
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-bundle-components-bin", string(encoded))); err != nil {
+		log.Printf("failed to send bundle components header: %v", err)
+	}
+}
+
+type BundleComponentPayload struct {
+	InventoryId string `json:"inventory_id"`
+	Quantity    int32  `json:"quantity"` // how many of the listing one bundle takes
+}
+
+type InventoryBundlePayload struct {
+	UserId      string                   `json:"user_id"`
+	InventoryId string                   `json:"inventory_id"`
+	Components  []BundleComponentPayload `json:"components"` // for set-inventory-bundle; empty makes it an ordinary listing again
+	StartDate   string                   `json:"start_date"` // for bundle-availability, e.g. "2025-06-15"; default today
+	EndDate     string                   `json:"end_date"`   // default the start date
+}
+
+// SetInventoryBundle makes one of the owner's rental listings a bundle of their other listings,
+// booked as one at the bundle's own price
+func (app *Config) SetInventoryBundle(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryBundlePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if len(requestPayload.Components) > maxBundleComponents {
+		app.errorJSON(w, fmt.Errorf("a bundle can have at most %d components", maxBundleComponents), nil)
+		return
+	}
+	components := make([]data.BundleComponent, 0, len(requestPayload.Components))
+	seen := make(map[string]bool, len(requestPayload.Components))
+	for _, c := range requestPayload.Components {
+		if !uuidPattern.MatchString(c.InventoryId) {
+			app.errorJSON(w, errors.New("every component needs an inventory_id"), nil)
+			return
+		}
+		if c.Quantity <= 0 {
+			app.errorJSON(w, errors.New("every component needs a quantity of at least 1"), nil)
+			return
+		}
+		if seen[c.InventoryId] {
+			app.errorJSON(w, fmt.Errorf("inventory %s is in the bundle more than once", c.InventoryId), nil)
+			return
+		}
+		seen[c.InventoryId] = true
+		components = append(components, data.BundleComponent{ComponentID: c.InventoryId, Quantity: c.Quantity})
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	saved, err := app.Repo.SetBundleComponents(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), requestPayload.UserId, inv.ID, components)
+	if err != nil {
+		app.errorJSON(w, err, nil, bundleErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "bundle updated successfully",
+		Data:       saved,
+	})
+}
+
+// BundleAvailability shows how many of a bundle can be booked for some dates, and how much of
+// each component is free then. It is public like the listing itself.
+func (app *Config) BundleAvailability(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryBundlePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	layout := "2006-01-02"
+	start := time.Now().Truncate(24 * time.Hour)
+	if requestPayload.StartDate != "" {
+		if start, err = time.Parse(layout, requestPayload.StartDate); err != nil {
+			app.errorJSON(w, errors.New("invalid start date format, use YYYY-MM-DD"), nil)
+			return
+		}
+	}
+	end := start
+	if requestPayload.EndDate != "" {
+		if end, err = time.Parse(layout, requestPayload.EndDate); err != nil {
+			app.errorJSON(w, errors.New("invalid end date format, use YYYY-MM-DD"), nil)
+			return
+		}
+	}
+	if end.Before(start) {
+		app.errorJSON(w, errors.New("end date can not be before start date"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	availability, err := app.Repo.GetBundleAvailability(timeoutCtx, requestPayload.InventoryId, start, end)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	if len(availability.Components) == 0 {
+		app.errorJSON(w, errors.New("this inventory is not a bundle"), nil)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "bundle availability retrieved successfully",
+		Data:       availability,
+	})
+}
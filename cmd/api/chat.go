@@ -1,18 +1,14 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/cloudinary/cloudinary-go"
-	"github.com/cloudinary/cloudinary-go/api/uploader"
 	"github.com/obynonwane/inventory-service/data"
 )
 
@@ -95,22 +91,9 @@ func (app *Config) SubmitChat(w http.ResponseWriter, r *http.Request) {
 	case "image", "file", "video":
 		log.Printf("Processing as %s", msgType)
 
-		cld, err := cloudinary.NewFromParams(
-			os.Getenv("CLOUDINARY_CLOUD_NAME"),
-			os.Getenv("CLOUDINARY_API_KEY"),
-			os.Getenv("CLOUDINARY_API_SECRET"),
-		)
-		if err != nil {
-			log.Println("Error initializing Cloudinary:", err)
-			app.errorJSON(w, err, nil)
-			return
-		}
-
 		uploadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer cancel()
 
-		uniqueFilename := app.generateUniqueFilename()
-
 		// Strip base64 prefix
 		parts := strings.SplitN(requestPayload.Content, ",", 2)
 		if len(parts) != 2 {
@@ -127,26 +110,15 @@ func (app *Config) SubmitChat(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Choose Cloudinary resource type
-		resourceType := map[string]string{
-			"image": "image",
-			"file":  "raw",
-			"video": "video",
-		}[msgType]
-
-		uploadResult, err := cld.Upload.Upload(uploadCtx, bytes.NewReader(decoded), uploader.UploadParams{
-			Folder:       "rentalsolution/chats",
-			PublicID:     uniqueFilename,
-			ResourceType: resourceType,
-		})
+		contentURL, err := app.uploadMedia(uploadCtx, "rentalsolution/chats", mimeType, decoded)
 		if err != nil {
 			log.Printf("%s upload failed: %v", msgType, err)
 			app.errorJSON(w, err, nil)
 			return
 		}
 
-		log.Printf("Uploaded to Cloudinary: %s", uploadResult.SecureURL)
-		requestPayload.Content = uploadResult.SecureURL
+		log.Printf("Uploaded chat %s: %s", msgType, contentURL)
+		requestPayload.Content = contentURL
 
 	case "text":
 		log.Println("Processing as plain text")
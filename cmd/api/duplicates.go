@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
+	"github.com/obynonwane/inventory-service/utility"
+)
+
+// How a listing that looks like another live listing of the same seller is handled
+const (
+	DuplicateModeOff   = "off"   // not checked
+	DuplicateModeFlag  = "flag"  // saved, but held for admin review
+	DuplicateModeBlock = "block" // rejected
+)
+
+var errDuplicateListing = errors.New("this looks like a duplicate of one of your other listings")
+
+// DuplicateConfig tunes duplicate listing detection. A listing matches another when the trigram
+// similarity of their names and descriptions reaches TextThreshold, or when any pair of their
+// images is within ImageDistance bits of each other.
+type DuplicateConfig struct {
+	Mode          string
+	TextThreshold float64
+	ImageDistance int
+}
+
+// duplicateConfigFromEnv reads DUPLICATE_LISTING_MODE (off, flag or block; default flag),
+// DUPLICATE_TEXT_THRESHOLD (default 0.8) and DUPLICATE_IMAGE_DISTANCE (default 6)
+func duplicateConfigFromEnv() (DuplicateConfig, error) {
+	cfg := DuplicateConfig{Mode: DuplicateModeFlag, TextThreshold: 0.8, ImageDistance: 6}
+
+	switch mode := strings.ToLower(os.Getenv("DUPLICATE_LISTING_MODE")); mode {
+	case "":
+	case DuplicateModeOff, DuplicateModeFlag, DuplicateModeBlock:
+		cfg.Mode = mode
+	default:
+		return cfg, fmt.Errorf("unknown DUPLICATE_LISTING_MODE %q", mode)
+	}
+
+	if v := os.Getenv("DUPLICATE_TEXT_THRESHOLD"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return cfg, fmt.Errorf("DUPLICATE_TEXT_THRESHOLD must be between 0 and 1, got %q", v)
+		}
+		cfg.TextThreshold = threshold
+	}
+
+	if v := os.Getenv("DUPLICATE_IMAGE_DISTANCE"); v != "" {
+		distance, err := strconv.Atoi(v)
+		if err != nil || distance < 0 || distance > 64 {
+			return cfg, fmt.Errorf("DUPLICATE_IMAGE_DISTANCE must be between 0 and 64, got %q", v)
+		}
+		cfg.ImageDistance = distance
+	}
+
+	return cfg, nil
+}
+
+// findDuplicates compares a listing with the seller's other live listings. excludeID is the
+// listing itself when it already exists. An unset mode is treated as off.
+func (app *Config) findDuplicates(ctx context.Context, userID, excludeID, name, description string, hashes []int64) ([]data.DuplicateMatch, error) {
+	cfg := app.Duplicates
+	if cfg.Mode == "" || cfg.Mode == DuplicateModeOff {
+		return nil, nil
+	}
+
+	candidates, err := app.Repo.GetDuplicateCandidates(ctx, userID, excludeID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := name + " " + description
+	var matches []data.DuplicateMatch
+	for _, c := range candidates {
+		similarity := utility.TrigramSimilarity(text, c.Name+" "+c.Description)
+
+		var distance *int32
+		for _, a := range hashes {
+			for _, b := range c.ImageHashes {
+				d := int32(media.HashDistance(uint64(a), uint64(b)))
+				if distance == nil || d < *distance {
+					distance = &d
+				}
+			}
+		}
+
+		if similarity >= cfg.TextThreshold || (distance != nil && int(*distance) <= cfg.ImageDistance) {
+			matches = append(matches, data.DuplicateMatch{
+				InventoryID:    c.ID,
+				Name:           c.Name,
+				TextSimilarity: math.Round(similarity*100) / 100,
+				ImageDistance:  distance,
+			})
+		}
+	}
+
+	return matches, nil
+}
+
+// checkDuplicates runs findDuplicates and returns errDuplicateListing when matches must block the listing.
+// Otherwise the matches are returned for flagDuplicates once the listing has been saved.
+func (app *Config) checkDuplicates(ctx context.Context, userID, excludeID, name, description string, hashes []int64) ([]data.DuplicateMatch, error) {
+	matches, err := app.findDuplicates(ctx, userID, excludeID, name, description, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("error checking for duplicate listings: %w", err)
+	}
+
+	if len(matches) > 0 && app.Duplicates.Mode == DuplicateModeBlock {
+		return matches, fmt.Errorf("%w: %s", errDuplicateListing, matches[0].Name)
+	}
+
+	return matches, nil
+}
+
+// flagDuplicates holds a saved listing for admin review when it matched others. Failing to flag
+// must not undo the save, so the error is only logged. The change is recorded as made by the system.
+func (app *Config) flagDuplicates(ctx context.Context, inventoryID string, matches []data.DuplicateMatch) {
+	if len(matches) == 0 {
+		return
+	}
+
+	if err := app.Repo.FlagInventoryDuplicates(data.WithChangeActor(ctx, "", data.ChangeSourceSystem), inventoryID, matches); err != nil {
+		log.Printf("failed to flag inventory %s as a duplicate: %v", inventoryID, err)
+	}
+}
+
+// hashImages returns the perceptual hashes of raw uploads, skipping any that can not be decoded
+func hashImages(images [][]byte) []int64 {
+	var hashes []int64
+	for _, content := range images {
+		hash, err := media.HashImage(content)
+		if err != nil {
+			continue
+		}
+		hashes = append(hashes, int64(hash))
+	}
+	return hashes
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// ExpiryConfig controls how long listings stay live and when their owners are reminded
+type ExpiryConfig struct {
+	LifetimeDays   int // for plans that do not set their own; 0 means listings never expire
+	ReminderBefore time.Duration
+	Interval       time.Duration
+}
+
+// expiryConfigFromEnv reads LISTING_LIFETIME_DAYS (default 90), LISTING_EXPIRY_REMINDER_DAYS
+// (default 7) and LISTING_EXPIRY_CHECK_INTERVAL, a duration such as "30m" (default 1h)
+func expiryConfigFromEnv() (ExpiryConfig, error) {
+	cfg := ExpiryConfig{LifetimeDays: 90, ReminderBefore: 7 * 24 * time.Hour, Interval: time.Hour}
+
+	if v := os.Getenv("LISTING_LIFETIME_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return cfg, fmt.Errorf("LISTING_LIFETIME_DAYS must be a number of days, got %q", v)
+		}
+		cfg.LifetimeDays = days
+	}
+
+	if v := os.Getenv("LISTING_EXPIRY_REMINDER_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 {
+			return cfg, fmt.Errorf("LISTING_EXPIRY_REMINDER_DAYS must be a positive number of days, got %q", v)
+		}
+		cfg.ReminderBefore = time.Duration(days) * 24 * time.Hour
+	}
+
+	if v := os.Getenv("LISTING_EXPIRY_CHECK_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < time.Minute {
+			return cfg, fmt.Errorf("LISTING_EXPIRY_CHECK_INTERVAL must be a duration of at least 1m, got %q", v)
+		}
+		cfg.Interval = interval
+	}
+
+	return cfg, nil
+}
+
+// listingLifetime returns how long the user's listings stay live, or 0 when they never expire
+func (app *Config) listingLifetime(ctx context.Context, userID string) (time.Duration, error) {
+	days, err := app.Repo.GetListingLifetimeDays(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	lifetime := app.Expiry.LifetimeDays
+	if days != nil {
+		lifetime = int(*days)
+	}
+	return time.Duration(lifetime) * 24 * time.Hour, nil
+}
+
+// listingExpiry returns when a listing of the user that goes live at start expires, or nil for never
+func (app *Config) listingExpiry(ctx context.Context, userID string, start time.Time) (*time.Time, error) {
+	lifetime, err := app.listingLifetime(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if lifetime == 0 {
+		return nil, nil
+	}
+
+	expiresAt := start.Add(lifetime)
+	return &expiresAt, nil
+}
+
+// runExpiryReminders reminds owners of listings that are about to expire, once every Interval.
+// Listings from before expiry dates existed get theirs first.
+func (app *Config) runExpiryReminders() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	count, err := app.Repo.BackfillListingExpiry(ctx, app.Expiry.LifetimeDays)
+	cancel()
+	if err != nil {
+		log.Printf("failed to set expiry dates of existing listings: %v", err)
+	} else if count > 0 {
+		log.Printf("set the expiry date of %d existing listings", count)
+	}
+
+	ticker := time.NewTicker(app.Expiry.Interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		count, err := app.Repo.QueueExpiryReminders(ctx, app.Expiry.ReminderBefore)
+		cancel()
+		if err != nil {
+			log.Printf("failed to send listing expiry reminders: %v", err)
+		} else if count > 0 {
+			log.Printf("sent %d listing expiry reminders", count)
+		}
+
+		<-ticker.C
+	}
+}
+
+type ScheduleInventoryPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+	PublishAt   string `json:"publish_at"` // RFC 3339; empty publishes now
+	ExpiresAt   string `json:"expires_at"` // RFC 3339; empty uses the plan's listing lifetime
+}
+
+// ScheduleInventory sets when a listing goes live and when it expires. The expiry date can not be
+// later than the plan's listing lifetime allows, counted from the publish time.
+func (app *Config) ScheduleInventory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload ScheduleInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	now := time.Now()
+	start := now
+	var publishAt *time.Time
+	if requestPayload.PublishAt != "" {
+		t, err := time.Parse(time.RFC3339, requestPayload.PublishAt)
+		if err != nil {
+			app.errorJSON(w, errors.New("publish_at must be a time like 2025-01-31T09:00:00Z"), nil)
+			return
+		}
+		if !t.After(now) {
+			app.errorJSON(w, errors.New("publish_at must be in the future"), nil)
+			return
+		}
+		publishAt, start = &t, t
+	}
+
+	lifetime, err := app.listingLifetime(timeoutCtx, inv.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	var expiresAt *time.Time
+	if lifetime > 0 {
+		latest := start.Add(lifetime)
+		expiresAt = &latest
+	}
+	if requestPayload.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, requestPayload.ExpiresAt)
+		if err != nil {
+			app.errorJSON(w, errors.New("expires_at must be a time like 2025-01-31T09:00:00Z"), nil)
+			return
+		}
+		if !t.After(start) {
+			app.errorJSON(w, errors.New("expires_at must be after the listing goes live"), nil)
+			return
+		}
+		if expiresAt != nil && t.After(*expiresAt) {
+			app.errorJSON(w, fmt.Errorf("your plan allows listings to stay live until %s at the latest", expiresAt.UTC().Format(time.RFC3339)), nil)
+			return
+		}
+		expiresAt = &t
+	}
+
+	actorCtx := data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP)
+	if err := app.Repo.ScheduleInventory(actorCtx, inv.UserId, inv.ID, publishAt, expiresAt); err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory scheduled successfully",
+		Data:       map[string]*time.Time{"publish_at": publishAt, "expires_at": expiresAt},
+	})
+}
+
+type RenewInventoryPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+}
+
+// RenewInventory gives a listing, expired or not, a full listing lifetime from now or from its
+// publish time when that is still ahead
+func (app *Config) RenewInventory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload RenewInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	publishAt, _, err := app.Repo.GetInventorySchedule(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	start := time.Now()
+	if publishAt != nil && publishAt.After(start) {
+		start = *publishAt
+	}
+
+	expiresAt, err := app.listingExpiry(timeoutCtx, inv.UserId, start)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	actorCtx := data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP)
+	if err := app.Repo.RenewInventory(actorCtx, inv.UserId, inv.ID, expiresAt); err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory renewed successfully",
+		Data:       map[string]*time.Time{"expires_at": expiresAt},
+	})
+}
+
+type NotificationsPayload struct {
+	UserId     string   `json:"user_id"`
+	UnreadOnly bool     `json:"unread_only"`
+	Limit      int32    `json:"limit"`
+	Ids        []string `json:"ids"` // for mark-notifications-read; empty marks all
+}
+
+// Notifications lists the user's notifications, newest first
+func (app *Config) Notifications(w http.ResponseWriter, r *http.Request) {
+	var requestPayload NotificationsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+	if requestPayload.Limit <= 0 || requestPayload.Limit > 100 {
+		requestPayload.Limit = 50
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	notifications, err := app.Repo.GetNotifications(timeoutCtx, requestPayload.UserId, requestPayload.UnreadOnly, requestPayload.Limit)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "notifications retrieved successfully",
+		Data:       notifications,
+	})
+}
+
+// MarkNotificationsRead marks the given notifications, or all of them, as read
+func (app *Config) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	var requestPayload NotificationsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.MarkNotificationsRead(timeoutCtx, requestPayload.UserId, requestPayload.Ids); err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "notifications marked as read",
+	})
+}
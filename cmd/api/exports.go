@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// exportFlushEvery is how many rows are written between flushes so the client sees progress
+const exportFlushEvery = 500
+
+type ExportPayload struct {
+	UserId string `json:"user_id"`
+	Format string `json:"format"` // csv (default) or ndjson
+	From   string `json:"from"`   // YYYY-MM-DD, inclusive
+	To     string `json:"to"`     // YYYY-MM-DD, inclusive
+}
+
+// The csv columns of each export, in order. They match the json names of the row types so both
+// formats carry the same fields; new columns go at the end.
+var (
+	inventoryExportColumns = []string{
+		"id", "name", "slug", "product_purpose", "category_slug", "subcategory_slug", "state_slug", "lga_slug",
+		"offer_price", "minimum_price", "security_deposit", "quantity", "rental_duration",
+		"is_available", "negotiable", "visibility", "deactivated", "created_at", "updated_at",
+	}
+	bookingExportColumns = []string{
+		"id", "inventory_id", "inventory_name", "variant_id", "renter_id", "renter_name", "owner_id", "owner_name",
+		"start_date", "start_time", "end_date", "end_time", "rental_type", "rental_duration", "quantity",
+		"offer_price_per_unit", "security_deposit", "total_amount", "status", "payment_status", "created_at",
+	}
+	saleExportColumns = []string{
+		"id", "inventory_id", "inventory_name", "variant_id", "buyer_id", "buyer_name", "seller_id", "seller_name",
+		"quantity", "offer_price_per_unit", "total_amount", "status", "payment_status", "created_at",
+	}
+)
+
+func inventoryExportRecord(row data.InventoryExportRow) []string {
+	return []string{
+		row.ID, row.Name, row.Slug, row.ProductPurpose, row.CategorySlug, row.SubcategorySlug, row.StateSlug, row.LgaSlug,
+		formatAmount(row.OfferPrice), formatAmount(row.MinimumPrice), formatAmount(row.SecurityDeposit), formatAmount(row.Quantity), row.RentalDuration,
+		row.IsAvailable, row.Negotiable, row.Visibility, strconv.FormatBool(row.Deactivated), formatExportTime(row.CreatedAt), formatExportTime(row.UpdatedAt),
+	}
+}
+
+func bookingExportRecord(row data.BookingExportRow) []string {
+	return []string{
+		row.ID, row.InventoryID, row.InventoryName, row.VariantID, row.RenterID, row.RenterName, row.OwnerID, row.OwnerName,
+		row.StartDate, row.StartTime, row.EndDate, row.EndTime, row.RentalType, formatAmount(row.RentalDuration), formatAmount(row.Quantity),
+		formatAmount(row.OfferPricePerUnit), formatAmount(row.SecurityDeposit), formatAmount(row.TotalAmount), row.Status, row.PaymentStatus, formatExportTime(row.CreatedAt),
+	}
+}
+
+func saleExportRecord(row data.SaleExportRow) []string {
+	return []string{
+		row.ID, row.InventoryID, row.InventoryName, row.VariantID, row.BuyerID, row.BuyerName, row.SellerID, row.SellerName,
+		formatAmount(row.Quantity), formatAmount(row.OfferPricePerUnit), formatAmount(row.TotalAmount), row.Status, row.PaymentStatus, formatExportTime(row.CreatedAt),
+	}
+}
+
+// ExportMyInventories streams the user's listings, see GetMyInventories
+func (app *Config) ExportMyInventories(w http.ResponseWriter, r *http.Request) {
+	payload, filter, ok := app.readExportPayload(w, r)
+	if !ok {
+		return
+	}
+
+	out := newExportWriter(w, payload.Format, "my-inventories", inventoryExportColumns)
+	err := app.Repo.ExportMyInventories(r.Context(), filter, func(row data.InventoryExportRow) error {
+		return out.write(row, inventoryExportRecord(row))
+	})
+	app.finishExport(w, out, err)
+}
+
+// ExportMyBookings streams the bookings the user made, see MyBookings
+func (app *Config) ExportMyBookings(w http.ResponseWriter, r *http.Request) {
+	app.exportBookings(w, r, false, "my-bookings")
+}
+
+// ExportBookingRequests streams the bookings made on the user's listings, see GetBookingRequest
+func (app *Config) ExportBookingRequests(w http.ResponseWriter, r *http.Request) {
+	app.exportBookings(w, r, true, "booking-requests")
+}
+
+// ExportMyPurchases streams the orders the user placed, see MyPurchase
+func (app *Config) ExportMyPurchases(w http.ResponseWriter, r *http.Request) {
+	app.exportSales(w, r, false, "my-purchases")
+}
+
+// ExportPurchaseRequests streams the orders placed on the user's listings, see GetPurchaseRequest
+func (app *Config) ExportPurchaseRequests(w http.ResponseWriter, r *http.Request) {
+	app.exportSales(w, r, true, "purchase-requests")
+}
+
+func (app *Config) exportBookings(w http.ResponseWriter, r *http.Request, asOwner bool, name string) {
+	payload, filter, ok := app.readExportPayload(w, r)
+	if !ok {
+		return
+	}
+
+	out := newExportWriter(w, payload.Format, name, bookingExportColumns)
+	err := app.Repo.ExportBookings(r.Context(), filter, asOwner, func(row data.BookingExportRow) error {
+		return out.write(row, bookingExportRecord(row))
+	})
+	app.finishExport(w, out, err)
+}
+
+func (app *Config) exportSales(w http.ResponseWriter, r *http.Request, asSeller bool, name string) {
+	payload, filter, ok := app.readExportPayload(w, r)
+	if !ok {
+		return
+	}
+
+	out := newExportWriter(w, payload.Format, name, saleExportColumns)
+	err := app.Repo.ExportSales(r.Context(), filter, asSeller, func(row data.SaleExportRow) error {
+		return out.write(row, saleExportRecord(row))
+	})
+	app.finishExport(w, out, err)
+}
+
+// readExportPayload reads and checks an export request. It writes the error response itself
+// and returns false when the request is not valid.
+func (app *Config) readExportPayload(w http.ResponseWriter, r *http.Request) (*ExportPayload, data.ExportFilter, bool) {
+	var requestPayload ExportPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return nil, data.ExportFilter{}, false
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return nil, data.ExportFilter{}, false
+	}
+
+	switch requestPayload.Format {
+	case "":
+		requestPayload.Format = "csv"
+	case "csv", "ndjson":
+	default:
+		app.errorJSON(w, errors.New("format must be csv or ndjson"), nil)
+		return nil, data.ExportFilter{}, false
+	}
+
+	filter := data.ExportFilter{UserId: requestPayload.UserId}
+	if requestPayload.From != "" {
+		from, err := time.Parse(time.DateOnly, requestPayload.From)
+		if err != nil {
+			app.errorJSON(w, errors.New("from must be a date like 2025-01-31"), nil)
+			return nil, data.ExportFilter{}, false
+		}
+		filter.From = &from
+	}
+	if requestPayload.To != "" {
+		to, err := time.Parse(time.DateOnly, requestPayload.To)
+		if err != nil {
+			app.errorJSON(w, errors.New("to must be a date like 2025-01-31"), nil)
+			return nil, data.ExportFilter{}, false
+		}
+		// the whole of the last day is included
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		app.errorJSON(w, errors.New("from must not be after to"), nil)
+		return nil, data.ExportFilter{}, false
+	}
+
+	return &requestPayload, filter, true
+}
+
+// exportWriter writes rows straight to the response as they are read from the database. Nothing
+// is sent until the first row, so an error before then can still become a json error response.
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  string
+	name    string
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	rows    int
+	started bool
+}
+
+func newExportWriter(w http.ResponseWriter, format, name string, columns []string) *exportWriter {
+	return &exportWriter{w: w, format: format, name: name, columns: columns}
+}
+
+func (e *exportWriter) start() error {
+	e.started = true
+
+	filename := fmt.Sprintf("%s-%s.%s", e.name, time.Now().UTC().Format("20060102"), e.format)
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if e.format == "ndjson" {
+		e.w.Header().Set("Content-Type", "application/x-ndjson")
+		e.w.WriteHeader(http.StatusOK)
+		e.json = json.NewEncoder(e.w)
+		return nil
+	}
+
+	e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	e.w.WriteHeader(http.StatusOK)
+	e.csv = csv.NewWriter(e.w)
+	return e.csv.Write(e.columns)
+}
+
+// write sends one row; value is encoded for ndjson and record for csv
+func (e *exportWriter) write(value any, record []string) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	if e.json != nil {
+		if err := e.json.Encode(value); err != nil {
+			return err
+		}
+	} else {
+		cells := make([]string, len(record))
+		for i, cell := range record {
+			cells[i] = csvCell(cell)
+		}
+		if err := e.csv.Write(cells); err != nil {
+			return err
+		}
+	}
+
+	e.rows++
+	if e.rows%exportFlushEvery == 0 {
+		e.flush()
+	}
+	return nil
+}
+
+// csvCell keeps spreadsheet programs from running a cell as a formula by prefixing the characters
+// a formula can start with with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (e *exportWriter) flush() {
+	if e.csv != nil {
+		e.csv.Flush()
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finishExport ends an export. An empty result still gets a csv header row. Once rows have been
+// sent the status can not change, so a later error is only logged and the download is cut short.
+func (app *Config) finishExport(w http.ResponseWriter, out *exportWriter, err error) {
+	if err != nil {
+		if !out.started {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+				app.errorJSON(w, errors.New("export timed out"), nil, http.StatusGatewayTimeout)
+				return
+			}
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("export %s stopped after %d rows: %v", out.name, out.rows, err)
+		return
+	}
+
+	if !out.started {
+		if err := out.start(); err != nil {
+			log.Printf("export %s: %v", out.name, err)
+			return
+		}
+	}
+	out.flush()
+}
+
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatExportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportWriter_CSVFormulas(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := newExportWriter(rec, "csv", "inventories", []string{"name", "price", "notes"})
+
+	rows := [][]string{
+		{"=HYPERLINK(\"http://evil\")", "-5", "@SUM(A1)"},
+		{"+1", "25000", "plain, with comma"},
+		{"Drill", "", "\tTab"},
+	}
+	for _, row := range rows {
+		if err := out.write(nil, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.flush()
+
+	want := "name,price,notes\n" +
+		"\"'=HYPERLINK(\"\"http://evil\"\")\",'-5,'@SUM(A1)\n" +
+		"'+1,25000,\"plain, with comma\"\n" +
+		"Drill,,'\tTab\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestExportWriter_NDJSONUnchanged(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := newExportWriter(rec, "ndjson", "inventories", nil)
+
+	if err := out.write(map[string]string{"name": "=1+1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rec.Body.String(), "{\"name\":\"=1+1\"}\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
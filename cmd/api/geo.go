@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+const (
+	// defaultSearchRadius and maxSearchRadius are in metres
+	defaultSearchRadius = 10000
+	maxSearchRadius     = 100000
+)
+
+// geoPoint checks a latitude and longitude sent together. Neither returns nil, for no location.
+func geoPoint(latitude, longitude *float64) (*data.GeoPoint, error) {
+	if latitude == nil && longitude == nil {
+		return nil, nil
+	}
+	if latitude == nil || longitude == nil {
+		return nil, errors.New("latitude and longitude must be sent together")
+	}
+	if math.IsNaN(*latitude) || *latitude < -90 || *latitude > 90 {
+		return nil, errors.New("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(*longitude) || *longitude < -180 || *longitude > 180 {
+		return nil, errors.New("longitude must be between -180 and 180")
+	}
+	return &data.GeoPoint{Latitude: *latitude, Longitude: *longitude}, nil
+}
+
+// parseGeoFilters takes near:lat,lng and within:5km (or within:800m) terms out of a search text and
+// returns the point and radius in metres with the rest of the text. The radius defaults to
+// defaultSearchRadius and is only allowed with a point.
+func parseGeoFilters(text string) (*data.GeoPoint, float64, string, error) {
+	var (
+		point  *data.GeoPoint
+		radius float64
+		rest   []string
+	)
+	for _, term := range strings.Fields(text) {
+		if value, ok := strings.CutPrefix(term, "near:"); ok {
+			lat, lng, found := strings.Cut(value, ",")
+			if !found {
+				return nil, 0, "", errors.New("near: needs a latitude and longitude, e.g. near:6.45,3.39")
+			}
+			latitude, latErr := strconv.ParseFloat(lat, 64)
+			longitude, lngErr := strconv.ParseFloat(lng, 64)
+			if latErr != nil || lngErr != nil {
+				return nil, 0, "", errors.New("near: needs a latitude and longitude, e.g. near:6.45,3.39")
+			}
+			p, err := geoPoint(&latitude, &longitude)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			point = p
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(term, "within:"); ok {
+			r, err := parseRadius(value)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			radius = r
+			continue
+		}
+
+		rest = append(rest, term)
+	}
+
+	if point == nil {
+		if radius > 0 {
+			return nil, 0, "", errors.New("within: needs a near: point")
+		}
+		return nil, 0, strings.Join(rest, " "), nil
+	}
+	if radius == 0 {
+		radius = defaultSearchRadius
+	}
+	return point, radius, strings.Join(rest, " "), nil
+}
+
+// parseRadius reads a distance in km or m, e.g. 5km or 800m, as metres
+func parseRadius(value string) (float64, error) {
+	unit := 1.0
+	number, ok := strings.CutSuffix(strings.ToLower(value), "km")
+	if ok {
+		unit = 1000
+	} else {
+		number = strings.TrimSuffix(number, "m")
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(n) || n <= 0 {
+		return 0, errors.New("within: needs a distance, e.g. within:5km")
+	}
+	if n*unit > maxSearchRadius {
+		return 0, fmt.Errorf("within: can be at most %dkm", maxSearchRadius/1000)
+	}
+	return n * unit, nil
+}
+
+type LocationPayload struct {
+	UserId    string   `json:"user_id"`
+	LgaId     string   `json:"lga_id"` // for admin-lga-centroid
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"` // leave both out to clear the location
+}
+
+// SetBusinessLocation sets where the user's business is. Their listings without coordinates of
+// their own are found in radius searches around it.
+func (app *Config) SetBusinessLocation(w http.ResponseWriter, r *http.Request) {
+	var requestPayload LocationPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+	point, err := geoPoint(requestPayload.Latitude, requestPayload.Longitude)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = app.Repo.SetBusinessLocation(timeoutCtx, requestPayload.UserId, point)
+	if errors.Is(err, data.ErrBusinessNotFound) {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "business location updated successfully",
+		Data:       point,
+	})
+}
+
+// AdminSetLgaCentroid sets the centre of an LGA, the location used for its listings that have no other
+func (app *Config) AdminSetLgaCentroid(w http.ResponseWriter, r *http.Request) {
+	var requestPayload LocationPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.LgaId == "" {
+		app.errorJSON(w, errors.New("missing lga_id"), nil)
+		return
+	}
+	point, err := geoPoint(requestPayload.Latitude, requestPayload.Longitude)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.SetLgaCentroid(timeoutCtx, requestPayload.LgaId, point); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "lga centroid updated successfully",
+		Data:       point,
+	})
+}
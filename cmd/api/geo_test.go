@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func TestParseRadius(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"5km", 5000, false},
+		{"2.5KM", 2500, false},
+		{"800m", 800, false},
+		{"800", 800, false},
+		{"100km", 100000, false},
+		{"101km", 0, true},
+		{"0km", 0, true},
+		{"-3km", 0, true},
+		{"far", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRadius(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseGeoFilters(t *testing.T) {
+	lagos := &data.GeoPoint{Latitude: 6.45, Longitude: 3.39}
+
+	tests := []struct {
+		name    string
+		text    string
+		point   *data.GeoPoint
+		radius  float64
+		rest    string
+		wantErr bool
+	}{
+		{"no location", "red sofa", nil, 0, "red sofa", false},
+		{"default radius", "sofa near:6.45,3.39", lagos, defaultSearchRadius, "sofa", false},
+		{"with a radius", "within:2km sofa near:6.45,3.39", lagos, 2000, "sofa", false},
+		{"radius without a point", "sofa within:2km", nil, 0, "", true},
+		{"no longitude", "near:6.45", nil, 0, "", true},
+		{"not numbers", "near:lagos,nigeria", nil, 0, "", true},
+		{"latitude out of range", "near:91,3.39", nil, 0, "", true},
+		{"longitude out of range", "near:6.45,181", nil, 0, "", true},
+		{"bad radius", "near:6.45,3.39 within:far", nil, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		point, radius, rest, err := parseGeoFilters(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(point, tt.point) || radius != tt.radius || rest != tt.rest {
+			t.Errorf("%s: got %v, %v, %q; want %v, %v, %q", tt.name, point, radius, rest, tt.point, tt.radius, tt.rest)
+		}
+	}
+}
+
+func TestSearchInventory_Near(t *testing.T) {
+	server, repo := searchServer()
+
+	_, err := server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: "generator near:6.45,3.39 within:800m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := repo.Searches[0]
+	if !reflect.DeepEqual(search.Near, &data.GeoPoint{Latitude: 6.45, Longitude: 3.39}) || search.RadiusMeters != 800 || search.Text != "generator" {
+		t.Errorf("got near %v within %v and text %q", search.Near, search.RadiusMeters, search.Text)
+	}
+
+	_, err = server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: "generator within:800m"})
+	if status.Code(err) != codes.InvalidArgument || len(repo.Searches) != 1 {
+		t.Errorf("got %v after %d searches; want InvalidArgument", err, len(repo.Searches))
+	}
+}
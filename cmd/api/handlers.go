@@ -1,26 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
-	"github.com/cloudinary/cloudinary-go"
-	"github.com/cloudinary/cloudinary-go/api/uploader"
 
 	"github.com/obynonwane/inventory-service/data"
-	"github.com/obynonwane/inventory-service/utility"
+	"github.com/obynonwane/inventory-service/media"
 	"github.com/obynonwane/rental-service-proto/inventory"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -54,314 +52,67 @@ func (app *Config) grpcListen() {
 
 func (i *InventoryServer) CreateInventory(ctx context.Context, req *inventory.CreateInventoryRequest) (*inventory.CreateInventoryResponse, error) {
 
-	var wg sync.WaitGroup
-
-	catErrCh := make(chan error, 1)            // Buffered to avoid blocking
-	categoryCh := make(chan *data.Category, 1) // Buffered to avoid blocking
-
-	subCatErrCh := make(chan error, 1)          // Buffered to avoid blocking
-	subCatCh := make(chan *data.Subcategory, 1) // Buffered to avoid blocking
-
-	stateErrCh := make(chan error, 1)    // Buffered to avoid blocking
-	stateCh := make(chan *data.State, 1) // Buffered to avoid blocking
-
-	countryErrCh := make(chan error, 1)      // Buffered to avoid blocking
-	countryCh := make(chan *data.Country, 1) // Buffered to avoid blocking
-
-	lgaErrCh := make(chan error, 1)  // Buffered to avoid blocking
-	lgaCh := make(chan *data.Lga, 1) // Buffered to avoid blocking
-
-	// Validate category
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		param := &data.GetCategoryByIDPayload{
-			CategoryID: req.CategoryId,
-		}
-
-		category, catErr := i.Models.GetCategoryByID(ctx, param)
-		catErrCh <- catErr     // Write the error or nil
-		categoryCh <- category // Write the subcategory or nil
-	}()
-
-	// Validate subcategory
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		subcategory, subCatErr := i.Models.GetSubcategoryByID(ctx, req.SubCategoryId)
-		subCatErrCh <- subCatErr // Write the error or nil
-		subCatCh <- subcategory  // Write the subcategory or nil
-	}()
-
-	// Validate state
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		state, stateErr := i.Models.GetStateByID(ctx, req.StateId)
-		stateErrCh <- stateErr // Write the error or nil
-		stateCh <- state       // Write the state or nil
-	}()
-
-	// Validate country
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		country, countryErr := i.Models.GetCountryByID(ctx, req.CountryId)
-		countryErrCh <- countryErr // Write the error or nil
-		countryCh <- country       // Write the country or nil
-	}()
-
-	// Validate Lga
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		lga, lgaErr := i.Models.GetLgaByID(ctx, req.LgaId)
-		lgaErrCh <- lgaErr // Write the error or nil
-		lgaCh <- lga       // Write the country or nil
-	}()
-
-	// Wait for both goroutines to finish
-	wg.Wait()
-
-	// Close channels after all goroutines finish writing
-	close(catErrCh)
-	close(subCatErrCh)
-	close(stateErrCh)
-	close(countryErrCh)
-	close(lgaErrCh)
-	close(subCatCh)
-	close(stateCh)
-	close(countryCh)
-	close(lgaCh)
-
-	// Read category validation error
-	catErr := <-catErrCh
-	if catErr != nil {
-		return nil, fmt.Errorf("error validating category: %v", catErr)
-	}
-
-	// Read subcategory validation error
-	subCatErr := <-subCatErrCh
-	if subCatErr != nil {
-		return nil, fmt.Errorf("error validating subcategory: %v", subCatErr)
-	}
-
-	// Read state validation error
-	stateErr := <-stateErrCh
-	if stateErr != nil {
-		return nil, fmt.Errorf("error validating state: %v", stateErr)
+	// validate the category, subcategory and location hierarchy
+	if _, err := resolveInventoryTaxonomy(ctx, i.Models, req.CategoryId, req.SubCategoryId, req.CountryId, req.StateId, req.LgaId); err != nil {
+		return nil, err
 	}
 
-	// Read country validation error
-	countryErr := <-countryErrCh
-	if countryErr != nil {
-		return nil, fmt.Errorf("error validating country: %v", stateErr)
+	if _, err := normaliseInventoryTags(req.Tags); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	// Read lga validation error
-	lgaErr := <-lgaErrCh
-	if lgaErr != nil {
-		return nil, fmt.Errorf("error validating lga: %v", lgaErr)
+	// metadata must fit the subcategory's attributes
+	if _, err := i.App.validateInventoryAttributes(ctx, req.SubCategoryId, req.Metadata); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	category := <-categoryCh
-	country := <-countryCh
-	// Read and validate subcategory
-	subcategory := <-subCatCh
-	if subcategory == nil {
-		return nil, fmt.Errorf("subcategory not found")
-	}
-
-	if subcategory.CategoryId != req.CategoryId {
-		return nil, fmt.Errorf("subcategory does not belong to category")
+	// reject unsupported or oversized images up front instead of failing them in the background
+	images := append([]*inventory.ImageData{req.PrimaryImage}, req.Images...)
+	for _, img := range images {
+		if img == nil {
+			return nil, status.Error(codes.InvalidArgument, "image data is missing")
+		}
+		if _, err := media.CheckImage(img.ImageData); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 	}
 
-	// Read and validate state & country
-	state := <-stateCh
-	if state == nil {
-		return nil, fmt.Errorf("state not found")
-	}
-	if state.CountryID != req.CountryId {
-		return nil, fmt.Errorf("state does not belong to country")
+	// when duplicates are blocked, refuse them before anything is queued
+	if i.App.Duplicates.Mode == DuplicateModeBlock {
+		var contents [][]byte
+		for _, img := range images {
+			contents = append(contents, img.ImageData)
+		}
+		if _, err := i.App.checkDuplicates(ctx, req.UserId, "", req.Name, req.Description, hashImages(contents)); err != nil {
+			if errors.Is(err, errDuplicateListing) {
+				return nil, status.Error(codes.AlreadyExists, err.Error())
+			}
+			return nil, err
+		}
 	}
 
-	// Read and validate state & lga
-	lga := <-lgaCh
-	if lga == nil {
-		return nil, fmt.Errorf("lga not found")
-	}
-	if lga.StateID != req.StateId {
-		return nil, fmt.Errorf("lga does not belong to state")
+	job, err := i.App.queueInventoryJob(ctx, req)
+	if errors.Is(err, data.ErrPostingQuotaExceeded) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
 	}
-
-	// Increase the timeout duration for Cloudinary initialization and image uploads
-	cld, err := cloudinary.NewFromParams(
-		os.Getenv("CLOUDINARY_CLOUD_NAME"),
-		os.Getenv("CLOUDINARY_API_KEY"),
-		os.Getenv("CLOUDINARY_API_SECRET"),
-	)
 	if err != nil {
 		return &inventory.CreateInventoryResponse{
-			Message:    "Failed to initialize Cloudinary",
+			Message:    "Failed to queue inventory creation",
 			StatusCode: 500,
 			Error:      true,
 		}, err
 	}
 
-	go func() {
-		var urls []string
-		var primageImageURL string
-		var wg sync.WaitGroup
-
-		//======================Upload multiple Image  for inventories to cloudinary =====================================================================
-
-		for _, image := range req.Images {
-			wg.Add(1)
-			go func(img *inventory.ImageData) {
-				defer wg.Done()
-
-				uploadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute) // Increased timeout for image upload
-				defer cancel()
-
-				// Validate MIME type and map to Cloudinary's expected format
-				switch img.ImageType {
-				case "image/jpeg", "image/png", "image/gif": // Supported types
-					// Generate unique filename (without extension)
-					uniqueFilename := i.App.generateUniqueFilename()
-
-					// Upload directly from byte stream to Cloudinary
-					uploadResult, err := cld.Upload.Upload(uploadCtx, bytes.NewReader(img.ImageData), uploader.UploadParams{
-						Folder:   "rentalsolution/inventories",
-						PublicID: uniqueFilename, // Pass filename without extension
-					})
-					if err != nil {
-						log.Printf("Error uploading to Cloudinary: %v", err)
-						return
-					}
-
-					var mu sync.Mutex
-					// Append the Cloudinary URL in a thread-safe manner
-					mu.Lock()
-					urls = append(urls, uploadResult.SecureURL)
-					mu.Unlock()
-				default:
-					log.Printf("Unsupported image format: %s", img.ImageType)
-					return
-				}
-			}(image)
-		}
-
-		//======================End Upload multiple Image  for inventories to cloudinary =====================================================================
-
-		//======================Upload Single Image for - Primary Image to cloudinary =====================================================================
-		wg.Add(1)
-		go func(img *inventory.ImageData) {
-			defer wg.Done()
-
-			uploadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute) // Increased timeout for image upload
-			defer cancel()
-
-			// Validate MIME type and map to Cloudinary's expected format
-			switch img.ImageType {
-			case "image/jpeg", "image/png", "image/gif": // Supported types
-				// Generate unique filename (without extension)
-				uniqueFilename := i.App.generateUniqueFilename()
-
-				// Upload directly from byte stream to Cloudinary
-				uploadResult, err := cld.Upload.Upload(uploadCtx, bytes.NewReader(img.ImageData), uploader.UploadParams{
-					Folder:   "rentalsolution/inventories",
-					PublicID: uniqueFilename, // Pass filename without extension
-				})
-				if err != nil {
-					log.Printf("Error uploading to Cloudinary: %v", err)
-					return
-				}
-
-				var mu sync.Mutex
-				// Append the Cloudinary URL in a thread-safe manner
-				mu.Lock()
-				primageImageURL = uploadResult.SecureURL
-				mu.Unlock()
-
-			default:
-				log.Printf("Unsupported image format: %s", img.ImageType)
-				return
-			}
-		}(req.PrimaryImage)
-		//====================== END Upload Single Image for - Primary Image to cloudinary =====================================================================
-
-		wg.Wait()
-
-		dbCtx, cancel := context.WithTimeout(context.Background(), 15*time.Minute) // Increased timeout for DB transaction
-		defer cancel()
-
-		// Prepare for transaction
-		tx, err := i.Models.BeginTransaction(dbCtx)
-		if err != nil {
-			log.Println(fmt.Errorf("failed to begin transaction: %v", err))
-			return
-		}
-		defer func() {
-			if p := recover(); p != nil {
-				tx.Rollback()
-				panic(p)
-			} else if err != nil {
-				tx.Rollback()
-			} else {
-				tx.Commit()
-			}
-		}()
-
-		slug, ulid := utility.GenerateSlug(req.Name)
-		description := utility.TextToLower(req.Description)
-
-		// Save product details and images in the database (if applicable)
-		err = i.Models.CreateInventory(&data.CreateInventoryParams{
-			Tx:              tx,
-			Ctx:             dbCtx,
-			Name:            req.Name,
-			Description:     description,
-			UserID:          req.UserId,
-			CategoryID:      req.CategoryId,
-			SubcategoryID:   req.SubCategoryId,
-			CountryID:       req.CountryId,
-			StateID:         req.StateId,
-			LgaID:           req.LgaId,
-			Slug:            slug,
-			ULID:            ulid,
-			StateSlug:       state.StateSlug,
-			CountrySlug:     country.Code,
-			LgaSlug:         lga.LgaSlug,
-			CategorySlug:    category.CategorySlug,
-			SubcategorySlug: subcategory.SubCategorySlug,
-			OfferPrice:      req.OfferPrice,
-			MinimumPrice:    req.MinimumPrice,
-			URLs:            urls,
-
-			ProductPurpose:  req.ProductPurpose,
-			Quantity:        req.Quantity,
-			IsAvailable:     req.IsAvailable,
-			RentalDuration:  req.RentalDuration,
-			SecurityDeposit: req.SecurityDeposit,
-			Tags:            req.Tags,
-			Metadata:        req.Metadata,
-			Negotiable:      req.Negotiable,
-			PrimaryImage:    primageImageURL,
-			Included:        req.Included,
-			UsageGuide:      req.UsageGuide,
-			Condition:       req.Condition,
-		})
+	// the job id travels as response metadata so clients can poll the job status endpoint
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-inventory-job-id", job.ID)); err != nil {
+		log.Printf("failed to send job id header: %v", err)
+	}
 
-		if err != nil {
-			log.Println(fmt.Errorf("error creating inventory for user %s - %s", req.UserId, err))
-			return
-		}
-	}()
+	go i.App.runInventoryJob(job.ID, data.ChangeSourceGRPC)
 
 	// Immediately return success response to the user
 	return &inventory.CreateInventoryResponse{
-		Message:    "Inventory creation request received. Processing images in the background.",
+		Message:    fmt.Sprintf("Inventory creation request received. Processing images in the background. Job ID: %s", job.ID),
 		StatusCode: 202, // 202 Accepted since the processing is asynchronous
 		Error:      false,
 	}, nil
@@ -813,6 +564,18 @@ func (app *Config) GetBusinessDetail(w http.ResponseWriter, r *http.Request) {
 
 	// get the user detail
 	user, err := app.Repo.GetBusinessBySubdomain(timeoutCtx, domain)
+
+	// a business that changed its subdomain is still found by the old one
+	redirect := false
+	if errors.Is(err, data.ErrBusinessNotFound) {
+		subdomain, resolveErr := app.Repo.ResolveSlugRedirect(timeoutCtx, data.SlugKindBusiness, domain)
+		if resolveErr != nil {
+			err = resolveErr
+		} else if subdomain != "" {
+			user, err = app.Repo.GetBusinessBySubdomain(timeoutCtx, subdomain)
+			redirect = err == nil
+		}
+	}
 	if err != nil {
 		app.errorJSON(w, err, nil, http.StatusInternalServerError)
 		return
@@ -844,6 +607,9 @@ func (app *Config) GetBusinessDetail(w http.ResponseWriter, r *http.Request) {
 			"userRatingCount":   userRatingCount,
 			"totalListingCount": totalListingCount,
 			"kycs":              businessKyc,
+			// when redirect is set the client should move permanently to subdomain
+			"redirect":  redirect,
+			"subdomain": user.Subdomain,
 		},
 	}
 
@@ -916,8 +682,19 @@ func (i *InventoryServer) GetInventoryByID(ctx context.Context, req *inventory.S
 
 		inv, err := i.Models.GetInventoryByIDOrSlug(timeoutCtx, req.SlugUlid, req.InventoryId)
 
+		// a renamed listing is still found by an old slug, under its current one
+		if errors.Is(err, data.ErrInventoryNotFound) && req.SlugUlid != "" && req.InventoryId == "" {
+			slug, resolveErr := i.Models.ResolveSlugRedirect(timeoutCtx, data.SlugKindInventory, req.SlugUlid)
+			if resolveErr != nil {
+				err = resolveErr
+			} else if slug != "" {
+				inv, err = i.Models.GetInventoryByIDOrSlug(timeoutCtx, slug, "")
+			}
+		}
+
 		if err != nil {
 			errInventoryExistCh <- err
+			return
 		}
 		inventoryExistCh <- inv
 
@@ -927,6 +704,21 @@ func (i *InventoryServer) GetInventoryByID(ctx context.Context, req *inventory.S
 
 	case di := <-inventoryExistCh:
 
+		// the canonical slug travels as response metadata so the client can redirect permanently
+		if req.SlugUlid != "" && req.InventoryId == "" && di.Slug != req.SlugUlid {
+			if err := grpc.SetHeader(ctx, metadata.Pairs("x-canonical-slug", di.Slug, "x-slug-redirect", "true")); err != nil {
+				log.Printf("failed to send canonical slug header: %v", err)
+			}
+		}
+
+		i.App.sendQuestionHeaders(ctx, timeoutCtx, di.ID)
+		sendBundleHeaders(ctx, di)
+
+		// owners looking at their own listing are not counted
+		if visitor := grpcVisitor(ctx); visitor != "" && visitor != di.UserId {
+			go i.App.recordInventoryEvent(data.InventoryEventView, visitor, di.ID)
+		}
+
 		user, err := i.Models.GetUserByID(ctx, di.UserId)
 		if err != nil {
 			log.Fatal("error getting user who owns inventory", err)
@@ -1431,12 +1223,27 @@ func (s *InventoryServer) SearchInventory(
 		req.UserId = userWithSubdomain.UserID
 	}
 
+	// attr.name>=value terms in the text filter on listing attributes
+	attributes, text, err := parseAttributeFilters(req.Text)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// and tag:name terms on tags
+	tags, text := parseTagFilters(text)
+	// near:lat,lng and within:5km terms find listings around a point, nearest first
+	near, radius, text, err := parseGeoFilters(text)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// and a sort:price_asc term orders the results
+	order, text := parseSortFilter(text)
+
 	// 2) Build your data.SearchPayload (Limit/Offset as strings)
 	param := &data.SearchPayload{
 		CountryID:       req.CountryId,
 		StateID:         req.StateId,
 		LgaID:           req.LgaId,
-		Text:            req.Text,
+		Text:            text,
 		Limit:           req.Limit,
 		Offset:          req.Offset,
 		CategoryID:      req.CategoryId,
@@ -1450,15 +1257,45 @@ func (s *InventoryServer) SearchInventory(
 		UserID:          req.UserId,
 		ProductPurpose:  req.ProductPurpose,
 		UserSlug:        req.UserSlug,
+		Attributes:      attributes,
+		Tags:            tags,
+		Near:            near,
+		RadiusMeters:    radius,
+		Sort:            order,
 	}
 
 	// 3) Call your repo
 	dc, err := s.Models.SearchInventory(ctx, param)
+	if errors.Is(err, data.ErrInvalidSearchSort) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal,
 			"failed to search inventories: %v", err)
 	}
 
+	// the proto has no distance field, so the distance of each result in metres is sent as
+	// response metadata, a JSON object by inventory id
+	if near != nil {
+		if distances, err := json.Marshal(dc.Distances); err != nil {
+			log.Printf("failed to encode distances: %v", err)
+		} else if err := grpc.SetHeader(ctx, metadata.Pairs("x-inventory-distances", string(distances))); err != nil {
+			log.Printf("failed to send distances header: %v", err)
+		}
+	}
+
+	// promoted placements shown on this page count as impressions
+	go s.App.recordPromotionImpressions(dc.Promotions)
+
+	// and so does every listing on it, for its owner's analytics
+	if visitor := grpcVisitor(ctx); visitor != "" {
+		ids := make([]string, 0, len(dc.Inventories))
+		for _, inv := range dc.Inventories {
+			ids = append(ids, inv.Id)
+		}
+		go s.App.recordInventoryEvent(data.InventoryEventImpression, visitor, ids...)
+	}
+
 	// 4) Map data.InventoryCollection → proto.InventoryCollection
 	resp := &inventory.InventoryCollection{
 		Inventories: []*inventory.Inventory{}, // <- explicitly set this
@@ -1573,6 +1410,7 @@ func (app *Config) SaveInventory(w http.ResponseWriter, r *http.Request) {
 				app.errorJSON(w, errors.New("failed to save inventory"), nil, http.StatusInternalServerError)
 				return
 			}
+			go app.recordInventoryEvent(data.InventoryEventSave, requestPayload.UserId, requestPayload.InventoryId)
 
 			app.writeJSON(w, http.StatusCreated, jsonResponse{
 				Error:      false,
@@ -1663,7 +1501,7 @@ func (app *Config) DeleteInventory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.Repo.DeleteInventory(timeoutCtx, requestPayload)
+	err = app.Repo.DeleteInventory(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), requestPayload)
 	if err != nil {
 		app.errorJSON(w, err, nil, http.StatusInternalServerError)
 		return
@@ -1882,7 +1720,7 @@ func (app *Config) MarkInventoryAvailability(w http.ResponseWriter, r *http.Requ
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second) // Example timeout duration
 	defer cancel()
 
-	err = app.Repo.MarkInventoryAvailability(timeoutCtx, requestPayload)
+	err = app.Repo.MarkInventoryAvailability(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), requestPayload)
 	if err != nil {
 		app.errorJSON(w, err, nil)
 		return
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"path"
 	"time"
 
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -90,6 +96,80 @@ func (app *Config) generateUniqueFilename() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
+// uploadMedia stores content in folder under a unique name and returns its URL
+func (app *Config) uploadMedia(ctx context.Context, folder, contentType string, content []byte) (string, error) {
+	return app.Media.Put(ctx, path.Join(folder, app.generateUniqueFilename()), contentType, bytes.NewReader(content))
+}
+
+// uploadImage processes an image and stores its thumbnail, card and full derivatives under one unique name
+func (app *Config) uploadImage(ctx context.Context, folder string, content []byte) (*data.ImageURLs, error) {
+	processed, err := media.ProcessImage(content)
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Join(folder, app.generateUniqueFilename())
+	hash := int64(processed.Hash)
+	urls := data.ImageURLs{Hash: &hash}
+	var stored []string
+	for _, d := range []struct {
+		derivative media.Derivative
+		url        *string
+	}{
+		{processed.Thumbnail, &urls.Thumbnail},
+		{processed.Card, &urls.Card},
+		{processed.Full, &urls.Full},
+	} {
+		key := name + "_" + d.derivative.Size.Name
+		url, err := app.Media.Put(ctx, key, d.derivative.ContentType, bytes.NewReader(d.derivative.Data))
+		if err != nil {
+			// don't leave a partial set of derivatives behind
+			for _, key := range stored {
+				if err := app.Media.Delete(ctx, key); err != nil {
+					log.Printf("Error deleting %s from media store: %v", key, err)
+				}
+			}
+			return nil, err
+		}
+		stored = append(stored, key)
+		*d.url = url
+	}
+
+	return &urls, nil
+}
+
+// uploadFullImage processes an image and stores only its full derivative, for single image slots
+// such as profile pictures and shop banners
+func (app *Config) uploadFullImage(ctx context.Context, folder string, content []byte) (string, error) {
+	processed, err := media.ProcessImage(content)
+	if err != nil {
+		return "", err
+	}
+
+	return app.uploadMedia(ctx, folder, processed.Full.ContentType, processed.Full.Data)
+}
+
+// deleteImage removes every stored derivative of a gallery image. Failures only leave orphaned files
+// behind, so they are logged rather than returned.
+func (app *Config) deleteImage(ctx context.Context, img *data.InventoryImage) {
+	seen := make(map[string]bool)
+	for _, url := range []string{img.LiveUrl, img.ThumbnailUrl, img.CardUrl, img.FullUrl} {
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		key := app.Media.Key(url)
+		if key == "" {
+			log.Printf("Image %s is not held by the media store, leaving it in place", url)
+			continue
+		}
+		if err := app.Media.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting %s from media store: %v", url, err)
+		}
+	}
+}
+
 func formatTimestamp(ts *timestamppb.Timestamp) string {
 	if ts == nil {
 		return ""
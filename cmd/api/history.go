@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type InventoryHistoryPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+}
+
+// InventoryHistory lists every recorded change to one of the user's listings, oldest first.
+// Deleted listings keep their history, so the owner check does not go through getOwnedInventory.
+func (app *Config) InventoryHistory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryHistoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	owner, err := app.Repo.GetInventoryOwner(timeoutCtx, requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+	if owner != requestPayload.UserId {
+		app.errorJSON(w, errors.New("you are not allowed to view this inventory"), nil, http.StatusForbidden)
+		return
+	}
+
+	app.writeInventoryHistory(w, timeoutCtx, requestPayload.InventoryId)
+}
+
+// AdminGetInventoryHistory lists the recorded changes to any listing
+func (app *Config) AdminGetInventoryHistory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryHistoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := app.Repo.GetInventoryOwner(timeoutCtx, requestPayload.InventoryId); err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+
+	app.writeInventoryHistory(w, timeoutCtx, requestPayload.InventoryId)
+}
+
+func (app *Config) writeInventoryHistory(w http.ResponseWriter, ctx context.Context, inventoryID string) {
+	history, err := app.Repo.GetInventoryHistory(ctx, inventoryID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory history retrieved successfully",
+		Data:       history,
+	})
+}
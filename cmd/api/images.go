@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
+)
+
+type InventoryImagePayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id" binding:"required"`
+	ImageId     string `json:"image_id" binding:"required"`
+}
+
+type ReorderInventoryImagesPayload struct {
+	UserId      string   `json:"user_id"`
+	InventoryId string   `json:"inventory_id" binding:"required"`
+	ImageIds    []string `json:"image_ids" binding:"required"`
+}
+
+// imageErrorStatus maps a gallery repository error to 404 for an unknown image, 400 for a bad
+// order and 500 for everything else
+func imageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrImageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrInvalidImageOrder):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// AddInventoryImages uploads one or more images (multipart field "images") to the end of a listing's gallery
+func (app *Config) AddInventoryImages(w http.ResponseWriter, r *http.Request) {
+	// Parse the incoming multipart form
+	err := r.ParseMultipartForm(20 << 20) // 20 MB
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to parse form"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, r.FormValue("inventory_id"), r.FormValue("user_id"))
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		app.errorJSON(w, errors.New("no image supplied"), nil)
+		return
+	}
+
+	// read and validate every file before uploading any of them
+	var images [][]byte
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			app.errorJSON(w, errors.New("failed to read image"), nil)
+			return
+		}
+
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, file)
+		file.Close()
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := media.CheckImage(buf.Bytes()); err != nil {
+			app.errorJSON(w, fmt.Errorf("%s: %w", header.Filename, err), nil)
+			return
+		}
+
+		images = append(images, buf.Bytes())
+	}
+
+	// new photos that copy another of the seller's listings count as a duplicate
+	duplicates, err := app.checkDuplicates(timeoutCtx, inv.UserId, inv.ID, "", "", hashImages(images))
+	if errors.Is(err, errDuplicateListing) {
+		app.errorJSON(w, err, duplicates, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	uploadCtx, cancelUpload := context.WithTimeout(context.Background(), 10*time.Minute) // Increased timeout for image upload
+	defer cancelUpload()
+
+	var uploads []data.ImageURLs
+	for _, img := range images {
+		urls, err := app.uploadImage(uploadCtx, "rentalsolution/inventories", img)
+		if err != nil {
+			log.Printf("Error uploading image: %v", err)
+			app.errorJSON(w, errors.New("failed to upload image"), nil, http.StatusInternalServerError)
+			return
+		}
+		uploads = append(uploads, *urls)
+	}
+
+	dbCtx, cancelDb := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelDb()
+	dbCtx = data.WithChangeActor(dbCtx, inv.UserId, data.ChangeSourceHTTP)
+
+	added, err := app.Repo.AddInventoryImages(dbCtx, inv.ID, uploads)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.flagDuplicates(dbCtx, inv.ID, duplicates)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "images added successfully",
+		Data:       added,
+	})
+}
+
+// DeleteInventoryImage removes an image from a listing and deletes the uploaded asset
+func (app *Config) DeleteInventoryImage(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload InventoryImagePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	deleted, err := app.Repo.DeleteInventoryImage(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), inv.ID, requestPayload.ImageId)
+	if err != nil {
+		app.errorJSON(w, err, nil, imageErrorStatus(err))
+		return
+	}
+
+	// the row is gone, so a failure here only leaves an orphaned asset behind
+	app.deleteImage(timeoutCtx, deleted)
+
+	images, err := app.Repo.GetInventoryImages(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "image deleted successfully",
+		Data:       images,
+	})
+}
+
+// ReorderInventoryImages sets the gallery order; the first image becomes the primary image
+func (app *Config) ReorderInventoryImages(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload ReorderInventoryImagesPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	err = app.Repo.ReorderInventoryImages(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), inv.ID, requestPayload.ImageIds)
+	if err != nil {
+		app.errorJSON(w, err, nil, imageErrorStatus(err))
+		return
+	}
+
+	images, err := app.Repo.GetInventoryImages(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "images reordered successfully",
+		Data:       images,
+	})
+}
+
+// SetPrimaryInventoryImage moves an image to the front of the gallery and makes it the primary image
+func (app *Config) SetPrimaryInventoryImage(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload InventoryImagePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	err = app.Repo.SetPrimaryInventoryImage(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), inv.ID, requestPayload.ImageId)
+	if err != nil {
+		app.errorJSON(w, err, nil, imageErrorStatus(err))
+		return
+	}
+
+	images, err := app.Repo.GetInventoryImages(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "primary image updated successfully",
+		Data:       images,
+	})
+}
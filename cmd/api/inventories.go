@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/utility"
+)
+
+// inventoryTaxonomy holds the validated category and location records a listing
+// is filed under, used to fill the denormalised slug columns
+type inventoryTaxonomy struct {
+	Category    *data.Category
+	Subcategory *data.Subcategory
+	Country     *data.Country
+	State       *data.State
+	Lga         *data.Lga
+}
+
+// resolveInventoryTaxonomy loads the category, subcategory, country, state and lga
+// concurrently and checks that each belongs to its parent
+func resolveInventoryTaxonomy(ctx context.Context, repo data.Repository, categoryID, subCategoryID, countryID, stateID, lgaID string) (*inventoryTaxonomy, error) {
+
+	var wg sync.WaitGroup
+
+	catErrCh := make(chan error, 1)            // Buffered to avoid blocking
+	categoryCh := make(chan *data.Category, 1) // Buffered to avoid blocking
+
+	subCatErrCh := make(chan error, 1)          // Buffered to avoid blocking
+	subCatCh := make(chan *data.Subcategory, 1) // Buffered to avoid blocking
+
+	stateErrCh := make(chan error, 1)    // Buffered to avoid blocking
+	stateCh := make(chan *data.State, 1) // Buffered to avoid blocking
+
+	countryErrCh := make(chan error, 1)      // Buffered to avoid blocking
+	countryCh := make(chan *data.Country, 1) // Buffered to avoid blocking
+
+	lgaErrCh := make(chan error, 1)  // Buffered to avoid blocking
+	lgaCh := make(chan *data.Lga, 1) // Buffered to avoid blocking
+
+	// Validate category
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		param := &data.GetCategoryByIDPayload{
+			CategoryID: categoryID,
+		}
+
+		category, catErr := repo.GetCategoryByID(ctx, param)
+		catErrCh <- catErr     // Write the error or nil
+		categoryCh <- category // Write the subcategory or nil
+	}()
+
+	// Validate subcategory
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		subcategory, subCatErr := repo.GetSubcategoryByID(ctx, subCategoryID)
+		subCatErrCh <- subCatErr // Write the error or nil
+		subCatCh <- subcategory  // Write the subcategory or nil
+	}()
+
+	// Validate state
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		state, stateErr := repo.GetStateByID(ctx, stateID)
+		stateErrCh <- stateErr // Write the error or nil
+		stateCh <- state       // Write the state or nil
+	}()
+
+	// Validate country
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		country, countryErr := repo.GetCountryByID(ctx, countryID)
+		countryErrCh <- countryErr // Write the error or nil
+		countryCh <- country       // Write the country or nil
+	}()
+
+	// Validate Lga
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lga, lgaErr := repo.GetLgaByID(ctx, lgaID)
+		lgaErrCh <- lgaErr // Write the error or nil
+		lgaCh <- lga       // Write the country or nil
+	}()
+
+	// Wait for both goroutines to finish
+	wg.Wait()
+
+	// Close channels after all goroutines finish writing
+	close(catErrCh)
+	close(subCatErrCh)
+	close(stateErrCh)
+	close(countryErrCh)
+	close(lgaErrCh)
+	close(subCatCh)
+	close(stateCh)
+	close(countryCh)
+	close(lgaCh)
+
+	// Read category validation error
+	catErr := <-catErrCh
+	if catErr != nil {
+		return nil, fmt.Errorf("error validating category: %v", catErr)
+	}
+
+	// Read subcategory validation error
+	subCatErr := <-subCatErrCh
+	if subCatErr != nil {
+		return nil, fmt.Errorf("error validating subcategory: %v", subCatErr)
+	}
+
+	// Read state validation error
+	stateErr := <-stateErrCh
+	if stateErr != nil {
+		return nil, fmt.Errorf("error validating state: %v", stateErr)
+	}
+
+	// Read country validation error
+	countryErr := <-countryErrCh
+	if countryErr != nil {
+		return nil, fmt.Errorf("error validating country: %v", countryErr)
+	}
+
+	// Read lga validation error
+	lgaErr := <-lgaErrCh
+	if lgaErr != nil {
+		return nil, fmt.Errorf("error validating lga: %v", lgaErr)
+	}
+
+	category := <-categoryCh
+	if category == nil {
+		return nil, fmt.Errorf("category not found")
+	}
+
+	country := <-countryCh
+	if country == nil {
+		return nil, fmt.Errorf("country not found")
+	}
+
+	// Read and validate subcategory
+	subcategory := <-subCatCh
+	if subcategory == nil {
+		return nil, fmt.Errorf("subcategory not found")
+	}
+
+	if subcategory.CategoryId != categoryID {
+		return nil, fmt.Errorf("subcategory does not belong to category")
+	}
+
+	// Read and validate state & country
+	state := <-stateCh
+	if state == nil {
+		return nil, fmt.Errorf("state not found")
+	}
+	if state.CountryID != countryID {
+		return nil, fmt.Errorf("state does not belong to country")
+	}
+
+	// Read and validate state & lga
+	lga := <-lgaCh
+	if lga == nil {
+		return nil, fmt.Errorf("lga not found")
+	}
+	if lga.StateID != stateID {
+		return nil, fmt.Errorf("lga does not belong to state")
+	}
+
+	return &inventoryTaxonomy{
+		Category:    category,
+		Subcategory: subcategory,
+		Country:     country,
+		State:       state,
+		Lga:         lga,
+	}, nil
+}
+
+type UpdateInventoryPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id" binding:"required"`
+
+	Name            *string  `json:"name"`
+	Description     *string  `json:"description"`
+	OfferPrice      *float64 `json:"offer_price"`
+	MinimumPrice    *float64 `json:"minimum_price"`
+	SecurityDeposit *float64 `json:"security_deposit"`
+
+	CategoryId    *string `json:"category_id"`
+	SubCategoryId *string `json:"sub_category_id"`
+	CountryId     *string `json:"country_id"`
+	StateId       *string `json:"state_id"`
+	LgaId         *string `json:"lga_id"`
+
+	Tags       *string `json:"tags"`
+	UsageGuide *string `json:"usage_guide"`
+	Condition  *string `json:"condition"`
+	Included   *string `json:"included"`
+	Metadata   *string `json:"metadata"` // JSON object, checked against the subcategory's attributes
+
+	Latitude      *float64 `json:"latitude"`
+	Longitude     *float64 `json:"longitude"`
+	ClearLocation bool     `json:"clear_location"` // use the business location or LGA centroid again
+}
+
+// UpdateInventory edits a listing in place. Only the fields present in the request body are changed.
+func (app *Config) UpdateInventory(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload UpdateInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	// Create a context with a timeout for the asynchronous task
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second) // Example timeout duration
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	params := data.UpdateInventoryParams{
+		UserId:          requestPayload.UserId,
+		InventoryId:     inv.ID,
+		OfferPrice:      requestPayload.OfferPrice,
+		MinimumPrice:    requestPayload.MinimumPrice,
+		SecurityDeposit: requestPayload.SecurityDeposit,
+		UsageGuide:      requestPayload.UsageGuide,
+		Condition:       requestPayload.Condition,
+		Included:        requestPayload.Included,
+	}
+
+	if requestPayload.Name != nil {
+		name := strings.TrimSpace(*requestPayload.Name)
+		if name == "" {
+			app.errorJSON(w, errors.New("name can not be empty"), nil, http.StatusBadRequest)
+			return
+		}
+		// keep the ULID so lookups by ulid still resolve after a rename
+		slug := utility.SlugWithULID(name, inv.Ulid)
+		params.Name = &name
+		params.Slug = &slug
+	}
+
+	if requestPayload.Description != nil {
+		description := utility.TextToLower(*requestPayload.Description)
+		params.Description = &description
+	}
+
+	params.Coordinates, err = geoPoint(requestPayload.Latitude, requestPayload.Longitude)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	params.ClearCoordinates = requestPayload.ClearLocation && params.Coordinates == nil
+
+	if requestPayload.Tags != nil {
+		tags, err := normaliseInventoryTags(*requestPayload.Tags)
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		params.Tags = tags
+	}
+
+	// a listing with variants takes its prices from them
+	if len(inv.Variants) > 0 && (requestPayload.OfferPrice != nil || requestPayload.MinimumPrice != nil) {
+		app.errorJSON(w, errors.New("this inventory has variants, update the price of each variant instead"), nil, http.StatusBadRequest)
+		return
+	}
+
+	// check the minimum price is not more than the offer price after the update
+	offerPrice, minimumPrice := inv.OfferPrice, inv.MinimumPrice
+	if requestPayload.OfferPrice != nil {
+		offerPrice = *requestPayload.OfferPrice
+	}
+	if requestPayload.MinimumPrice != nil {
+		minimumPrice = *requestPayload.MinimumPrice
+	}
+	if offerPrice < 0 || minimumPrice < 0 {
+		app.errorJSON(w, errors.New("price can not be negative"), nil, http.StatusBadRequest)
+		return
+	}
+	if minimumPrice > offerPrice {
+		app.errorJSON(w, fmt.Errorf("minimum price can not be more than offer price: %v", offerPrice), nil, http.StatusBadRequest)
+		return
+	}
+
+	// re-validate the whole hierarchy when any part of it changes, using the
+	// stored value for whatever was not supplied
+	if requestPayload.CategoryId != nil || requestPayload.SubCategoryId != nil ||
+		requestPayload.CountryId != nil || requestPayload.StateId != nil || requestPayload.LgaId != nil {
+
+		categoryID := stringOr(requestPayload.CategoryId, inv.CategoryId)
+		subCategoryID := stringOr(requestPayload.SubCategoryId, inv.SubcategoryId)
+		countryID := stringOr(requestPayload.CountryId, inv.CountryId)
+		stateID := stringOr(requestPayload.StateId, inv.StateId)
+		lgaID := stringOr(requestPayload.LgaId, inv.LgaId)
+
+		taxonomy, err := resolveInventoryTaxonomy(timeoutCtx, app.Repo, categoryID, subCategoryID, countryID, stateID, lgaID)
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusBadRequest)
+			return
+		}
+
+		params.CategoryID = &taxonomy.Category.ID
+		params.SubcategoryID = &taxonomy.Subcategory.ID
+		params.CountryID = &taxonomy.Country.ID
+		params.StateID = &taxonomy.State.ID
+		params.LgaID = &taxonomy.Lga.ID
+		params.CategorySlug = &taxonomy.Category.CategorySlug
+		params.SubcategorySlug = &taxonomy.Subcategory.SubCategorySlug
+		params.CountrySlug = &taxonomy.Country.Code
+		params.StateSlug = &taxonomy.State.StateSlug
+		params.LgaSlug = &taxonomy.Lga.LgaSlug
+	}
+
+	// new metadata, or a new subcategory with other attributes, is checked again
+	if requestPayload.Metadata != nil || params.SubcategoryID != nil {
+		metadata := stringOr(requestPayload.Metadata, inv.Metadata)
+		attributes, err := app.validateInventoryAttributes(timeoutCtx, stringOr(params.SubcategoryID, inv.SubcategoryId), metadata)
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		params.Metadata = requestPayload.Metadata
+		params.Attributes = &attributes
+	}
+
+	// a new name or description is compared with the seller's other listings again
+	var duplicates []data.DuplicateMatch
+	if params.Name != nil || params.Description != nil {
+		hashes, err := app.Repo.GetInventoryImageHashes(timeoutCtx, inv.ID)
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+
+		name := stringOr(params.Name, inv.Name)
+		description := stringOr(params.Description, inv.Description)
+		duplicates, err = app.checkDuplicates(timeoutCtx, inv.UserId, inv.ID, name, description, hashes)
+		if errors.Is(err, errDuplicateListing) {
+			app.errorJSON(w, err, duplicates, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = app.Repo.UpdateInventory(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), params)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.flagDuplicates(timeoutCtx, inv.ID, duplicates)
+
+	updated, err := app.Repo.GetInventoryByIDOrSlug(timeoutCtx, "", inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory updated successfully",
+		Data:       updated,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, payload)
+}
+
+// getOwnedInventory loads a live inventory and checks that userID owns it. The returned
+// status code is meant for the error response when err is not nil.
+func (app *Config) getOwnedInventory(ctx context.Context, inventoryID, userID string) (*data.Inventory, int, error) {
+	inv, err := app.Repo.GetInventoryByID(ctx, inventoryID)
+	if errors.Is(err, data.ErrInventoryNotFound) || errors.Is(err, sql.ErrNoRows) {
+		return nil, http.StatusNotFound, errors.New("no record found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// only the owner can change the listing
+	if inv.UserId != userID {
+		return nil, http.StatusForbidden, errors.New("you are not allowed to modify this inventory")
+	}
+
+	return inv, http.StatusOK, nil
+}
+
+// stringOr returns *value when it is set, otherwise fallback
+func stringOr(value *string, fallback string) string {
+	if value != nil {
+		return *value
+	}
+	return fallback
+}
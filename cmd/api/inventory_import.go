@@ -0,0 +1,663 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"github.com/xuri/excelize/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// maxImportRows caps a single upload so one file can not tie up the importer for hours
+	maxImportRows = 1000
+	// maxImportImageBytes caps each image fetched from a row's image urls
+	maxImportImageBytes = 10 << 20
+)
+
+// importColumns are the recognised header names. name, description, category, subcategory,
+// state, lga, offer_price, quantity, product_purpose and images are required on every row.
+// metadata takes the same json object CreateInventory does.
+var importColumns = []string{
+	"name", "description", "category", "subcategory", "state", "lga",
+	"offer_price", "minimum_price", "security_deposit", "quantity",
+	"product_purpose", "rental_duration", "negotiable", "tags",
+	"condition", "usage_guide", "included", "images", "metadata",
+}
+
+type InventoryImportPayload struct {
+	UserId  string `json:"user_id"`
+	BatchId string `json:"batch_id" binding:"required"`
+}
+
+// importRowReport is the validation outcome of one data row. Row numbers count the header as row 1,
+// the way spreadsheet programs number them.
+type importRowReport struct {
+	Row    int      `json:"row"`
+	Name   string   `json:"name"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type importReport struct {
+	DryRun            bool              `json:"dry_run"`
+	TotalRows         int               `json:"total_rows"`
+	ValidRows         int               `json:"valid_rows"`
+	InvalidRows       int               `json:"invalid_rows"`
+	AvailablePostings int32             `json:"available_postings"`
+	Rows              []importRowReport `json:"rows"`
+	BatchId           string            `json:"batch_id,omitempty"`
+}
+
+// ImportInventories creates listings in bulk from a CSV or XLSX file (multipart field "file").
+// With dry_run=true it only returns the per-row validation report. Otherwise the valid rows are
+// stored as a batch and imported in the background, one creation job per row.
+func (app *Config) ImportInventories(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(20 << 20) // 20 MB
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to parse form"), nil)
+		return
+	}
+
+	userID := r.FormValue("user_id")
+	if userID == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to read file"), nil)
+		return
+	}
+	defer file.Close()
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	isBusiness, err := app.Repo.IsBusinessAccount(timeoutCtx, userID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	if !isBusiness {
+		app.errorJSON(w, errors.New("bulk import is only available to business accounts"), nil, http.StatusForbidden)
+		return
+	}
+
+	records, err := readImportFile(file, header.Filename)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+	if len(records) < 2 {
+		app.errorJSON(w, errors.New("the file has no data rows"), nil)
+		return
+	}
+	if len(records)-1 > maxImportRows {
+		app.errorJSON(w, fmt.Errorf("the file has %d rows, the limit is %d", len(records)-1, maxImportRows), nil)
+		return
+	}
+
+	columns, err := importHeader(records[0])
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	available, err := app.Repo.GetAvailablePostings(timeoutCtx, userID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	report := importReport{DryRun: dryRun, AvailablePostings: available}
+	var rows []data.InventoryImportRow
+
+	for idx, record := range records[1:] {
+		rowNumber := idx + 2
+		if isBlankRecord(record) {
+			continue
+		}
+
+		// each row gets its own deadline, so a long file is not cut short by the rows before it
+		rowCtx, cancelRow := context.WithTimeout(ctx, 10*time.Second)
+		req, imageURLs, errs := app.parseImportRow(rowCtx, userID, columns, record)
+		cancelRow()
+		rowReport := importRowReport{Row: rowNumber, Name: req.Name, Valid: len(errs) == 0, Errors: errs}
+		report.Rows = append(report.Rows, rowReport)
+		report.TotalRows++
+
+		if !rowReport.Valid {
+			report.InvalidRows++
+			continue
+		}
+		report.ValidRows++
+
+		request, err := protojson.Marshal(req)
+		if err != nil {
+			app.errorJSON(w, fmt.Errorf("failed to encode row %d: %w", rowNumber, err), nil, http.StatusInternalServerError)
+			return
+		}
+		rows = append(rows, data.InventoryImportRow{
+			RowNumber: int32(rowNumber),
+			Name:      req.Name,
+			Request:   string(request),
+			ImageURLs: imageURLs,
+		})
+	}
+
+	if dryRun {
+		app.writeJSON(w, http.StatusAccepted, jsonResponse{
+			Error:      false,
+			StatusCode: http.StatusAccepted,
+			Message:    "import validated",
+			Data:       report,
+		})
+		return
+	}
+
+	if report.ValidRows == 0 {
+		app.errorJSON(w, errors.New("no valid rows to import"), report)
+		return
+	}
+	if int32(report.ValidRows) > available {
+		app.errorJSON(w, fmt.Errorf("the file has %d valid rows but your subscription allows %d more postings", report.ValidRows, available), report)
+		return
+	}
+
+	batchCtx, cancelBatch := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelBatch()
+
+	batch, err := app.Repo.CreateImportBatch(batchCtx, &data.InventoryImportBatch{
+		UserID:   userID,
+		Filename: header.Filename,
+		Rows:     rows,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	report.BatchId = batch.ID
+
+	go app.runImportBatch(batch.ID)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    fmt.Sprintf("import of %d rows started, batch ID: %s", report.ValidRows, batch.ID),
+		Data:       report,
+	})
+}
+
+// GetInventoryImport returns a batch with the status of each row to its owner
+func (app *Config) GetInventoryImport(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryImportPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	batch, err := app.Repo.GetImportBatch(timeoutCtx, requestPayload.BatchId)
+	if err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil)
+		return
+	}
+	if batch.UserID != requestPayload.UserId {
+		app.errorJSON(w, errors.New("you are not allowed to view this import"), nil, http.StatusForbidden)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "import retrieved successfully",
+		Data:       batch,
+	})
+}
+
+// runImportBatch submits the rows of a batch one at a time. Each row's images are fetched and
+// handed to the same creation job CreateInventory uses, so a row that fails after submission can
+// be retried through its job.
+func (app *Config) runImportBatch(batchID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+
+	batch, err := app.Repo.GetImportBatch(ctx, batchID)
+	if err != nil {
+		log.Printf("import batch %s: %v", batchID, err)
+		return
+	}
+
+	if err := app.Repo.SetImportBatchStatus(ctx, batchID, data.ImportBatchRunning); err != nil {
+		log.Printf("import batch %s: %v", batchID, err)
+		return
+	}
+
+	for _, row := range batch.Rows {
+		if row.Status != data.ImportRowQueued {
+			continue
+		}
+
+		jobID, err := app.submitImportRow(ctx, batch.UserID, row)
+		if err != nil {
+			log.Printf("import batch %s: row %d failed: %v", batchID, row.RowNumber, err)
+			message := err.Error()
+			if err := app.Repo.SetImportRowResult(ctx, row.ID, data.ImportRowFailed, nil, &message); err != nil {
+				log.Printf("failed to record import row %s result: %v", row.ID, err)
+			}
+			continue
+		}
+
+		if err := app.Repo.SetImportRowResult(ctx, row.ID, data.ImportRowSubmitted, &jobID, nil); err != nil {
+			log.Printf("failed to record import row %s result: %v", row.ID, err)
+		}
+
+		// run the job before the next row so the posting quota is spent one listing at a time
+		app.runInventoryJob(jobID, data.ChangeSourceSystem)
+	}
+
+	if err := app.Repo.SetImportBatchStatus(ctx, batchID, data.ImportBatchCompleted); err != nil {
+		log.Printf("import batch %s: %v", batchID, err)
+	}
+}
+
+// resumeImportBatches carries on with the batches a restart cut short, one after another. Rows that
+// were submitted are left to their creation jobs; queued rows are submitted as usual.
+func (app *Config) resumeImportBatches() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ids, err := app.Repo.GetUnfinishedImportBatches(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("failed to find unfinished import batches: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		log.Printf("resuming import batch %s", id)
+		app.runImportBatch(id)
+	}
+}
+
+// submitImportRow fetches a row's images and queues its creation job
+func (app *Config) submitImportRow(ctx context.Context, userID string, row data.InventoryImportRow) (string, error) {
+	// the quota may have been spent elsewhere since the file was validated; checked before the
+	// images are downloaded, queueInventoryJob still takes the posting atomically
+	available, err := app.Repo.GetAvailablePostings(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if available <= 0 {
+		return "", errors.New("no postings left on your subscription")
+	}
+
+	var req inventory.CreateInventoryRequest
+	if err := protojson.Unmarshal([]byte(row.Request), &req); err != nil {
+		return "", fmt.Errorf("failed to decode row: %w", err)
+	}
+
+	for idx, imageURL := range row.ImageURLs {
+		content, err := app.fetchImportImage(ctx, imageURL)
+		if err != nil {
+			return "", fmt.Errorf("image %s: %w", imageURL, err)
+		}
+		if idx == 0 {
+			req.PrimaryImage = &inventory.ImageData{ImageData: content}
+			continue
+		}
+		req.Images = append(req.Images, &inventory.ImageData{ImageData: content})
+	}
+
+	job, err := app.queueInventoryJob(ctx, &req)
+	if err != nil {
+		return "", err
+	}
+
+	return job.ID, nil
+}
+
+// errImageDownload is what a row reports for any failed download; the details are only logged, so
+// imports can not be used to read responses from elsewhere
+var errImageDownload = errors.New("could not download the image")
+
+// nonPublicPrefixes are the special-purpose ranges the netip predicates do not cover
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, which can reach any IPv4 address
+}
+
+// publicAddress reports whether ip is on the public internet. Loopback, private, link-local (which
+// holds the cloud metadata endpoint) and other special-purpose addresses are not.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkImportURL allows only absolute http and https urls
+func checkImportURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("not an http(s) url")
+	}
+	return nil
+}
+
+// newImportClient returns the client image urls of imports are fetched with. Every connection,
+// including those of redirects, is checked after DNS resolution and refused unless it goes to a
+// public address, and no proxy is used so the check sees the real destination.
+func newImportClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddress(addr.Addr()) {
+				return fmt.Errorf("refusing to connect to %s", address)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 20 * time.Second,
+			MaxIdleConnsPerHost:   2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return checkImportURL(req.URL)
+		},
+	}
+}
+
+// fetchImportImage downloads one image url and checks it the way an uploaded image is checked
+func (app *Config) fetchImportImage(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.New("not an http(s) url")
+	}
+	if err := checkImportURL(u); err != nil {
+		return nil, err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.New("not an http(s) url")
+	}
+
+	resp, err := app.ImportClient.Do(req)
+	if err != nil {
+		log.Printf("import image %s: %v", rawURL, err)
+		return nil, errImageDownload
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("import image %s: download failed with status %d", rawURL, resp.StatusCode)
+		return nil, errImageDownload
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxImportImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxImportImageBytes {
+		return nil, fmt.Errorf("image is larger than %d MB", maxImportImageBytes>>20)
+	}
+
+	if _, err := media.CheckImage(content); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// parseImportRow turns a record into a CreateInventoryRequest without images and checks it with
+// the rules CreateInventory applies. Every problem with the row is reported, not just the first.
+func (app *Config) parseImportRow(ctx context.Context, userID string, columns map[string]int, record []string) (*inventory.CreateInventoryRequest, []string, []string) {
+	field := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	var errs []string
+	number := func(name string, required bool) float64 {
+		value := field(name)
+		if value == "" {
+			if required {
+				errs = append(errs, name+" is required")
+			}
+			return 0
+		}
+		n, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %q is not a number", name, value))
+			return 0
+		}
+		if n < 0 {
+			errs = append(errs, name+" can not be negative")
+		}
+		return n
+	}
+
+	req := &inventory.CreateInventoryRequest{
+		UserId:          userID,
+		Name:            field("name"),
+		Description:     field("description"),
+		OfferPrice:      number("offer_price", true),
+		MinimumPrice:    number("minimum_price", false),
+		SecurityDeposit: number("security_deposit", false),
+		Quantity:        number("quantity", true),
+		ProductPurpose:  strings.ToLower(field("product_purpose")),
+		RentalDuration:  strings.ToLower(field("rental_duration")),
+		Negotiable:      strings.ToLower(field("negotiable")),
+		Tags:            field("tags"),
+		Condition:       field("condition"),
+		UsageGuide:      field("usage_guide"),
+		Included:        field("included"),
+		Metadata:        field("metadata"),
+		IsAvailable:     "yes",
+	}
+
+	if req.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if req.Description == "" {
+		errs = append(errs, "description is required")
+	}
+	if req.MinimumPrice > req.OfferPrice {
+		errs = append(errs, "minimum_price can not be more than offer_price")
+	}
+	if req.Quantity == 0 && field("quantity") != "" {
+		errs = append(errs, "quantity must be more than zero")
+	}
+
+	switch req.ProductPurpose {
+	case "rental":
+		if req.RentalDuration == "" {
+			errs = append(errs, "rental_duration is required for rentals")
+		}
+	case "sale":
+		req.RentalDuration = ""
+	case "":
+		errs = append(errs, "product_purpose is required")
+	default:
+		errs = append(errs, fmt.Sprintf("product_purpose %q must be rental or sale", req.ProductPurpose))
+	}
+
+	switch req.Negotiable {
+	case "":
+		req.Negotiable = "no"
+	case "yes", "no":
+	default:
+		errs = append(errs, "negotiable must be yes or no")
+	}
+
+	if _, err := normaliseInventoryTags(req.Tags); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	imageURLs := strings.FieldsFunc(field("images"), func(r rune) bool {
+		return r == '|' || r == ' ' || r == '\n' || r == '\t'
+	})
+	if len(imageURLs) == 0 {
+		errs = append(errs, "at least one image url is required")
+	}
+	for _, imageURL := range imageURLs {
+		if u, err := url.Parse(imageURL); err != nil || checkImportURL(u) != nil {
+			errs = append(errs, fmt.Sprintf("image %q is not an http(s) url", imageURL))
+		}
+	}
+
+	category, subcategory, state, lga := field("category"), field("subcategory"), field("state"), field("lga")
+	if category == "" || subcategory == "" || state == "" || lga == "" {
+		errs = append(errs, "category, subcategory, state and lga are required")
+		return req, imageURLs, errs
+	}
+
+	ids, err := app.Repo.ResolveTaxonomySlugs(ctx, category, subcategory, state, lga)
+	if err != nil {
+		errs = append(errs, err.Error())
+		return req, imageURLs, errs
+	}
+	req.CategoryId, req.SubCategoryId = ids.CategoryID, ids.SubcategoryID
+	req.CountryId, req.StateId, req.LgaId = ids.CountryID, ids.StateID, ids.LgaID
+
+	// the same hierarchy check CreateInventory runs
+	if _, err := resolveInventoryTaxonomy(ctx, app.Repo, req.CategoryId, req.SubCategoryId, req.CountryId, req.StateId, req.LgaId); err != nil {
+		errs = append(errs, err.Error())
+		return req, imageURLs, errs
+	}
+
+	// metadata must fit the subcategory's attributes
+	if _, err := app.validateInventoryAttributes(ctx, req.SubCategoryId, req.Metadata); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	return req, imageURLs, errs
+}
+
+// readImportFile reads every record of a CSV file or of the first sheet of an XLSX workbook
+func readImportFile(file io.Reader, filename string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv file: %w", err)
+		}
+		// drop the byte order mark spreadsheet programs put at the start of a csv export
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+
+	case ".xlsx":
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		workbook, err := excelize.OpenReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid xlsx file: %w", err)
+		}
+		defer workbook.Close()
+
+		sheets := workbook.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("the workbook has no sheets")
+		}
+		records, err := workbook.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid xlsx file: %w", err)
+		}
+		return records, nil
+	}
+
+	return nil, errors.New("the file must be a .csv or .xlsx")
+}
+
+// importHeader maps the header row to column positions and rejects unknown or missing columns
+func importHeader(header []string) (map[string]int, error) {
+	known := make(map[string]bool, len(importColumns))
+	for _, name := range importColumns {
+		known[name] = true
+	}
+
+	columns := make(map[string]int, len(header))
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = idx
+	}
+
+	for _, name := range []string{"name", "description", "category", "subcategory", "state", "lga", "offer_price", "quantity", "product_purpose", "images"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	return columns, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func TestParseImportRow(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	repo.Attributes = testAttributes
+	app := &Config{Repo: repo}
+
+	header := []string{"name", "description", "category", "subcategory", "state", "lga", "offer_price", "quantity", "product_purpose", "rental_duration", "tags", "images", "metadata"}
+	columns, err := importHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := func(tags, metadata string) []string {
+		return []string{"Speedboat", "A fast boat", "vehicles", "boats", "lagos", "ikeja", "25,000", "1", "Rental", "daily", tags, "https://example.com/boat.jpg", metadata}
+	}
+
+	tests := []struct {
+		name   string
+		record []string
+		errs   []string
+	}{
+		{"valid", row("#Boat, speed", `{"bedrooms": 2, "fuel": "diesel"}`), nil},
+		{"too many tags", row("a b c d e f g h i j k", `{"bedrooms": 2}`), []string{"a listing can have at most 10 tags"}},
+		{"metadata not an object", row("boat", `[1]`), []string{"metadata must be a json object"}},
+		{"required attribute missing", row("boat", ""), []string{"Bedrooms is required"}},
+	}
+
+	for _, tt := range tests {
+		req, _, errs := app.parseImportRow(context.Background(), "7a937e9d-1dc2-4e6d-ba38-d1648b05730c", columns, tt.record)
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s: got errors %q; want %q", tt.name, errs, tt.errs)
+		}
+		if req.Metadata != strings.TrimSpace(tt.record[len(tt.record)-1]) {
+			t.Errorf("%s: metadata %q was not taken from the row", tt.name, req.Metadata)
+		}
+	}
+}
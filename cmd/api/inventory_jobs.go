@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
+	"github.com/obynonwane/inventory-service/utility"
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type InventoryJobPayload struct {
+	UserId string `json:"user_id"`
+	JobId  string `json:"job_id" binding:"required"`
+}
+
+// queueInventoryJob persists a CreateInventory request. The listing fields are stored as JSON
+// and the image bytes go to their own rows so a retry never needs the client to send them again.
+func (app *Config) queueInventoryJob(ctx context.Context, req *inventory.CreateInventoryRequest) (*data.InventoryJob, error) {
+	stripped := proto.Clone(req).(*inventory.CreateInventoryRequest)
+	stripped.Images = nil
+	stripped.PrimaryImage = nil
+
+	request, err := protojson.Marshal(stripped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inventory request: %w", err)
+	}
+
+	job := &data.InventoryJob{
+		UserID:  req.UserId,
+		Request: string(request),
+	}
+
+	// the image type is taken from the bytes, not from what the client claimed
+	primaryType, _ := media.SniffImageType(req.PrimaryImage.ImageData)
+	job.Images = append(job.Images, data.InventoryJobImage{
+		IsPrimary: true,
+		ImageType: primaryType,
+		ImageData: req.PrimaryImage.ImageData,
+	})
+	for idx, img := range req.Images {
+		imageType, _ := media.SniffImageType(img.ImageData)
+		job.Images = append(job.Images, data.InventoryJobImage{
+			Position:  int32(idx),
+			ImageType: imageType,
+			ImageData: img.ImageData,
+		})
+	}
+
+	return app.Repo.CreateInventoryJob(ctx, job)
+}
+
+// runInventoryJob uploads the job's outstanding images and creates the inventory. Every step is
+// recorded on the job so the owner can see where it stopped. source is where the job came from
+// and is kept in the listing's history.
+func (app *Config) runInventoryJob(jobID, source string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	fail := func(err error) {
+		log.Printf("inventory job %s failed: %v", jobID, err)
+		if err := app.Repo.FailInventoryJob(ctx, jobID, err.Error()); err != nil {
+			log.Printf("failed to record inventory job %s failure: %v", jobID, err)
+		}
+	}
+
+	job, err := app.Repo.GetInventoryJob(ctx, jobID)
+	if err != nil {
+		log.Printf("inventory job %s: %v", jobID, err)
+		return
+	}
+	ctx = data.WithChangeActor(ctx, job.UserID, source)
+
+	var req inventory.CreateInventoryRequest
+	if err := protojson.Unmarshal([]byte(job.Request), &req); err != nil {
+		fail(fmt.Errorf("failed to decode inventory request: %w", err))
+		return
+	}
+
+	if err := app.Repo.SetInventoryJobStatus(ctx, jobID, data.InventoryJobUploading); err != nil {
+		fail(err)
+		return
+	}
+
+	if err := app.uploadInventoryJobImages(ctx, job); err != nil {
+		fail(err)
+		return
+	}
+
+	if err := app.Repo.SetInventoryJobStatus(ctx, jobID, data.InventoryJobSaving); err != nil {
+		fail(err)
+		return
+	}
+
+	// reload to pick up the urls recorded by this and any earlier attempt
+	job, err = app.Repo.GetInventoryJob(ctx, jobID)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var primaryImage data.ImageURLs
+	var images []data.ImageURLs
+	var hashes []int64
+	for _, img := range job.Images {
+		if img.URL == nil {
+			fail(fmt.Errorf("image %s has no uploaded url", img.ID))
+			return
+		}
+		// images uploaded before derivatives were made have only the original, which stands in for them
+		urls := data.ImageURLs{
+			Thumbnail: stringOr(img.ThumbnailURL, *img.URL),
+			Card:      stringOr(img.CardURL, *img.URL),
+			Full:      *img.URL,
+			Hash:      img.Hash,
+		}
+		if img.Hash != nil {
+			hashes = append(hashes, *img.Hash)
+		}
+		if img.IsPrimary {
+			primaryImage = urls
+			continue
+		}
+		images = append(images, urls)
+	}
+
+	// the taxonomy may have changed since the job was queued
+	taxonomy, err := resolveInventoryTaxonomy(ctx, app.Repo, req.CategoryId, req.SubCategoryId, req.CountryId, req.StateId, req.LgaId)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// checked here as well as on request so imports and retries are covered
+	matches, err := app.checkDuplicates(ctx, req.UserId, "", req.Name, req.Description, hashes)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// the subcategory's attributes may have changed since the job was queued
+	attributes, err := app.validateInventoryAttributes(ctx, req.SubCategoryId, req.Metadata)
+	if err != nil {
+		fail(fmt.Errorf("invalid metadata: %w", err))
+		return
+	}
+
+	tags, err := normaliseInventoryTags(req.Tags)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	expiresAt, err := app.listingExpiry(ctx, req.UserId, time.Now())
+	if err != nil {
+		fail(fmt.Errorf("failed to work out listing expiry: %w", err))
+		return
+	}
+
+	tx, err := app.Repo.BeginTransaction(ctx)
+	if err != nil {
+		fail(fmt.Errorf("failed to begin transaction: %w", err))
+		return
+	}
+
+	slug, ulid := utility.GenerateSlug(req.Name)
+	created, err := app.Repo.CreateInventory(&data.CreateInventoryParams{
+		Tx:              tx,
+		Ctx:             ctx,
+		Name:            req.Name,
+		Description:     utility.TextToLower(req.Description),
+		UserID:          req.UserId,
+		CategoryID:      req.CategoryId,
+		SubcategoryID:   req.SubCategoryId,
+		CountryID:       req.CountryId,
+		StateID:         req.StateId,
+		LgaID:           req.LgaId,
+		Slug:            slug,
+		ULID:            ulid,
+		StateSlug:       taxonomy.State.StateSlug,
+		CountrySlug:     taxonomy.Country.Code,
+		LgaSlug:         taxonomy.Lga.LgaSlug,
+		CategorySlug:    taxonomy.Category.CategorySlug,
+		SubcategorySlug: taxonomy.Subcategory.SubCategorySlug,
+		OfferPrice:      req.OfferPrice,
+		MinimumPrice:    req.MinimumPrice,
+		Images:          images,
+
+		ProductPurpose:  req.ProductPurpose,
+		Quantity:        req.Quantity,
+		IsAvailable:     req.IsAvailable,
+		RentalDuration:  req.RentalDuration,
+		SecurityDeposit: req.SecurityDeposit,
+		Tags:            tags,
+		Metadata:        req.Metadata,
+		Attributes:      attributes,
+		Negotiable:      req.Negotiable,
+		PrimaryImage:    primaryImage,
+		Included:        req.Included,
+		UsageGuide:      req.UsageGuide,
+		Condition:       req.Condition,
+		ExpiresAt:       expiresAt,
+		JobID:           jobID,
+	})
+	if err != nil {
+		tx.Rollback()
+		fail(fmt.Errorf("error creating inventory: %w", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		fail(fmt.Errorf("failed to commit inventory: %w", err))
+		return
+	}
+
+	app.flagDuplicates(ctx, created.ID, matches)
+}
+
+// resumeInventoryJobs picks up the jobs a restart cut short and runs them again. Uploads are
+// recorded image by image, and a job is completed in the transaction that creates its listing, so
+// a job still marked as saving has not created anything yet.
+func (app *Config) resumeInventoryJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	jobs, err := app.Repo.GetUnfinishedInventoryJobs(ctx)
+	if err != nil {
+		log.Printf("failed to find unfinished inventory jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		log.Printf("resuming inventory job %s", job.ID)
+		go app.runInventoryJob(job.ID, data.ChangeSourceSystem)
+	}
+}
+
+// uploadInventoryJobImages uploads every image that has not been uploaded yet and records
+// the outcome per image. It returns an error when any of them failed.
+func (app *Config) uploadInventoryJobImages(ctx context.Context, job *data.InventoryJob) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+
+	for _, image := range job.Images {
+		if image.Status == data.InventoryJobImageUploaded {
+			continue
+		}
+
+		wg.Add(1)
+		go func(img data.InventoryJobImage) {
+			defer wg.Done()
+
+			urls, err := app.uploadInventoryJobImage(ctx, img)
+			if err != nil {
+				log.Printf("inventory job %s: image %s failed to upload: %v", job.ID, img.ID, err)
+
+				mu.Lock()
+				failed++
+				mu.Unlock()
+
+				message := err.Error()
+				if err := app.Repo.SetInventoryJobImageResult(ctx, img.ID, data.InventoryJobImageFailed, nil, &message); err != nil {
+					log.Printf("failed to record image %s result: %v", img.ID, err)
+				}
+				return
+			}
+
+			if err := app.Repo.SetInventoryJobImageResult(ctx, img.ID, data.InventoryJobImageUploaded, urls, nil); err != nil {
+				log.Printf("failed to record image %s result: %v", img.ID, err)
+			}
+		}(image)
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d image(s) failed to upload", failed)
+	}
+
+	return nil
+}
+
+// uploadInventoryJobImage processes one stored image and uploads its derivatives
+func (app *Config) uploadInventoryJobImage(ctx context.Context, img data.InventoryJobImage) (*data.ImageURLs, error) {
+	imageData, err := app.Repo.GetInventoryJobImageData(ctx, img.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.uploadImage(ctx, "rentalsolution/inventories", imageData)
+}
+
+// GetInventoryJob returns the status of an inventory creation job to its owner
+func (app *Config) GetInventoryJob(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryJobPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	job, status, err := app.getOwnedInventoryJob(timeoutCtx, requestPayload.JobId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "job retrieved successfully",
+		Data:       job,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, payload)
+}
+
+// RetryInventoryJob re-runs a failed job from the images it already holds. The posting refunded
+// when the job failed is taken again.
+func (app *Config) RetryInventoryJob(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryJobPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	job, status, err := app.getOwnedInventoryJob(timeoutCtx, requestPayload.JobId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	if err := app.Repo.RetryInventoryJob(timeoutCtx, job.ID); err != nil {
+		if errors.Is(err, data.ErrPostingQuotaExceeded) {
+			app.errorJSON(w, err, nil, http.StatusForbidden)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+
+	go app.runInventoryJob(job.ID, data.ChangeSourceHTTP)
+
+	payload := jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "job queued for retry",
+		Data:       map[string]string{"job_id": job.ID},
+	}
+
+	app.writeJSON(w, http.StatusAccepted, payload)
+}
+
+// getOwnedInventoryJob loads a creation job and checks that userID queued it. The returned
+// status code is meant for the error response when err is not nil.
+func (app *Config) getOwnedInventoryJob(ctx context.Context, jobID, userID string) (*data.InventoryJob, int, error) {
+	job, err := app.Repo.GetInventoryJob(ctx, jobID)
+	if errors.Is(err, data.ErrInventoryJobNotFound) {
+		return nil, http.StatusNotFound, errors.New("no record found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if job.UserID != userID {
+		return nil, http.StatusForbidden, errors.New("you are not allowed to view this job")
+	}
+
+	return job, http.StatusOK, nil
+}
+
+type PostingQuotaPayload struct {
+	UserId string `json:"user_id"`
+}
+
+// PostingQuota shows how many more listings the user's subscription allows
+func (app *Config) PostingQuota(w http.ResponseWriter, r *http.Request) {
+	var requestPayload PostingQuotaPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	quota, err := app.Repo.GetPostingQuota(timeoutCtx, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "posting quota retrieved successfully",
+		Data:       quota,
+	})
+}
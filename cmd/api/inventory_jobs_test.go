@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const jobOwner = "7a937e9d-1dc2-4e6d-ba38-d1648b05730c"
+
+// waitForJobStatus waits for a job run in the background to reach status
+func waitForJobStatus(t *testing.T, repo *data.PostgresTestRepository, jobID, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := repo.GetInventoryJob(context.Background(), jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s; want %s", jobID, job.Status, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func availablePostings(t *testing.T, repo *data.PostgresTestRepository) int32 {
+	t.Helper()
+	available, err := repo.GetAvailablePostings(context.Background(), jobOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return available
+}
+
+// a job holds one posting while it is queued or running and gives it back once when it fails
+func TestInventoryJob_Postings(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	repo.Postings = 2
+	app := &Config{Repo: repo, Media: media.NewMemoryStore()}
+
+	// bytes that are no image make the upload, and so the job, fail
+	job, err := app.queueInventoryJob(context.Background(), &inventory.CreateInventoryRequest{
+		UserId:       jobOwner,
+		Name:         "Speedboat",
+		PrimaryImage: &inventory.ImageData{ImageData: []byte("not an image")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := availablePostings(t, repo); got != 1 {
+		t.Fatalf("after queueing: %d postings left; want 1", got)
+	}
+
+	app.runInventoryJob(job.ID, data.ChangeSourceSystem)
+	waitForJobStatus(t, repo, job.ID, data.InventoryJobFailed)
+	if got := availablePostings(t, repo); got != 2 {
+		t.Fatalf("after the job failed: %d postings left; want 2", got)
+	}
+
+	// failing it again, as a restart might, does not refund the posting twice
+	if err := repo.FailInventoryJob(context.Background(), job.ID, "interrupted"); err != nil {
+		t.Fatal(err)
+	}
+	if got := availablePostings(t, repo); got != 2 {
+		t.Fatalf("after failing twice: %d postings left; want 2", got)
+	}
+
+	code, _ := postJSON(t, app.RetryInventoryJob, `{"user_id": "`+jobOwner+`", "job_id": "`+job.ID+`"}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("retry: got status %d; want %d", code, http.StatusAccepted)
+	}
+	// the retry runs in the background and fails again, refunding the posting it took
+	waitForJobStatus(t, repo, job.ID, data.InventoryJobFailed)
+	if got := availablePostings(t, repo); got != 2 {
+		t.Fatalf("after the retry failed: %d postings left; want 2", got)
+	}
+
+	repo.Postings = 0
+	code, _ = postJSON(t, app.RetryInventoryJob, `{"user_id": "`+jobOwner+`", "job_id": "`+job.ID+`"}`)
+	if code != http.StatusForbidden {
+		t.Errorf("retry without postings: got status %d; want %d", code, http.StatusForbidden)
+	}
+
+	for _, tt := range []struct {
+		name, body string
+		want       int
+	}{
+		{"someone else's job", `{"user_id": "01197718-a7a9-4af8-9870-661e17cd0d81", "job_id": "` + job.ID + `"}`, http.StatusForbidden},
+		{"unknown job", `{"user_id": "` + jobOwner + `", "job_id": "job-404"}`, http.StatusNotFound},
+	} {
+		if code, _ := postJSON(t, app.RetryInventoryJob, tt.body); code != tt.want {
+			t.Errorf("retry %s: got status %d; want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestCreateInventory_NoPostingsLeft(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	server := &InventoryServer{Models: repo, App: &Config{Repo: repo}}
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ids are those of the test repository's taxonomy fixtures
+	_, err := server.CreateInventory(context.Background(), &inventory.CreateInventoryRequest{
+		UserId:        jobOwner,
+		Name:          "Speedboat",
+		Description:   "A fast boat",
+		CategoryId:    "9c47613d-0beb-4f46-91e0-14ad5fb88548",
+		SubCategoryId: "1bd9364a-c5ed-4d13-b32c-3dbab9f64972",
+		CountryId:     "d922a911-2d03-4479-9170-4bd44e68b5f2",
+		StateId:       "bfd2d6b0-2ed3-4274-9950-a26db49f606a",
+		LgaId:         "bc82cf4e-7809-484f-aa43-8a988922bbfc",
+		PrimaryImage:  &inventory.ImageData{ImageData: img.Bytes()},
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v; want %s", err, codes.ResourceExhausted)
+	}
+	if len(repo.Jobs) != 0 {
+		t.Errorf("a job was queued without a posting: %v", repo.Jobs)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
 )
 
 const (
@@ -25,8 +26,14 @@ const (
 var counts int64
 
 type Config struct {
-	Repo   data.Repository
-	Client *http.Client
+	Repo         data.Repository
+	Client       *http.Client
+	ImportClient *http.Client
+	Media        media.MediaStore
+	Duplicates   DuplicateConfig
+	Purge        PurgeConfig
+	Expiry       ExpiryConfig
+	Site         SiteConfig
 }
 
 func main() {
@@ -39,10 +46,42 @@ func main() {
 		log.Panic("can't connect to Postgres")
 	}
 
+	// media backend is chosen by MEDIA_BACKEND
+	store, err := media.NewFromEnv()
+	if err != nil {
+		log.Panic("can't initialize media storage:", err)
+	}
+
+	duplicates, err := duplicateConfigFromEnv()
+	if err != nil {
+		log.Panic("invalid duplicate listing settings:", err)
+	}
+
+	purge, err := purgeConfigFromEnv()
+	if err != nil {
+		log.Panic("invalid inventory purge settings:", err)
+	}
+
+	expiry, err := expiryConfigFromEnv()
+	if err != nil {
+		log.Panic("invalid listing expiry settings:", err)
+	}
+
+	site, err := siteConfigFromEnv()
+	if err != nil {
+		log.Panic("invalid site settings:", err)
+	}
+
 	// Setup config with an initialized Repo
 	app := Config{
-		Repo:   data.NewPostgresRepository(conn),
-		Client: &http.Client{},
+		Repo:         data.NewPostgresRepository(conn),
+		Client:       &http.Client{},
+		ImportClient: newImportClient(),
+		Media:        store,
+		Duplicates:   duplicates,
+		Purge:        purge,
+		Expiry:       expiry,
+		Site:         site,
 	}
 
 	// Pass the initialized Config to RPCServer
@@ -51,7 +90,7 @@ func main() {
 	}
 
 	// Register RPC server: tell teh app e will be accepting rpc request
-	err := rpc.Register(rpcServer)
+	err = rpc.Register(rpcServer)
 	if err != nil {
 		log.Panic("failed to register RPC server:", err)
 	}
@@ -59,6 +98,15 @@ func main() {
 	//register gRPC: and start listening
 	go app.grpcListen()
 
+	// deleted listings are purged once their restore window has passed
+	go app.runInventoryPurger()
+	go app.runExpiryReminders()
+	go app.runInventoryEventCleanup()
+
+	// creation jobs and import batches cut short by a restart are picked up again
+	go app.resumeInventoryJobs()
+	go app.resumeImportBatches()
+
 	// define http server
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", webPort),
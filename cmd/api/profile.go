@@ -6,11 +6,9 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/cloudinary/cloudinary-go"
-	"github.com/cloudinary/cloudinary-go/api/uploader"
+	"github.com/obynonwane/inventory-service/media"
 )
 
 func (app *Config) UploadProfileImage(w http.ResponseWriter, r *http.Request) {
@@ -51,37 +49,20 @@ func (app *Config) UploadProfileImage(w http.ResponseWriter, r *http.Request) {
 	log.Println(userID)
 	log.Println(file)
 
-	// Increase the timeout duration for Cloudinary initialization and image uploads
-	cld, err := cloudinary.NewFromParams(
-		os.Getenv("CLOUDINARY_CLOUD_NAME"),
-		os.Getenv("CLOUDINARY_API_KEY"),
-		os.Getenv("CLOUDINARY_API_SECRET"),
-	)
-	if err != nil {
-		if err != nil {
-			app.errorJSON(w, err, nil, http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Generate unique filename (without extension)
-	uniqueFilename := app.generateUniqueFilename()
-
 	uploadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute) // Increased timeout for image upload
 	defer cancel()
 
-	// Upload directly from byte stream to Cloudinary
-	uploadResult, err := cld.Upload.Upload(uploadCtx, bytes.NewReader(buf.Bytes()), uploader.UploadParams{
-		Folder:   "rentalsolution/profile_images",
-		PublicID: uniqueFilename, // Pass filename without extension
-	})
-	if err != nil {
-		log.Printf("Error uploading to Cloudinary: %v", err)
+	if _, err := media.CheckImage(buf.Bytes()); err != nil {
+		app.errorJSON(w, err, nil)
 		return
 	}
 
-	// log.Println(uploadResult.SecureURL)
-	imageUrl := uploadResult.SecureURL
+	imageUrl, err := app.uploadFullImage(uploadCtx, "rentalsolution/profile_images", buf.Bytes())
+	if err != nil {
+		log.Printf("Error uploading image: %v", err)
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
 
 	err = app.Repo.UploadProfileImage(timeoutCtx, imageUrl, userID)
 	if err != nil {
@@ -133,36 +114,20 @@ func (app *Config) UploadBanner(w http.ResponseWriter, r *http.Request) {
 	log.Println(userID)
 	log.Println(file)
 
-	// Increase the timeout duration for Cloudinary initialization and image uploads
-	cld, err := cloudinary.NewFromParams(
-		os.Getenv("CLOUDINARY_CLOUD_NAME"),
-		os.Getenv("CLOUDINARY_API_KEY"),
-		os.Getenv("CLOUDINARY_API_SECRET"),
-	)
-	if err != nil {
-		if err != nil {
-			app.errorJSON(w, err, nil, http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Generate unique filename (without extension)
-	uniqueFilename := app.generateUniqueFilename()
-
 	uploadCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute) // Increased timeout for image upload
 	defer cancel()
 
-	// Upload directly from byte stream to Cloudinary
-	uploadResult, err := cld.Upload.Upload(uploadCtx, bytes.NewReader(buf.Bytes()), uploader.UploadParams{
-		Folder:   "rentalsolution/business_banners",
-		PublicID: uniqueFilename, // Pass filename without extension
-	})
-	if err != nil {
-		log.Printf("Error uploading to Cloudinary: %v", err)
+	if _, err := media.CheckImage(buf.Bytes()); err != nil {
+		app.errorJSON(w, err, nil)
 		return
 	}
 
-	imageUrl := uploadResult.SecureURL
+	imageUrl, err := app.uploadFullImage(uploadCtx, "rentalsolution/business_banners", buf.Bytes())
+	if err != nil {
+		log.Printf("Error uploading image: %v", err)
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
 
 	err = app.Repo.UploadShopBanner(timeoutCtx, imageUrl, userID)
 	if err != nil {
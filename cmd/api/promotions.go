@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// maxPromotionDays is the longest promotion that can be bought at once
+const maxPromotionDays = 90
+
+type PromoteInventoryPayload struct {
+	UserId           string `json:"user_id"`
+	InventoryId      string `json:"inventory_id"`
+	Scope            string `json:"scope"`             // category, state or homepage
+	Days             int    `json:"days"`              // how long the promotion runs
+	StartsAt         string `json:"starts_at"`         // RFC 3339; empty starts now
+	PaymentReference string `json:"payment_reference"` // the payment for the promotion
+}
+
+// PromoteInventory records a promotion the owner has paid for. The price comes from the
+// placement's daily rate, and the reference must be a successful payment of the owner, recorded by
+// the payments service, that covers it. A reference can only pay for one promotion.
+func (app *Config) PromoteInventory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload PromoteInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	switch requestPayload.Scope {
+	case data.PromotionScopeCategory, data.PromotionScopeState, data.PromotionScopeHomepage:
+	default:
+		app.errorJSON(w, errors.New("scope must be category, state or homepage"), nil)
+		return
+	}
+	if requestPayload.Days < 1 || requestPayload.Days > maxPromotionDays {
+		app.errorJSON(w, errors.New("days must be between 1 and 90"), nil)
+		return
+	}
+	if requestPayload.PaymentReference == "" {
+		app.errorJSON(w, errors.New("missing payment_reference"), nil)
+		return
+	}
+
+	startsAt := time.Now()
+	if requestPayload.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, requestPayload.StartsAt)
+		if err != nil {
+			app.errorJSON(w, errors.New("starts_at must be a time like 2025-01-31T09:00:00Z"), nil)
+			return
+		}
+		if t.After(startsAt) {
+			startsAt = t
+		}
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	price, err := app.Repo.GetPromotionPrice(timeoutCtx, requestPayload.Scope, requestPayload.Days)
+	if err != nil {
+		if errors.Is(err, data.ErrPromotionNotForSale) {
+			app.errorJSON(w, err, nil)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	promotion, err := app.Repo.CreateInventoryPromotion(timeoutCtx, &data.InventoryPromotion{
+		InventoryID:      inv.ID,
+		UserID:           inv.UserId,
+		Scope:            requestPayload.Scope,
+		StartsAt:         startsAt,
+		EndsAt:           startsAt.AddDate(0, 0, requestPayload.Days),
+		Amount:           price,
+		PaymentReference: requestPayload.PaymentReference,
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrPromotionOverlaps) || errors.Is(err, data.ErrPaymentReferenceUsed) {
+			app.errorJSON(w, err, nil, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, data.ErrPaymentNotVerified) || errors.Is(err, data.ErrPaymentTooSmall) {
+			app.errorJSON(w, err, nil, http.StatusPaymentRequired)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory promoted successfully",
+		Data:       promotion,
+	})
+}
+
+type PromotionPricePayload struct {
+	Scope string `json:"scope"`
+	Days  int    `json:"days"`
+}
+
+// PromotionPrice quotes a promotion, so the client knows what to charge before calling PromoteInventory
+func (app *Config) PromotionPrice(w http.ResponseWriter, r *http.Request) {
+	var requestPayload PromotionPricePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.Days < 1 || requestPayload.Days > maxPromotionDays {
+		app.errorJSON(w, errors.New("days must be between 1 and 90"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	price, err := app.Repo.GetPromotionPrice(timeoutCtx, requestPayload.Scope, requestPayload.Days)
+	if err != nil {
+		if errors.Is(err, data.ErrPromotionNotForSale) {
+			app.errorJSON(w, err, nil)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "promotion price retrieved successfully",
+		Data:       map[string]any{"scope": requestPayload.Scope, "days": requestPayload.Days, "amount": price},
+	})
+}
+
+type InventoryPromotionsPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+}
+
+// InventoryPromotions lists a listing's promotions with their impressions and clicks
+func (app *Config) InventoryPromotions(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryPromotionsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	promotions, err := app.Repo.GetInventoryPromotions(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "promotions retrieved successfully",
+		Data:       promotions,
+	})
+}
+
+type PromotionClickPayload struct {
+	InventoryId string `json:"inventory_id"`
+	Scope       string `json:"scope"`      // the placement that was clicked; empty for any
+	VisitorId   string `json:"visitor_id"` // the signed in user, or an anonymous id the client keeps
+	UserAgent   string `json:"user_agent"` // of the visitor's browser; the request's own when empty
+}
+
+// PromotionClick counts a click on a promoted placement. It is called by the client when a
+// promoted search result is opened. Like InventoryEvents, bots are ignored and a visitor counts
+// once a day per promotion.
+func (app *Config) PromotionClick(w http.ResponseWriter, r *http.Request) {
+	var requestPayload PromotionClickPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if !uuidPattern.MatchString(requestPayload.InventoryId) {
+		app.errorJSON(w, errors.New("inventory_id must be an inventory id"), nil)
+		return
+	}
+	if requestPayload.VisitorId == "" {
+		app.errorJSON(w, errors.New("missing visitor_id"), nil)
+		return
+	}
+
+	userAgent := requestPayload.UserAgent
+	if userAgent == "" {
+		userAgent = r.UserAgent()
+	}
+	if botUserAgentPattern.MatchString(userAgent) {
+		app.writeJSON(w, http.StatusAccepted, jsonResponse{
+			Error:      false,
+			StatusCode: http.StatusAccepted,
+			Message:    "click ignored",
+		})
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = app.Repo.RecordPromotionClick(timeoutCtx, requestPayload.InventoryId, requestPayload.Scope, visitorKey(requestPayload.VisitorId))
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "click recorded",
+	})
+}
+
+// recordPromotionImpressions counts the promoted placements a search showed. It runs after the
+// response is built, so failures are only logged.
+func (app *Config) recordPromotionImpressions(promotions map[string]string) {
+	if len(promotions) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(promotions))
+	for _, id := range promotions {
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.RecordPromotionImpressions(ctx, ids); err != nil {
+		log.Printf("failed to record promotion impressions: %v", err)
+	}
+}
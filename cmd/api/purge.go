@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// purgeBatchSize is how many listings one purge run removes; the rest wait for the next run
+const purgeBatchSize = 100
+
+// PurgeConfig controls how long deleted listings can be restored and how often the purge job runs
+type PurgeConfig struct {
+	RestoreWindow time.Duration
+	Interval      time.Duration
+}
+
+// purgeConfigFromEnv reads INVENTORY_RESTORE_DAYS (default 30) and INVENTORY_PURGE_INTERVAL,
+// a duration such as "30m" (default 1h)
+func purgeConfigFromEnv() (PurgeConfig, error) {
+	cfg := PurgeConfig{RestoreWindow: 30 * 24 * time.Hour, Interval: time.Hour}
+
+	if v := os.Getenv("INVENTORY_RESTORE_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 {
+			return cfg, fmt.Errorf("INVENTORY_RESTORE_DAYS must be a positive number of days, got %q", v)
+		}
+		cfg.RestoreWindow = time.Duration(days) * 24 * time.Hour
+	}
+
+	if v := os.Getenv("INVENTORY_PURGE_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < time.Minute {
+			return cfg, fmt.Errorf("INVENTORY_PURGE_INTERVAL must be a duration of at least 1m, got %q", v)
+		}
+		cfg.Interval = interval
+	}
+
+	return cfg, nil
+}
+
+// runInventoryPurger purges listings whose restore window has passed, once every Interval
+func (app *Config) runInventoryPurger() {
+	ticker := time.NewTicker(app.Purge.Interval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeDeletedInventories()
+		<-ticker.C
+	}
+}
+
+func (app *Config) purgeDeletedInventories() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	ctx = data.WithChangeActor(ctx, "", data.ChangeSourceSystem)
+
+	ids, err := app.Repo.GetPurgeableInventories(ctx, app.Purge.RestoreWindow, purgeBatchSize)
+	if err != nil {
+		log.Printf("failed to list inventories to purge: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		images, err := app.Repo.PurgeInventory(ctx, id)
+		if err != nil {
+			log.Printf("failed to purge inventory %s: %v", id, err)
+			continue
+		}
+
+		// the rows are gone, so a failure here only leaves orphaned assets behind
+		for i := range images {
+			app.deleteImage(ctx, &images[i])
+		}
+	}
+
+	if len(ids) > 0 {
+		log.Printf("purged %d deleted inventories", len(ids))
+	}
+}
+
+type RestoreInventoryPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+}
+
+// DeletedInventories lists the user's deleted listings that can still be restored
+func (app *Config) DeletedInventories(w http.ResponseWriter, r *http.Request) {
+	var requestPayload RestoreInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inventories, err := app.Repo.GetDeletedInventories(timeoutCtx, requestPayload.UserId, app.Purge.RestoreWindow)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "deleted inventories retrieved successfully",
+		Data:       inventories,
+	})
+}
+
+// RestoreInventory brings back a deleted listing while it is inside the restore window
+func (app *Config) RestoreInventory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload RestoreInventoryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	owner, err := app.Repo.GetInventoryOwner(timeoutCtx, requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+	if owner != requestPayload.UserId {
+		app.errorJSON(w, errors.New("you are not allowed to modify this inventory"), nil, http.StatusForbidden)
+		return
+	}
+
+	actorCtx := data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP)
+	err = app.Repo.RestoreInventory(actorCtx, requestPayload.UserId, requestPayload.InventoryId, app.Purge.RestoreWindow)
+	if err != nil {
+		if errors.Is(err, data.ErrInventoryNotRestorable) {
+			app.errorJSON(w, err, nil, http.StatusConflict)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	inv, err := app.Repo.GetInventoryByIDOrSlug(timeoutCtx, "", requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventory restored successfully",
+		Data:       inv,
+	})
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/obynonwane/inventory-service/data"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// maxQuestionLength and maxAnswerLength are in characters
+	maxQuestionLength = 500
+	maxAnswerLength   = 1000
+	// maxDetailQuestions is how many answered question ids go with a listing's detail
+	maxDetailQuestions = 10
+)
+
+// sendQuestionHeaders tells the client about a listing's answered questions with its gRPC detail,
+// as the proto has no field for them: x-answered-questions has the count and x-inventory-question-ids
+// the ids of the latest few. The questions themselves are fetched from inventory-questions, which
+// keeps the headers small. Failures are only logged.
+func (app *Config) sendQuestionHeaders(ctx, timeoutCtx context.Context, inventoryID string) {
+	questions, err := app.Repo.GetInventoryQuestions(timeoutCtx, inventoryID, false)
+	if err != nil {
+		log.Printf("failed to get questions of inventory %s: %v", inventoryID, err)
+		return
+	}
+
+	ids := make([]string, 0, maxDetailQuestions)
+	for _, q := range questions {
+		if len(ids) == maxDetailQuestions {
+			break
+		}
+		ids = append(ids, q.ID)
+	}
+
+	md := metadata.Pairs("x-answered-questions", strconv.Itoa(len(questions)), "x-inventory-question-ids", strings.Join(ids, ","))
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		log.Printf("failed to send questions header: %v", err)
+	}
+}
+
+type InventoryQuestionPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id"`
+	QuestionId  string `json:"question_id"`
+	Question    string `json:"question"` // for ask-inventory-question
+	Answer      string `json:"answer"`   // for answer-inventory-question
+	Hidden      bool   `json:"hidden"`   // for hide-inventory-question; false shows the question again
+}
+
+// questionText trims text and checks it is not empty and at most max characters
+func questionText(field, text string, max int) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("missing " + field)
+	}
+	if utf8.RuneCountInString(text) > max {
+		return "", errors.New(field + " is too long")
+	}
+	return text, nil
+}
+
+// AskInventoryQuestion lets a signed in user ask a public question on someone else's listing.
+// The owner is notified and the question shows on the listing once it is answered.
+func (app *Config) AskInventoryQuestion(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+	question, err := questionText("question", requestPayload.Question, maxQuestionLength)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, err := app.Repo.GetInventoryByID(timeoutCtx, requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+	if inv.UserId == requestPayload.UserId {
+		app.errorJSON(w, errors.New("you cannot ask a question on your own inventory"), nil, http.StatusForbidden)
+		return
+	}
+
+	saved, err := app.Repo.AskInventoryQuestion(timeoutCtx, &data.InventoryQuestion{
+		InventoryID: inv.ID,
+		AskerID:     requestPayload.UserId,
+		Question:    question,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "question sent successfully",
+		Data:       saved,
+	})
+}
+
+// AnswerInventoryQuestion lets the owner answer a question on their listing, or change the answer.
+// The user who asked is notified.
+func (app *Config) AnswerInventoryQuestion(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" || requestPayload.QuestionId == "" {
+		app.errorJSON(w, errors.New("missing user_id or question_id"), nil)
+		return
+	}
+	answer, err := questionText("answer", requestPayload.Answer, maxAnswerLength)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	saved, err := app.Repo.AnswerInventoryQuestion(timeoutCtx, requestPayload.UserId, requestPayload.QuestionId, answer)
+	if errors.Is(err, data.ErrQuestionNotFound) {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "question answered successfully",
+		Data:       saved,
+	})
+}
+
+// InventoryQuestions lists a listing's answered questions. The owner, passing their user_id,
+// also sees unanswered and hidden ones.
+func (app *Config) InventoryQuestions(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, err := app.Repo.GetInventoryByID(timeoutCtx, requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+		return
+	}
+
+	owner := requestPayload.UserId != "" && requestPayload.UserId == inv.UserId
+	questions, err := app.Repo.GetInventoryQuestions(timeoutCtx, inv.ID, owner)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "questions retrieved successfully",
+		Data:       questions,
+	})
+}
+
+// ReportInventoryQuestion records a user's report of a question. Each user counts once, and enough
+// reports hide the question until the owner or an admin shows it again.
+func (app *Config) ReportInventoryQuestion(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" || requestPayload.QuestionId == "" {
+		app.errorJSON(w, errors.New("missing user_id or question_id"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = app.Repo.ReportInventoryQuestion(timeoutCtx, requestPayload.UserId, requestPayload.QuestionId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "recorded successfully",
+	})
+}
+
+// HideInventoryQuestion lets the owner hide a question on their listing, or show it again unless
+// it was hidden by moderation
+func (app *Config) HideInventoryQuestion(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.UserId == "" {
+		app.errorJSON(w, errors.New("missing user_id"), nil)
+		return
+	}
+
+	app.setInventoryQuestionHidden(w, r, requestPayload.UserId, requestPayload)
+}
+
+// AdminHideInventoryQuestion hides any question, or shows it again
+func (app *Config) AdminHideInventoryQuestion(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryQuestionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	app.setInventoryQuestionHidden(w, r, "", requestPayload)
+}
+
+func (app *Config) setInventoryQuestionHidden(w http.ResponseWriter, r *http.Request, ownerID string, requestPayload InventoryQuestionPayload) {
+	if requestPayload.QuestionId == "" {
+		app.errorJSON(w, errors.New("missing question_id"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := app.Repo.SetInventoryQuestionHidden(timeoutCtx, ownerID, requestPayload.QuestionId, requestPayload.Hidden)
+	if errors.Is(err, data.ErrQuestionNotFound) {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, data.ErrQuestionModerated) {
+		app.errorJSON(w, err, nil, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	message := "question shown successfully"
+	if requestPayload.Hidden {
+		message = "question hidden successfully"
+	}
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    message,
+	})
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/obynonwane/inventory-service/media"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -30,6 +32,11 @@ func (app *Config) routes() http.Handler {
 	mux.Post("/api/v1/create-order", app.CreatePrurchaseOrder)
 	mux.Post("/api/v1/my-purchase", app.MyPurchase)
 	mux.Post("/api/v1/purchase-requests", app.GetPurchaseRequest)
+	mux.Post("/api/v1/export-my-inventories", app.ExportMyInventories)
+	mux.Post("/api/v1/export-my-bookings", app.ExportMyBookings)
+	mux.Post("/api/v1/export-booking-requests", app.ExportBookingRequests)
+	mux.Post("/api/v1/export-my-purchases", app.ExportMyPurchases)
+	mux.Post("/api/v1/export-purchase-requests", app.ExportPurchaseRequests)
 	mux.Post("/api/v1/submit-chat", app.SubmitChat)
 	mux.Post("/api/v1/chat-history", app.GetChatHistory)
 	mux.Post("/api/v1/chat-list", app.GetChatList)
@@ -40,6 +47,53 @@ func (app *Config) routes() http.Handler {
 	mux.Post("/api/v1/save-inventory", app.SaveInventory)
 	mux.Post("/api/v1/delete-saved-inventory", app.DeleteSaveInventory)
 	mux.Post("/api/v1/delete-inventory", app.DeleteInventory)
+	mux.Post("/api/v1/deleted-inventories", app.DeletedInventories)
+	mux.Post("/api/v1/restore-inventory", app.RestoreInventory)
+	mux.Post("/api/v1/schedule-inventory", app.ScheduleInventory)
+	mux.Post("/api/v1/renew-inventory", app.RenewInventory)
+	mux.Post("/api/v1/promotion-price", app.PromotionPrice)
+	mux.Post("/api/v1/promote-inventory", app.PromoteInventory)
+	mux.Post("/api/v1/inventory-promotions", app.InventoryPromotions)
+	mux.Post("/api/v1/promotion-click", app.PromotionClick)
+	mux.Post("/api/v1/notifications", app.Notifications)
+	mux.Post("/api/v1/mark-notifications-read", app.MarkNotificationsRead)
+	mux.Post("/api/v1/inventory-events", app.InventoryEvents)
+	mux.Post("/api/v1/inventory-analytics", app.InventoryAnalytics)
+	mux.Post("/api/v1/my-inventory-analytics", app.MyInventoryAnalytics)
+	mux.Post("/api/v1/inventory-questions", app.InventoryQuestions)
+	mux.Post("/api/v1/ask-inventory-question", app.AskInventoryQuestion)
+	mux.Post("/api/v1/answer-inventory-question", app.AnswerInventoryQuestion)
+	mux.Post("/api/v1/report-inventory-question", app.ReportInventoryQuestion)
+	mux.Post("/api/v1/hide-inventory-question", app.HideInventoryQuestion)
+	mux.Post("/api/v1/business-location", app.SetBusinessLocation)
+	mux.Get("/api/v1/sitemap.xml", app.SitemapIndex)
+	mux.Get("/api/v1/sitemaps/{section}-{page}.xml", app.Sitemap)
+	mux.Get("/api/v1/feeds/products.{format}", app.ProductFeed)
+	mux.Post("/api/v1/update-inventory", app.UpdateInventory)
+	mux.Post("/api/v1/add-inventory-images", app.AddInventoryImages)
+	mux.Post("/api/v1/delete-inventory-image", app.DeleteInventoryImage)
+	mux.Post("/api/v1/reorder-inventory-images", app.ReorderInventoryImages)
+	mux.Post("/api/v1/set-primary-inventory-image", app.SetPrimaryInventoryImage)
+	mux.Post("/api/v1/inventory-job", app.GetInventoryJob)
+	mux.Post("/api/v1/retry-inventory-job", app.RetryInventoryJob)
+	mux.Post("/api/v1/posting-quota", app.PostingQuota)
+	mux.Post("/api/v1/import-inventories", app.ImportInventories)
+	mux.Post("/api/v1/inventory-import", app.GetInventoryImport)
+	mux.Post("/api/v1/inventory-variants", app.GetInventoryVariants)
+	mux.Post("/api/v1/create-inventory-variant", app.CreateInventoryVariant)
+	mux.Post("/api/v1/update-inventory-variant", app.UpdateInventoryVariant)
+	mux.Post("/api/v1/delete-inventory-variant", app.DeleteInventoryVariant)
+	mux.Post("/api/v1/inventory-units", app.GetInventoryUnits)
+	mux.Post("/api/v1/create-inventory-unit", app.CreateInventoryUnit)
+	mux.Post("/api/v1/update-inventory-unit", app.UpdateInventoryUnit)
+	mux.Post("/api/v1/inventory-unit-history", app.InventoryUnitHistory)
+	mux.Post("/api/v1/hand-over-booking-units", app.HandOverBookingUnits)
+	mux.Post("/api/v1/return-booking-units", app.ReturnBookingUnits)
+	mux.Post("/api/v1/inventory-stock", app.InventoryStock)
+	mux.Post("/api/v1/low-stock-threshold", app.SetLowStockThreshold)
+	mux.Post("/api/v1/set-inventory-bundle", app.SetInventoryBundle)
+	mux.Post("/api/v1/bundle-availability", app.BundleAvailability)
+	mux.Post("/api/v1/inventory-history", app.InventoryHistory)
 	mux.Post("/api/v1/delete-chat", app.DeleteChat)
 
 	mux.Post("/api/v1/report-user-rating", app.ReportUserRating)
@@ -52,6 +106,9 @@ func (app *Config) routes() http.Handler {
 	mux.Post("/api/v1/user-saved-inventory", app.GetUserSavedInventory)
 
 	mux.Get("/api/v1/user-detail", app.GetUserDetail)
+	mux.Get("/api/v1/subcategory-attributes/{id}", app.GetSubcategoryAttributes)
+	mux.Post("/api/v1/tag-inventories", app.TagInventories)
+	mux.Post("/api/v1/popular-tags", app.PopularTags)
 
 	mux.Post("/api/v1/premium-partners", app.PremiumPartner)
 	mux.Get("/api/v1/premium-extras", app.GetPremiumUsersExtras)
@@ -68,6 +125,12 @@ func (app *Config) routes() http.Handler {
 	// admin endpoints
 	mux.Post("/api/v1/pending-inventories", app.AdminGetInventoryPendingApproval)
 	mux.Get("/api/v1/approve-inventory/{id}", app.AdminApproveInventory)
+	mux.Post("/api/v1/admin-inventory-history", app.AdminGetInventoryHistory)
+	mux.Post("/api/v1/admin-save-subcategory-attribute", app.AdminSaveSubcategoryAttribute)
+	mux.Post("/api/v1/admin-delete-subcategory-attribute", app.AdminDeleteSubcategoryAttribute)
+	mux.Post("/api/v1/admin-tag-synonym", app.AdminSetTagSynonym)
+	mux.Post("/api/v1/admin-hide-inventory-question", app.AdminHideInventoryQuestion)
+	mux.Post("/api/v1/admin-lga-centroid", app.AdminSetLgaCentroid)
 	mux.Post("/api/v1/active-subscriptions", app.AdminGetActiveSubscriptions)
 	mux.Post("/api/v1/getusers", app.AdminGetUsers)
 	mux.Get("/api/v1/dasboard-card", app.AdminGetDashboardCard)
@@ -80,5 +143,11 @@ func (app *Config) routes() http.Handler {
 	mux.Post("/api/v1/analytics/subscription-amount", app.GetSubscriptionAmountStats)
 	mux.Post("/api/v1/get-businesses", app.GetBusinesses)
 
+	// files uploaded to the local media backend are served by this service
+	if local, ok := app.Media.(*media.LocalStore); ok {
+		prefix := strings.TrimSuffix(local.Path(), "/")
+		mux.Handle(prefix+"/*", http.StripPrefix(prefix, local))
+	}
+
 	return mux
 }
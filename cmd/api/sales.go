@@ -14,6 +14,7 @@ import (
 
 type CreatePrurchaseOrderPayload struct {
 	InventoryId       string  `json:"inventory_id" binding:"required"`
+	VariantId         string  `json:"variant_id"` // required when the inventory has variants
 	SellerId          string  `json:"seller_id"`
 	BuyerId           string  `json:"buyer_id"`
 	OfferPricePerUnit float64 `json:"offer_price_per_unit" binding:"required"`
@@ -45,26 +46,36 @@ func (app *Config) CreatePrurchaseOrder(w http.ResponseWriter, r *http.Request)
 		app.errorJSON(w, err, nil, http.StatusInternalServerError)
 		return
 	}
+	// every attempt counts in the owner's analytics, whether or not it goes through
+	go app.recordInventoryEvent(data.InventoryEventBooking, requestPayload.BuyerId, inv.ID)
+
 	// check if item is for sale
 	if inv.ProductPurpose != "sale" {
 		app.errorJSON(w, errors.New(fmt.Sprintf("item is only for outright purchase not rental")), nil, http.StatusBadRequest)
 		return
 	}
 
+	// price and stock come from the chosen variant when the inventory has variants
+	terms, err := resolveVariantTerms(inv, requestPayload.VariantId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusBadRequest)
+		return
+	}
+
 	// check check the offer price is not less than stipulated price
-	if requestPayload.OfferPricePerUnit < inv.MinimumPrice {
-		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be less than minimum price: %v", inv.MinimumPrice)), nil, http.StatusBadRequest)
+	if requestPayload.OfferPricePerUnit < terms.MinimumPrice {
+		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be less than minimum price: %v", terms.MinimumPrice)), nil, http.StatusBadRequest)
 		return
 	}
 
 	// check the offer price is not more than stipulated price
-	if requestPayload.OfferPricePerUnit > inv.OfferPrice {
-		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be more than stipulated price: %v", inv.OfferPrice)), nil, http.StatusBadRequest)
+	if requestPayload.OfferPricePerUnit > terms.OfferPrice {
+		app.errorJSON(w, errors.New(fmt.Sprintf("offer price can not be more than stipulated price: %v", terms.OfferPrice)), nil, http.StatusBadRequest)
 		return
 	}
 	// check the quantity needed is met
-	if requestPayload.Quantity > inv.Quantity {
-		app.errorJSON(w, errors.New(fmt.Sprintf("the stipulated quantity is not available, only: %v", inv.Quantity)), nil, http.StatusBadRequest)
+	if requestPayload.Quantity > terms.Quantity {
+		app.errorJSON(w, errors.New(fmt.Sprintf("the stipulated quantity is not available, only: %v", terms.Quantity)), nil, http.StatusBadRequest)
 		return
 	}
 
@@ -75,6 +86,7 @@ func (app *Config) CreatePrurchaseOrder(w http.ResponseWriter, r *http.Request)
 		SellerId:          inv.UserId,
 		BuyerId:           requestPayload.BuyerId,
 		InventoryId:       inv.ID,
+		VariantId:         terms.VariantId,
 		OfferPricePerUnit: requestPayload.OfferPricePerUnit,
 		Quantity:          int32(requestPayload.Quantity),
 		TotalAmount:       totalPrice,
@@ -84,6 +96,9 @@ func (app *Config) CreatePrurchaseOrder(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// the order holds stock
+	go app.checkInventoryStock(inv.ID)
+
 	// send sms & email notification to both owner and buyer
 
 	payload := jsonResponse{
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/joho/godotenv"
 	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/media"
 )
 
 var testApp Config
@@ -23,8 +28,22 @@ func TestMain(m *testing.M) {
 	repo := data.NewPostgresTestRepository(nil)
 
 	testApp.Repo = repo
+	testApp.Media = media.NewMemoryStore()
 	//execute the tests and benchmarks.
 	//It returns an exit code that indicates
 	//whether the tests passed or failed
 	os.Exit(m.Run()) // run all of my test
 }
+
+// postJSON sends body to an HTTP handler and decodes its response
+func postJSON(t *testing.T, handler http.HandlerFunc, body string) (int, jsonResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	var response jsonResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, response
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/obynonwane/inventory-service/data"
+)
+
+const (
+	// sitemapMaxURLs is the most URLs one sitemap file may list
+	sitemapMaxURLs = 50000
+	// sitemapCacheAge is how long crawlers and proxies may keep a sitemap or feed
+	sitemapCacheAge = time.Hour
+
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// sitemapSections are listed in the sitemap index in this order
+var sitemapSections = []string{data.SitemapCategories, data.SitemapStates, data.SitemapBusinesses, data.SitemapListings}
+
+// SiteConfig describes the public site the sitemaps and product feed link to. The paths may use
+// {slug}, {category_slug} and {state_slug}.
+type SiteConfig struct {
+	URL          *url.URL // the public site; sitemaps and the feed are off without it
+	SitemapURL   string   // where the sitemap files are served, as the site proxies them
+	ListingPath  string
+	CategoryPath string
+	StatePath    string
+	Currency     string // of listing prices in the product feed
+}
+
+// siteConfigFromEnv reads SITE_URL, SITEMAP_URL (default SITE_URL/api/v1), SITE_LISTING_PATH
+// (default /{state_slug}/{category_slug}/{slug}), SITE_CATEGORY_PATH (default /category/{category_slug}),
+// SITE_STATE_PATH (default /location/{state_slug}) and FEED_CURRENCY (default NGN)
+func siteConfigFromEnv() (SiteConfig, error) {
+	cfg := SiteConfig{
+		ListingPath:  "/{state_slug}/{category_slug}/{slug}",
+		CategoryPath: "/category/{category_slug}",
+		StatePath:    "/location/{state_slug}",
+		Currency:     "NGN",
+	}
+
+	if v := os.Getenv("SITE_URL"); v != "" {
+		u, err := url.Parse(strings.TrimRight(v, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("SITE_URL must be an http or https url, got %q", v)
+		}
+		cfg.URL = u
+		cfg.SitemapURL = u.String() + "/api/v1"
+	}
+	if v := os.Getenv("SITEMAP_URL"); v != "" {
+		cfg.SitemapURL = strings.TrimRight(v, "/")
+	}
+	if v := os.Getenv("SITE_LISTING_PATH"); v != "" {
+		cfg.ListingPath = v
+	}
+	if v := os.Getenv("SITE_CATEGORY_PATH"); v != "" {
+		cfg.CategoryPath = v
+	}
+	if v := os.Getenv("SITE_STATE_PATH"); v != "" {
+		cfg.StatePath = v
+	}
+	if v := os.Getenv("FEED_CURRENCY"); v != "" {
+		cfg.Currency = strings.ToUpper(v)
+	}
+
+	return cfg, nil
+}
+
+// pageURL returns the public url of a sitemap entry in section
+func (c SiteConfig) pageURL(section string, entry data.SitemapEntry) string {
+	if section == data.SitemapBusinesses {
+		return fmt.Sprintf("%s://%s.%s/", c.URL.Scheme, url.PathEscape(entry.Subdomain), c.URL.Host)
+	}
+
+	path := map[string]string{
+		data.SitemapListings:   c.ListingPath,
+		data.SitemapCategories: c.CategoryPath,
+		data.SitemapStates:     c.StatePath,
+	}[section]
+	path = strings.NewReplacer(
+		"{slug}", url.PathEscape(entry.Slug),
+		"{category_slug}", url.PathEscape(entry.CategorySlug),
+		"{state_slug}", url.PathEscape(entry.StateSlug),
+	).Replace(path)
+	return c.URL.String() + path
+}
+
+// streamWriter sends a sitemap or feed as its rows are read. Nothing is written until the first
+// row, so an error before then can still become a json error response.
+type streamWriter struct {
+	w           http.ResponseWriter
+	name        string
+	contentType string
+	prologue    string
+	epilogue    string
+	xml         *xml.Encoder
+	started     bool
+	rows        int
+}
+
+func (s *streamWriter) start() error {
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sitemapCacheAge.Seconds())))
+	s.w.WriteHeader(http.StatusOK)
+	_, err := io.WriteString(s.w, s.prologue)
+	return err
+}
+
+// encode writes one row, v as an xml element when the stream is xml, otherwise as text
+func (s *streamWriter) encode(v any) error {
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if s.xml != nil {
+		err = s.xml.Encode(v)
+	} else {
+		_, err = io.WriteString(s.w, v.(string))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.rows++
+	if s.rows%exportFlushEvery == 0 {
+		if f, ok := s.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	return nil
+}
+
+// finish ends the stream like finishExport ends an export
+func (app *Config) finishStream(w http.ResponseWriter, s *streamWriter, err error) {
+	if err != nil {
+		if !s.started {
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s stopped after %d rows: %v", s.name, s.rows, err)
+		return
+	}
+
+	if !s.started {
+		if err := s.start(); err != nil {
+			log.Printf("%s: %v", s.name, err)
+			return
+		}
+	}
+	if _, err := io.WriteString(w, s.epilogue); err != nil {
+		log.Printf("%s: %v", s.name, err)
+	}
+}
+
+func newXMLStream(w http.ResponseWriter, name, prologue, epilogue string) *streamWriter {
+	return &streamWriter{
+		w:           w,
+		name:        name,
+		contentType: "application/xml; charset=utf-8",
+		prologue:    xml.Header + prologue,
+		epilogue:    epilogue,
+		xml:         xml.NewEncoder(w),
+	}
+}
+
+// siteConfigured writes an error response and returns false when SITE_URL is not set
+func (app *Config) siteConfigured(w http.ResponseWriter) bool {
+	if app.Site.URL == nil {
+		app.errorJSON(w, errors.New("sitemaps are not configured"), nil, http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+type sitemapIndexEntry struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// SitemapIndex lists the sitemap files of every section, listings and businesses split into files
+// of at most sitemapMaxURLs. Files after the first carry the key they start at, so each is read
+// from an index instead of an offset.
+func (app *Config) SitemapIndex(w http.ResponseWriter, r *http.Request) {
+	if !app.siteConfigured(w) {
+		return
+	}
+
+	var entries []sitemapIndexEntry
+	for _, section := range sitemapSections {
+		pages, err := app.Repo.GetSitemapPages(r.Context(), section, sitemapMaxURLs)
+		if err != nil {
+			app.errorJSON(w, err, nil, http.StatusInternalServerError)
+			return
+		}
+		for i, page := range pages {
+			loc := fmt.Sprintf("%s/sitemaps/%s-%d.xml", app.Site.SitemapURL, section, i+1)
+			if i > 0 {
+				loc += "?from=" + url.QueryEscape(page.From)
+			}
+			entries = append(entries, sitemapIndexEntry{Loc: loc, LastMod: page.LastMod.UTC().Format(time.RFC3339)})
+		}
+	}
+
+	out := newXMLStream(w, "sitemap index", `<sitemapindex xmlns="`+sitemapNamespace+`">`, "</sitemapindex>\n")
+	var err error
+	for _, entry := range entries {
+		if err = out.encode(entry); err != nil {
+			break
+		}
+	}
+	app.finishStream(w, out, err)
+}
+
+type sitemapURL struct {
+	XMLName xml.Name `xml:"url"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// Sitemap streams one sitemap file, /sitemaps/{section}-{page}.xml?from={key}, with pages counted
+// from 1. Every page but the first starts at the key the sitemap index gives it.
+func (app *Config) Sitemap(w http.ResponseWriter, r *http.Request) {
+	if !app.siteConfigured(w) {
+		return
+	}
+
+	section := chi.URLParam(r, "section")
+	page, err := strconv.Atoi(chi.URLParam(r, "page"))
+	from := r.URL.Query().Get("from")
+	if _, ok := map[string]bool{data.SitemapListings: true, data.SitemapCategories: true, data.SitemapStates: true, data.SitemapBusinesses: true}[section]; !ok || err != nil || page < 1 || (page > 1) != (from != "") {
+		app.errorJSON(w, errors.New("no sitemap found"), nil, http.StatusNotFound)
+		return
+	}
+
+	out := newXMLStream(w, "sitemap "+section, `<urlset xmlns="`+sitemapNamespace+`">`, "</urlset>\n")
+	err = app.Repo.StreamSitemapEntries(r.Context(), section, from, sitemapMaxURLs, func(entry data.SitemapEntry) error {
+		return out.encode(sitemapURL{
+			Loc:     app.Site.pageURL(section, entry),
+			LastMod: entry.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if errors.Is(err, data.ErrInvalidSitemapCursor) {
+		app.errorJSON(w, err, nil, http.StatusNotFound)
+		return
+	}
+	app.finishStream(w, out, err)
+}
+
+// productFeedColumns are the attributes of the tsv feed, named as in the xml one
+var productFeedColumns = []string{"id", "title", "description", "link", "image_link", "availability", "price", "condition", "product_type"}
+
+type productFeedEntry struct {
+	XMLName      xml.Name `xml:"item"`
+	ID           string   `xml:"g:id"`
+	Title        string   `xml:"title"`
+	Description  string   `xml:"description"`
+	Link         string   `xml:"link"`
+	ImageLink    string   `xml:"g:image_link"`
+	Availability string   `xml:"g:availability"`
+	Price        string   `xml:"g:price"`
+	Condition    string   `xml:"g:condition,omitempty"`
+	ProductType  string   `xml:"g:product_type,omitempty"`
+}
+
+// feedCondition maps a listing's free text condition to new, used or refurbished, or "" when unclear
+func feedCondition(condition string) string {
+	condition = strings.ToLower(condition)
+	switch {
+	case strings.Contains(condition, "refurb"):
+		return "refurbished"
+	case strings.Contains(condition, "used"):
+		return "used"
+	case strings.Contains(condition, "new"):
+		return "new"
+	}
+	return ""
+}
+
+func (app *Config) productFeedEntry(item data.ProductFeedItem) productFeedEntry {
+	availability := "out_of_stock"
+	if item.IsAvailable == "yes" && item.Quantity > 0 {
+		availability = "in_stock"
+	}
+
+	productType := item.CategoryName
+	if item.SubcategoryName != "" {
+		productType += " > " + item.SubcategoryName
+	}
+
+	return productFeedEntry{
+		ID:          item.ID,
+		Title:       item.Name,
+		Description: item.Description,
+		Link: app.Site.pageURL(data.SitemapListings, data.SitemapEntry{
+			Slug: item.Slug, CategorySlug: item.CategorySlug, StateSlug: item.StateSlug,
+		}),
+		ImageLink:    item.PrimaryImage,
+		Availability: availability,
+		Price:        fmt.Sprintf("%.2f %s", item.OfferPrice, app.Site.Currency),
+		Condition:    feedCondition(item.Condition),
+		ProductType:  productType,
+	}
+}
+
+// tsvField keeps a value on one line of one column
+var tsvField = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// ProductFeed streams the public sale listings as a Google Merchant style feed,
+// /feeds/products.xml or /feeds/products.tsv
+func (app *Config) ProductFeed(w http.ResponseWriter, r *http.Request) {
+	if !app.siteConfigured(w) {
+		return
+	}
+
+	var out *streamWriter
+	switch chi.URLParam(r, "format") {
+	case "xml":
+		var channel strings.Builder
+		channel.WriteString(`<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0"><channel><title>`)
+		xml.EscapeText(&channel, []byte(app.Site.URL.Host))
+		channel.WriteString(`</title><link>`)
+		xml.EscapeText(&channel, []byte(app.Site.URL.String()))
+		channel.WriteString(`</link><description>Products for sale</description>`)
+		out = newXMLStream(w, "product feed", channel.String(), "</channel></rss>\n")
+	case "tsv":
+		out = &streamWriter{
+			w:           w,
+			name:        "product feed",
+			contentType: "text/tab-separated-values; charset=utf-8",
+			prologue:    strings.Join(productFeedColumns, "\t") + "\n",
+		}
+	default:
+		app.errorJSON(w, errors.New("no feed found"), nil, http.StatusNotFound)
+		return
+	}
+
+	err := app.Repo.StreamProductFeed(r.Context(), func(item data.ProductFeedItem) error {
+		entry := app.productFeedEntry(item)
+		if out.xml != nil {
+			return out.encode(entry)
+		}
+
+		record := []string{
+			entry.ID, entry.Title, entry.Description, entry.Link, entry.ImageLink,
+			entry.Availability, entry.Price, entry.Condition, entry.ProductType,
+		}
+		for i, field := range record {
+			record[i] = tsvField.Replace(field)
+		}
+		return out.encode(strings.Join(record, "\t") + "\n")
+	})
+	app.finishStream(w, out, err)
+}
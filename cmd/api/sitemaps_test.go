@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func testSiteConfig(t *testing.T) SiteConfig {
+	t.Helper()
+	t.Setenv("SITE_URL", "https://shop.example.com/")
+	t.Setenv("FEED_CURRENCY", "usd")
+	cfg, err := siteConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestSiteConfigPageURL(t *testing.T) {
+	cfg := testSiteConfig(t)
+
+	tests := []struct {
+		section string
+		entry   data.SitemapEntry
+		want    string
+	}{
+		{data.SitemapListings, data.SitemapEntry{Slug: "red-sofa-01J9.html", CategorySlug: "furniture", StateSlug: "lagos"}, "https://shop.example.com/lagos/furniture/red-sofa-01J9.html"},
+		{data.SitemapListings, data.SitemapEntry{Slug: "a b", CategorySlug: "c/d", StateSlug: "e"}, "https://shop.example.com/e/c%2Fd/a%20b"},
+		{data.SitemapCategories, data.SitemapEntry{CategorySlug: "furniture"}, "https://shop.example.com/category/furniture"},
+		{data.SitemapStates, data.SitemapEntry{StateSlug: "lagos"}, "https://shop.example.com/location/lagos"},
+		{data.SitemapBusinesses, data.SitemapEntry{Subdomain: "acme"}, "https://acme.shop.example.com/"},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.pageURL(tt.section, tt.entry); got != tt.want {
+			t.Errorf("%s: got %s; want %s", tt.section, got, tt.want)
+		}
+	}
+
+	if cfg.SitemapURL != "https://shop.example.com/api/v1" {
+		t.Errorf("got sitemap url %s", cfg.SitemapURL)
+	}
+}
+
+func TestSiteConfigFromEnv_RejectsBadURL(t *testing.T) {
+	t.Setenv("SITE_URL", "ftp://example.com")
+	if _, err := siteConfigFromEnv(); err == nil {
+		t.Error("expected an ftp SITE_URL to be rejected")
+	}
+}
+
+func TestProductFeedEntry(t *testing.T) {
+	app := Config{Site: testSiteConfig(t)}
+
+	item := data.ProductFeedItem{
+		ID: "id", Name: "Red sofa", Slug: "red-sofa", CategorySlug: "furniture", StateSlug: "lagos",
+		CategoryName: "Furniture", SubcategoryName: "Sofas", Condition: "Used - like new",
+		IsAvailable: "yes", OfferPrice: 1500, Quantity: 2,
+	}
+	entry := app.productFeedEntry(item)
+	if entry.Availability != "in_stock" || entry.Price != "1500.00 USD" || entry.Condition != "used" ||
+		entry.ProductType != "Furniture > Sofas" || entry.Link != "https://shop.example.com/lagos/furniture/red-sofa" {
+		t.Errorf("got %+v", entry)
+	}
+
+	// a listing that is marked available but has nothing left is out of stock
+	item.Quantity = 0
+	if entry := app.productFeedEntry(item); entry.Availability != "out_of_stock" {
+		t.Errorf("got availability %s", entry.Availability)
+	}
+	item.Quantity, item.IsAvailable = 2, "no"
+	if entry := app.productFeedEntry(item); entry.Availability != "out_of_stock" {
+		t.Errorf("got availability %s", entry.Availability)
+	}
+}
+
+func TestFeedCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      string
+	}{
+		{"Brand New", "new"},
+		{"Used", "used"},
+		{"Used - like new", "used"},
+		{"Refurbished", "refurbished"},
+		{"refurb, barely used", "refurbished"},
+		{"good", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := feedCondition(tt.condition); got != tt.want {
+			t.Errorf("%q: got %q; want %q", tt.condition, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "strings"
+
+// parseSortFilter takes a sort:order term, such as sort:price_asc, out of a search text and returns
+// the order with the rest of the text. The last term wins; the order is checked by the search.
+func parseSortFilter(text string) (string, string) {
+	var order string
+	var rest []string
+	for _, term := range strings.Fields(text) {
+		value, ok := strings.CutPrefix(term, "sort:")
+		if !ok {
+			rest = append(rest, term)
+			continue
+		}
+		order = strings.ToLower(value)
+	}
+
+	return order, strings.Join(rest, " ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/obynonwane/rental-service-proto/inventory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func TestParseSortFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		order string
+		rest  string
+	}{
+		{"no order", "red sofa", "", "red sofa"},
+		{"order", "sofa sort:price_asc lagos", "price_asc", "sofa lagos"},
+		{"lowercased", "sort:Newest", "newest", ""},
+		{"last wins", "sort:rating sofa sort:nearest", "nearest", "sofa"},
+	}
+
+	for _, tt := range tests {
+		order, rest := parseSortFilter(tt.text)
+		if order != tt.order || rest != tt.rest {
+			t.Errorf("%s: got %q, %q; want %q, %q", tt.name, order, rest, tt.order, tt.rest)
+		}
+	}
+}
+
+func TestSearchInventory_Sort(t *testing.T) {
+	server, repo := searchServer()
+
+	tests := []struct {
+		name  string
+		text  string
+		order string
+		code  codes.Code
+	}{
+		{"default", "sofa", "", codes.OK},
+		{"price", "sofa sort:price_asc", data.SortPriceAsc, codes.OK},
+		{"relevance needs text", "sort:relevance", data.SortRelevance, codes.InvalidArgument},
+		{"nearest needs a point", "sofa sort:nearest", data.SortNearest, codes.InvalidArgument},
+		{"nearest near a point", "sofa near:6.45,3.39 sort:nearest", data.SortNearest, codes.OK},
+		{"unknown", "sofa sort:cheapest", "cheapest", codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		_, err := server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: tt.text})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v; want %v", tt.name, err, tt.code)
+		}
+		if search := repo.Searches[len(repo.Searches)-1]; search.Sort != tt.order {
+			t.Errorf("%s: got order %q; want %q", tt.name, search.Sort, tt.order)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// checkInventoryStock alerts the owner of a listing when an order, booking or variant change
+// leaves it low on stock or sold out. It runs after the response is built, so failures are only logged.
+func (app *Config) checkInventoryStock(inventoryID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctx = data.WithChangeActor(ctx, "", data.ChangeSourceSystem)
+	if _, err := app.Repo.CheckInventoryStock(ctx, inventoryID); err != nil {
+		log.Printf("failed to check stock of inventory %s: %v", inventoryID, err)
+	}
+}
+
+type InventoryStockPayload struct {
+	UserId            string   `json:"user_id"`
+	InventoryId       string   `json:"inventory_id"`
+	LowStockThreshold *float64 `json:"low_stock_threshold"` // for low-stock-threshold; leave out to turn alerts off
+}
+
+// InventoryStock shows the owner how much of a listing is held by orders and bookings and how much is left
+func (app *Config) InventoryStock(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryStockPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	level, err := app.Repo.GetInventoryStock(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "stock retrieved successfully",
+		Data:       level,
+	})
+}
+
+// SetLowStockThreshold sets the available quantity below which the owner is alerted, and checks
+// the listing's stock against it straight away
+func (app *Config) SetLowStockThreshold(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryStockPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if t := requestPayload.LowStockThreshold; t != nil && (math.IsNaN(*t) || *t < 0) {
+		app.errorJSON(w, errors.New("low_stock_threshold can not be negative"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	err = app.Repo.SetLowStockThreshold(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), requestPayload.UserId, inv.ID, requestPayload.LowStockThreshold)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	level, err := app.Repo.CheckInventoryStock(data.WithChangeActor(timeoutCtx, "", data.ChangeSourceSystem), inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "low stock threshold updated successfully",
+		Data:       level,
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/obynonwane/inventory-service/data"
+	"github.com/obynonwane/inventory-service/utility"
+)
+
+const (
+	// maxInventoryTags is how many tags one listing can have
+	maxInventoryTags = 10
+	// maxTagLength is the longest tag, in characters
+	maxTagLength = 50
+)
+
+// normaliseInventoryTags splits and normalises the tags a user gave a listing and checks them
+// against the limits
+func normaliseInventoryTags(tags string) ([]string, error) {
+	normalized := utility.NormalizeTags(tags)
+	if len(normalized) > maxInventoryTags {
+		return nil, fmt.Errorf("a listing can have at most %d tags", maxInventoryTags)
+	}
+	for _, tag := range normalized {
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+	}
+	return normalized, nil
+}
+
+// parseTagFilters takes tag:name terms out of a search text and returns the tags with the rest of the text
+func parseTagFilters(text string) ([]string, string) {
+	var tags []string
+	var rest []string
+	for _, term := range strings.Fields(text) {
+		name, ok := strings.CutPrefix(term, "tag:")
+		if !ok {
+			rest = append(rest, term)
+			continue
+		}
+		tags = append(tags, utility.NormalizeTags(name)...)
+	}
+
+	return tags, strings.Join(rest, " ")
+}
+
+type TagInventoriesPayload struct {
+	Tag        string `json:"tag"`
+	CategoryId string `json:"category_id"`
+	Limit      string `json:"limit"`
+	Offset     string `json:"offset"`
+}
+
+// TagInventories lists the live listings with a tag, or with the tag it is a synonym of
+func (app *Config) TagInventories(w http.ResponseWriter, r *http.Request) {
+	var requestPayload TagInventoriesPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	tags := utility.NormalizeTags(requestPayload.Tag)
+	if len(tags) != 1 {
+		app.errorJSON(w, errors.New("tag must be a single tag"), nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inventories, err := app.Repo.SearchInventory(timeoutCtx, &data.SearchPayload{
+		Tags:       tags,
+		CategoryID: requestPayload.CategoryId,
+		Limit:      requestPayload.Limit,
+		Offset:     requestPayload.Offset,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	// promoted placements shown on this page count as impressions
+	go app.recordPromotionImpressions(inventories.Promotions)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "inventories retrieved successfully",
+		Data:       inventories,
+	})
+}
+
+type PopularTagsPayload struct {
+	CategoryId string `json:"category_id"` // empty for all categories
+	Limit      int    `json:"limit"`
+}
+
+// PopularTags lists the tags with the most live listings, in a category or overall
+func (app *Config) PopularTags(w http.ResponseWriter, r *http.Request) {
+	var requestPayload PopularTagsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.Limit <= 0 || requestPayload.Limit > 100 {
+		requestPayload.Limit = 20
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if requestPayload.CategoryId != "" {
+		if _, err := app.Repo.GetCategoryByID(timeoutCtx, &data.GetCategoryByIDPayload{CategoryID: requestPayload.CategoryId}); err != nil {
+			app.errorJSON(w, errors.New("no record found"), nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tags, err := app.Repo.GetPopularTags(timeoutCtx, requestPayload.CategoryId, requestPayload.Limit)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "tags retrieved successfully",
+		Data:       tags,
+	})
+}
+
+type TagSynonymPayload struct {
+	Synonym   string `json:"synonym"`
+	Canonical string `json:"canonical"`
+}
+
+// AdminSetTagSynonym makes one tag stand for another, moving the listings that have it
+func (app *Config) AdminSetTagSynonym(w http.ResponseWriter, r *http.Request) {
+	var requestPayload TagSynonymPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	synonym := utility.NormalizeTags(requestPayload.Synonym)
+	canonical := utility.NormalizeTags(requestPayload.Canonical)
+	if len(synonym) != 1 || len(canonical) != 1 {
+		app.errorJSON(w, errors.New("synonym and canonical must each be a single tag"), nil)
+		return
+	}
+	for _, tag := range []string{synonym[0], canonical[0]} {
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			app.errorJSON(w, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength), nil)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := app.Repo.SetTagSynonym(timeoutCtx, synonym[0], canonical[0]); err != nil {
+		if errors.Is(err, data.ErrTagSynonymLoop) {
+			app.errorJSON(w, err, nil, http.StatusConflict)
+			return
+		}
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "tag synonym saved successfully",
+		Data:       TagSynonymPayload{Synonym: synonym[0], Canonical: canonical[0]},
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obynonwane/rental-service-proto/inventory"
+)
+
+func TestNormaliseInventoryTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    string
+		want    []string
+		wantErr bool
+	}{
+		{"normalised and deduplicated", "#Sofa, sofa Leather", []string{"sofa", "leather"}, false},
+		{"no tags", "", []string{}, false},
+		{"at the limit", "a b c d e f g h i j", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, false},
+		{"too many", "a b c d e f g h i j k", nil, true},
+		{"repeats do not count", "a b c d e f g h i j #J a", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, false},
+		{"too long", strings.Repeat("x", maxTagLength+1), nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := normaliseInventoryTags(tt.tags)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSearchInventory_TagFilters(t *testing.T) {
+	server, repo := searchServer()
+
+	_, err := server.SearchInventory(context.Background(), &inventory.SearchInventoryRequest{Text: "sofa tag:#Vintage tag:red,blue tag:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := repo.Searches[0]
+	if want := []string{"vintage", "red", "blue"}; !reflect.DeepEqual(search.Tags, want) || search.Text != "sofa" {
+		t.Errorf("got tags %q and text %q; want %q and %q", search.Tags, search.Text, want, "sofa")
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+// unitErrors are the unit and booking errors caused by the request rather than the service
+var unitErrors = []error{
+	data.ErrUnitNotFound, data.ErrUnitDuplicate, data.ErrUnitUnavailable, data.ErrUnitOut,
+	data.ErrBookingNotFound, data.ErrTooManyUnits, data.ErrBookingNotAccepted, data.ErrNoUnitFields,
+}
+
+// unitErrorStatus returns the status to respond to a unit error with
+func unitErrorStatus(err error) int {
+	for _, target := range unitErrors {
+		if errors.Is(err, target) {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// trimmedOrNil trims v and returns nil for an empty value
+func trimmedOrNil(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*v)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+type InventoryUnitPayload struct {
+	UserId       string  `json:"user_id"`
+	InventoryId  string  `json:"inventory_id"`
+	UnitId       string  `json:"unit_id"` // for update-inventory-unit and inventory-unit-history
+	VariantId    *string `json:"variant_id"`
+	SerialNumber *string `json:"serial_number"`
+	AssetTag     *string `json:"asset_tag"`
+	Condition    *string `json:"condition"`
+	Status       *string `json:"status"` // available, maintenance or retired; units go out by handover
+	Notes        *string `json:"notes"`
+}
+
+// checkUnitFields validates the fields of a unit being created or updated
+func (app *Config) checkUnitFields(ctx context.Context, inventoryID string, requestPayload InventoryUnitPayload) error {
+	if requestPayload.Status != nil {
+		switch *requestPayload.Status {
+		case data.UnitAvailable, data.UnitMaintenance, data.UnitRetired:
+		case data.UnitOut:
+			return errors.New("units go out when they are handed over for a booking")
+		default:
+			return errors.New("status must be available, maintenance or retired")
+		}
+	}
+	if requestPayload.VariantId != nil && *requestPayload.VariantId != "" {
+		if _, err := app.Repo.GetInventoryVariant(ctx, inventoryID, *requestPayload.VariantId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateInventoryUnit adds a physical unit to one of the owner's listings
+func (app *Config) CreateInventoryUnit(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryUnitPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+	if err := app.checkUnitFields(timeoutCtx, inv.ID, requestPayload); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	unit := &data.InventoryUnit{
+		InventoryID:  inv.ID,
+		VariantID:    trimmedOrNil(requestPayload.VariantId),
+		SerialNumber: trimmedOrNil(requestPayload.SerialNumber),
+		AssetTag:     trimmedOrNil(requestPayload.AssetTag),
+		Condition:    strings.TrimSpace(stringOr(requestPayload.Condition, "")),
+		Status:       stringOr(requestPayload.Status, data.UnitAvailable),
+		Notes:        stringOr(requestPayload.Notes, ""),
+	}
+
+	created, err := app.Repo.CreateInventoryUnit(timeoutCtx, unit)
+	if err != nil {
+		app.errorJSON(w, err, nil, unitErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "unit created successfully",
+		Data:       created,
+	})
+}
+
+// UpdateInventoryUnit changes the fields of a unit present in the request body
+func (app *Config) UpdateInventoryUnit(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryUnitPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+	if err := app.checkUnitFields(timeoutCtx, inv.ID, requestPayload); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	trim := func(v *string) *string {
+		if v == nil {
+			return nil
+		}
+		trimmed := strings.TrimSpace(*v)
+		return &trimmed
+	}
+	updated, err := app.Repo.UpdateInventoryUnit(timeoutCtx, data.UpdateInventoryUnitParams{
+		InventoryID:  inv.ID,
+		UnitID:       requestPayload.UnitId,
+		VariantID:    trim(requestPayload.VariantId),
+		SerialNumber: trim(requestPayload.SerialNumber),
+		AssetTag:     trim(requestPayload.AssetTag),
+		Condition:    trim(requestPayload.Condition),
+		Status:       requestPayload.Status,
+		Notes:        requestPayload.Notes,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil, unitErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "unit updated successfully",
+		Data:       updated,
+	})
+}
+
+// GetInventoryUnits lists the units of one of the owner's listings, with the booking each is out on
+func (app *Config) GetInventoryUnits(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryUnitPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	units, err := app.Repo.GetInventoryUnits(timeoutCtx, inv.ID)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "units retrieved successfully",
+		Data:       units,
+	})
+}
+
+// InventoryUnitHistory lists each rental of a unit, latest first
+func (app *Config) InventoryUnitHistory(w http.ResponseWriter, r *http.Request) {
+	var requestPayload InventoryUnitPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	rentals, err := app.Repo.GetInventoryUnitRentals(timeoutCtx, inv.ID, requestPayload.UnitId)
+	if err != nil {
+		app.errorJSON(w, err, nil, unitErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "unit history retrieved successfully",
+		Data:       rentals,
+	})
+}
+
+type BookingUnitPayload struct {
+	UnitId      string `json:"unit_id"`
+	Condition   string `json:"condition"`   // "" keeps the unit's condition
+	Notes       string `json:"notes"`       // about this handover or return
+	Maintenance bool   `json:"maintenance"` // for return-booking-units: the unit needs maintenance before it goes out again
+}
+
+type BookingUnitsPayload struct {
+	UserId    string               `json:"user_id"` // the owner of the booked listing
+	BookingId string               `json:"booking_id"`
+	Units     []BookingUnitPayload `json:"units"`
+}
+
+// readBookingUnits reads a handover or return, writing the error response itself when the request
+// is not valid. Each unit may appear once.
+func (app *Config) readBookingUnits(w http.ResponseWriter, r *http.Request) (*BookingUnitsPayload, bool) {
+	var requestPayload BookingUnitsPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return nil, false
+	}
+
+	if requestPayload.UserId == "" || requestPayload.BookingId == "" {
+		app.errorJSON(w, errors.New("missing user_id or booking_id"), nil)
+		return nil, false
+	}
+	seen := make(map[string]bool)
+	for _, unit := range requestPayload.Units {
+		if unit.UnitId == "" || seen[unit.UnitId] {
+			app.errorJSON(w, errors.New("each unit must have a unit_id and appear once"), nil)
+			return nil, false
+		}
+		seen[unit.UnitId] = true
+	}
+
+	return &requestPayload, true
+}
+
+// HandOverBookingUnits records which units went to the renter of a booking on the owner's listing
+func (app *Config) HandOverBookingUnits(w http.ResponseWriter, r *http.Request) {
+	requestPayload, ok := app.readBookingUnits(w, r)
+	if !ok {
+		return
+	}
+	if len(requestPayload.Units) == 0 {
+		app.errorJSON(w, errors.New("missing units"), nil)
+		return
+	}
+
+	handovers := make([]data.UnitHandover, 0, len(requestPayload.Units))
+	for _, unit := range requestPayload.Units {
+		handovers = append(handovers, data.UnitHandover{
+			UnitID:    unit.UnitId,
+			Condition: strings.TrimSpace(unit.Condition),
+			Notes:     unit.Notes,
+		})
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rentals, err := app.Repo.HandOverBookingUnits(timeoutCtx, requestPayload.UserId, requestPayload.BookingId, handovers)
+	if err != nil {
+		app.errorJSON(w, err, nil, unitErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "units handed over successfully",
+		Data:       rentals,
+	})
+}
+
+// ReturnBookingUnits records units coming back from a booking and makes them available again, or
+// sends them to maintenance. Without units every unit still out on the booking is returned.
+func (app *Config) ReturnBookingUnits(w http.ResponseWriter, r *http.Request) {
+	requestPayload, ok := app.readBookingUnits(w, r)
+	if !ok {
+		return
+	}
+
+	returns := make([]data.UnitReturn, 0, len(requestPayload.Units))
+	for _, unit := range requestPayload.Units {
+		returns = append(returns, data.UnitReturn{
+			UnitID:      unit.UnitId,
+			Condition:   strings.TrimSpace(unit.Condition),
+			Notes:       unit.Notes,
+			Maintenance: unit.Maintenance,
+		})
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rentals, err := app.Repo.ReturnBookingUnits(timeoutCtx, requestPayload.UserId, requestPayload.BookingId, returns)
+	if err != nil {
+		app.errorJSON(w, err, nil, unitErrorStatus(err))
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "units returned successfully",
+		Data:       rentals,
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+func TestReadBookingUnits(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		units int
+		ok    bool
+	}{
+		{"units", `{"user_id": "u1", "booking_id": "b1", "units": [{"unit_id": "a"}, {"unit_id": "b"}]}`, 2, true},
+		{"no units", `{"user_id": "u1", "booking_id": "b1"}`, 0, true},
+		{"missing user_id", `{"booking_id": "b1", "units": [{"unit_id": "a"}]}`, 0, false},
+		{"missing booking_id", `{"user_id": "u1", "units": [{"unit_id": "a"}]}`, 0, false},
+		{"unit without an id", `{"user_id": "u1", "booking_id": "b1", "units": [{"condition": "good"}]}`, 0, false},
+		{"unit twice", `{"user_id": "u1", "booking_id": "b1", "units": [{"unit_id": "a"}, {"unit_id": "a"}]}`, 0, false},
+		{"not json", `units`, 0, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/hand-over-booking-units", strings.NewReader(tt.body))
+
+		payload, ok := testApp.readBookingUnits(w, r)
+		if ok != tt.ok {
+			t.Errorf("%s: got ok %v; want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d; want %d", tt.name, w.Code, http.StatusBadRequest)
+		}
+		if ok && len(payload.Units) != tt.units {
+			t.Errorf("%s: got %d units; want %d", tt.name, len(payload.Units), tt.units)
+		}
+	}
+}
+
+func TestHandOverBookingUnits(t *testing.T) {
+	repo := data.NewPostgresTestRepository(nil)
+	app := Config{Repo: repo}
+
+	status, response := postJSON(t, app.HandOverBookingUnits, `{"user_id": "owner", "booking_id": "booking", "units": [{"unit_id": "drill-1", "condition": " scratched "}]}`)
+	if status != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", status, response.Message)
+	}
+	if len(repo.Handovers) != 1 || repo.Handovers[0][0] != (data.UnitHandover{UnitID: "drill-1", Condition: "scratched"}) {
+		t.Errorf("got handovers %+v", repo.Handovers)
+	}
+
+	// a handover needs units, unlike a return
+	if status, _ := postJSON(t, app.HandOverBookingUnits, `{"user_id": "owner", "booking_id": "booking"}`); status != http.StatusBadRequest || len(repo.Handovers) != 1 {
+		t.Errorf("got status %d after %d handovers", status, len(repo.Handovers))
+	}
+
+	// units that can not go out are the request's fault, anything else the service's
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{data.ErrUnitUnavailable, http.StatusBadRequest},
+		{data.ErrUnitNotFound, http.StatusBadRequest},
+		{data.ErrTooManyUnits, http.StatusBadRequest},
+		{data.ErrBookingNotFound, http.StatusBadRequest},
+		{data.ErrBookingNotAccepted, http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		repo.UnitErr = tt.err
+		if status, _ := postJSON(t, app.HandOverBookingUnits, `{"user_id": "owner", "booking_id": "booking", "units": [{"unit_id": "drill-1"}]}`); status != tt.status {
+			t.Errorf("%v: got status %d; want %d", tt.err, status, tt.status)
+		}
+	}
+}
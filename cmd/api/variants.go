@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/obynonwane/inventory-service/data"
+)
+
+type CreateInventoryVariantPayload struct {
+	UserId       string            `json:"user_id"`
+	InventoryId  string            `json:"inventory_id" binding:"required"`
+	Name         string            `json:"name" binding:"required"`
+	Attributes   map[string]string `json:"attributes"`
+	Quantity     float64           `json:"quantity"`
+	OfferPrice   float64           `json:"offer_price" binding:"required"`
+	MinimumPrice float64           `json:"minimum_price"`
+	ImageId      *string           `json:"image_id"` // an image from the listing's gallery
+}
+
+type UpdateInventoryVariantPayload struct {
+	UserId       string            `json:"user_id"`
+	InventoryId  string            `json:"inventory_id" binding:"required"`
+	VariantId    string            `json:"variant_id" binding:"required"`
+	Name         *string           `json:"name"`
+	Attributes   map[string]string `json:"attributes"`
+	Quantity     *float64          `json:"quantity"`
+	OfferPrice   *float64          `json:"offer_price"`
+	MinimumPrice *float64          `json:"minimum_price"`
+	ImageId      *string           `json:"image_id"` // "" removes the image
+}
+
+type InventoryVariantPayload struct {
+	UserId      string `json:"user_id"`
+	InventoryId string `json:"inventory_id" binding:"required"`
+	VariantId   string `json:"variant_id"`
+}
+
+func (app *Config) CreateInventoryVariant(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload CreateInventoryVariantPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	if requestPayload.Name == "" {
+		app.errorJSON(w, errors.New("variant name is required"), nil)
+		return
+	}
+	if err := validateVariantStock(requestPayload.Quantity, requestPayload.OfferPrice, requestPayload.MinimumPrice); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+	if len(inv.Components) > 0 {
+		app.errorJSON(w, errors.New("a bundle can not have variants"), nil)
+		return
+	}
+
+	attributes := requestPayload.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	variant, err := app.Repo.CreateInventoryVariant(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), &data.InventoryVariant{
+		InventoryId:  inv.ID,
+		Name:         requestPayload.Name,
+		Attributes:   attributes,
+		Quantity:     requestPayload.Quantity,
+		OfferPrice:   requestPayload.OfferPrice,
+		MinimumPrice: requestPayload.MinimumPrice,
+		ImageId:      requestPayload.ImageId,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	// variant quantities add up to the listing's stock
+	go app.checkInventoryStock(inv.ID)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "variant created successfully",
+		Data:       variant,
+	})
+}
+
+func (app *Config) UpdateInventoryVariant(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload UpdateInventoryVariantPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	current, err := app.Repo.GetInventoryVariant(timeoutCtx, inv.ID, requestPayload.VariantId)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	// validate the variant as it will be after the update
+	quantity, offerPrice, minimumPrice := current.Quantity, current.OfferPrice, current.MinimumPrice
+	if requestPayload.Quantity != nil {
+		quantity = *requestPayload.Quantity
+	}
+	if requestPayload.OfferPrice != nil {
+		offerPrice = *requestPayload.OfferPrice
+	}
+	if requestPayload.MinimumPrice != nil {
+		minimumPrice = *requestPayload.MinimumPrice
+	}
+	if err := validateVariantStock(quantity, offerPrice, minimumPrice); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+	if requestPayload.Name != nil && *requestPayload.Name == "" {
+		app.errorJSON(w, errors.New("variant name is required"), nil)
+		return
+	}
+
+	variant, err := app.Repo.UpdateInventoryVariant(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), data.UpdateInventoryVariantParams{
+		InventoryId:  inv.ID,
+		VariantId:    current.ID,
+		Name:         requestPayload.Name,
+		Attributes:   requestPayload.Attributes,
+		Quantity:     requestPayload.Quantity,
+		OfferPrice:   requestPayload.OfferPrice,
+		MinimumPrice: requestPayload.MinimumPrice,
+		ImageId:      requestPayload.ImageId,
+	})
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	// variant quantities add up to the listing's stock
+	go app.checkInventoryStock(inv.ID)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "variant updated successfully",
+		Data:       variant,
+	})
+}
+
+func (app *Config) DeleteInventoryVariant(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload InventoryVariantPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	inv, status, err := app.getOwnedInventory(timeoutCtx, requestPayload.InventoryId, requestPayload.UserId)
+	if err != nil {
+		app.errorJSON(w, err, nil, status)
+		return
+	}
+
+	if err := app.Repo.DeleteInventoryVariant(data.WithChangeActor(timeoutCtx, requestPayload.UserId, data.ChangeSourceHTTP), inv.ID, requestPayload.VariantId); err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	// variant quantities add up to the listing's stock
+	go app.checkInventoryStock(inv.ID)
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "variant deleted successfully",
+	})
+}
+
+// GetInventoryVariants lists the variants of a listing; it is public like the listing itself
+func (app *Config) GetInventoryVariants(w http.ResponseWriter, r *http.Request) {
+
+	//extract the request body
+	var requestPayload InventoryVariantPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	variants, err := app.Repo.GetInventoryVariants(timeoutCtx, requestPayload.InventoryId)
+	if err != nil {
+		app.errorJSON(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:      false,
+		StatusCode: http.StatusAccepted,
+		Message:    "variants retrieved successfully",
+		Data:       variants,
+	})
+}
+
+// variantTerms are the price bounds and stock a booking or order is checked against
+type variantTerms struct {
+	VariantId    *string
+	OfferPrice   float64
+	MinimumPrice float64
+	Quantity     float64
+}
+
+// resolveVariantTerms picks the terms for a booking or order. A listing with variants must be
+// booked through one of them; a listing without variants uses its own price and quantity.
+func resolveVariantTerms(inv *data.Inventory, variantID string) (*variantTerms, error) {
+	if len(inv.Variants) == 0 {
+		if variantID != "" {
+			return nil, errors.New("this inventory has no variants")
+		}
+		return &variantTerms{OfferPrice: inv.OfferPrice, MinimumPrice: inv.MinimumPrice, Quantity: inv.Quantity}, nil
+	}
+
+	if variantID == "" {
+		return nil, errors.New("variant_id is required for this inventory")
+	}
+
+	for _, v := range inv.Variants {
+		if v.ID == variantID {
+			return &variantTerms{VariantId: &v.ID, OfferPrice: v.OfferPrice, MinimumPrice: v.MinimumPrice, Quantity: v.Quantity}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("variant %s does not belong to this inventory", variantID)
+}
+
+func validateVariantStock(quantity, offerPrice, minimumPrice float64) error {
+	if quantity < 0 || offerPrice < 0 || minimumPrice < 0 {
+		return errors.New("quantity and prices can not be negative")
+	}
+	if minimumPrice > offerPrice {
+		return errors.New("minimum price can not be more than offer price")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+// bookingTestTables are the columns of the tables bookings read and write, as temporary tables so
+// the test leaves nothing behind
+const bookingTestTables = `
+	CREATE TEMP TABLE inventories (
+		id UUID PRIMARY KEY, name TEXT NOT NULL, slug TEXT NOT NULL, primary_image TEXT,
+		quantity NUMERIC NOT NULL, is_available TEXT NOT NULL DEFAULT 'yes',
+		deleted BOOLEAN NOT NULL DEFAULT false, deactivated BOOLEAN NOT NULL DEFAULT false
+	);
+	CREATE TEMP TABLE inventory_bundle_components (
+		bundle_id UUID NOT NULL, component_id UUID NOT NULL, quantity INT NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE TEMP TABLE inventory_bookings (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(), inventory_id UUID NOT NULL, variant_id UUID,
+		renter_id UUID NOT NULL, owner_id UUID NOT NULL, start_date DATE NOT NULL, end_date DATE NOT NULL,
+		start_time TEXT, end_time TEXT, offer_price_per_unit NUMERIC, total_amount NUMERIC, security_deposit NUMERIC,
+		quantity INT NOT NULL, status TEXT NOT NULL DEFAULT 'pending', payment_status TEXT NOT NULL DEFAULT 'pending',
+		rental_type TEXT, rental_duration INT, created_at TIMESTAMP, updated_at TIMESTAMP
+	);
+	CREATE TEMP TABLE inventory_booking_components (
+		booking_id UUID NOT NULL, inventory_id UUID NOT NULL, quantity INT NOT NULL
+	);`
+
+// openTestDB connects to the database in TEST_DATABASE_URL and creates tables in it, or skips the
+// test when there is none
+func openTestDB(t *testing.T, tables string) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// temporary tables belong to one session, so every query has to use the same connection
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(tables); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// a listing that is part of a bundle shares its stock with the bundle's bookings, whichever is booked first
+func TestBundleAndComponentBookingsOverlap(t *testing.T) {
+	db := openTestDB(t, bookingTestTables)
+	repo := &PostgresRepository{Conn: db}
+	ctx := context.Background()
+
+	const (
+		owner     = "7a937e9d-1dc2-4e6d-ba38-d1648b05730c"
+		renter    = "01197718-a7a9-4af8-9870-661e17cd0d81"
+		drill     = "1f426485-e2ad-4f1b-839f-5714dea928ff"
+		toolKit   = "e933c064-2c82-46a4-8c76-bef9558001d8"
+		otherTool = "15abc220-967b-44cb-9e95-183b63571e88"
+	)
+	_, err := db.Exec(`
+		INSERT INTO inventories (id, name, slug, quantity)
+		VALUES ($1, 'Drill', 'drill', 1), ($2, 'Tool kit', 'tool-kit', 0), ($3, 'Saw', 'saw', 5)
+	`, drill, toolKit, otherTool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO inventory_bundle_components (bundle_id, component_id, quantity) VALUES ($1, $2, 1), ($1, $3, 1)
+	`, toolKit, drill, otherTool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(n int) time.Time {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day()+n, 0, 0, 0, 0, time.UTC)
+	}
+	book := func(create func(context.Context, *CreateBookingPayload) (*InventoryBooking, error), inventoryID string, start, end int) error {
+		_, err := create(ctx, &CreateBookingPayload{
+			OwnerId: owner, RenterId: renter, InventoryId: inventoryID, RentalType: "daily", RentalDuration: int32(end - start + 1),
+			Quantity: 1, StartDate: day(start), EndDate: day(end), StartTime: "09:00", EndTime: "18:00",
+		})
+		return err
+	}
+
+	tests := []struct {
+		name   string
+		create func(context.Context, *CreateBookingPayload) (*InventoryBooking, error)
+		id     string
+		start  int
+		end    int
+		err    error
+	}{
+		{"the drill on its own", repo.CreateBooking, drill, 10, 12, nil},
+		{"the kit while the drill is out", repo.CreateBundleBooking, toolKit, 12, 13, ErrBundleUnavailable},
+		{"the kit once the drill is back", repo.CreateBundleBooking, toolKit, 13, 14, nil},
+		{"the drill while the kit is out", repo.CreateBooking, drill, 14, 15, ErrComponentUnavailable},
+		{"the drill after the kit", repo.CreateBooking, drill, 15, 16, nil},
+		// the saw is in the kit too, but has stock to spare
+		{"the saw while the kit is out", repo.CreateBooking, otherTool, 13, 14, nil},
+	}
+
+	for _, tt := range tests {
+		if err := book(tt.create, tt.id, tt.start, tt.end); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v; want %v", tt.name, err, tt.err)
+		}
+	}
+}
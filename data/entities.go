@@ -94,9 +94,14 @@ type Inventory struct {
 	Condition       *wrapperspb.StringValue `json:"condition"`
 	Included        *wrapperspb.StringValue `json:"included"`
 	Visibility      string                  `json:"visibility"`
-
-	Images []InventoryImage `json:"images"` // One-to-many relationship
-	User   User             `json:"user,omitempty"`
+	PublishAt       *time.Time              `json:"publish_at,omitempty"` // not live before this
+	ExpiresAt       *time.Time              `json:"expires_at,omitempty"` // not live from this on
+
+	Images   []InventoryImage   `json:"images"` // One-to-many relationship
+	Variants []InventoryVariant `json:"variants"`
+	// Components are the listings a bundle is made up of; empty for other listings
+	Components []BundleComponent `json:"components,omitempty"`
+	User       User              `json:"user,omitempty"`
 	// Add the following:
 	Country *Country `json:"country"`
 	State   *State   `json:"state"`
@@ -109,12 +114,39 @@ type Inventory struct {
 }
 
 type InventoryImage struct {
-	ID          string    `json:"id"`
-	LiveUrl     string    `json:"live_url"`
-	LocalUrl    string    `json:"local_url"`
-	InventoryId string    `json:"inventory_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	LiveUrl      string    `json:"live_url"`
+	LocalUrl     string    `json:"local_url"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+	CardUrl      string    `json:"card_url"`
+	FullUrl      string    `json:"full_url"`
+	InventoryId  string    `json:"inventory_id"`
+	Position     int32     `json:"position"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// InventoryVariant is one bookable option of a listing (e.g. a large green tent) with its own stock and price
+type InventoryVariant struct {
+	ID           string            `json:"id"`
+	InventoryId  string            `json:"inventory_id"`
+	Name         string            `json:"name"`
+	Attributes   map[string]string `json:"attributes"`
+	Quantity     float64           `json:"quantity"`
+	OfferPrice   float64           `json:"offer_price"`
+	MinimumPrice float64           `json:"minimum_price"`
+	ImageId      *string           `json:"image_id"`
+	ImageUrl     *string           `json:"image_url"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// ImageURLs are the stored derivatives of one uploaded image
+type ImageURLs struct {
+	Thumbnail string `json:"thumbnail"`
+	Card      string `json:"card"`
+	Full      string `json:"full"`
+	Hash      *int64 `json:"-"` // perceptual hash, used for duplicate detection
 }
 
 type InventoryRating struct {
@@ -198,6 +230,7 @@ type Lga struct {
 type InventoryBooking struct {
 	ID                string           `json:"id"`
 	InventoryID       string           `json:"inventory_id"`
+	VariantID         *string          `json:"variant_id"`
 	RenterID          string           `json:"renter_id"`
 	OwnerID           string           `json:"owner_id"`
 	StartDate         time.Time        `json:"start_date"`           // just the date part
@@ -230,6 +263,7 @@ type InventoryBooking struct {
 type InventorySale struct {
 	ID                string           `json:"id"`
 	InventoryID       string           `json:"inventory_id,omitempty"`
+	VariantID         *string          `json:"variant_id,omitempty"`
 	SellerID          string           `json:"seller_id,omitempty"`
 	BuyerID           *string          `json:"buyer_id,omitempty"` // Nullable
 	OfferPricePerUnit float64          `json:"offer_price_per_unit,omitempty"`
@@ -437,3 +471,402 @@ type TrackHelpfulInventoryRating struct {
 	CreatedAt time.Time `json:"created_at"`          // Timestamp of creation
 	UpdatedAt time.Time `json:"updated_at"`          // Timestamp of last update
 }
+
+// InventoryJob tracks one asynchronous CreateInventory request from upload to insert
+type InventoryJob struct {
+	ID          string              `json:"id"`
+	UserID      string              `json:"user_id"`
+	Status      string              `json:"status"` // queued, uploading, saving, succeeded, failed
+	Request     string              `json:"-"`      // CreateInventoryRequest as JSON, without image bytes
+	Error       *string             `json:"error"`  // why the last attempt failed
+	InventoryID *string             `json:"inventory_id"`
+	Attempts    int32               `json:"attempts"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Images      []InventoryJobImage `json:"images"`
+}
+
+type InventoryJobImage struct {
+	ID           string    `json:"id"`
+	JobID        string    `json:"job_id"`
+	Position     int32     `json:"position"`
+	IsPrimary    bool      `json:"is_primary"`
+	ImageType    string    `json:"image_type"`
+	ImageData    []byte    `json:"-"`
+	Status       string    `json:"status"` // pending, uploaded, failed
+	URL          *string   `json:"url"`    // full derivative
+	ThumbnailURL *string   `json:"thumbnail_url"`
+	CardURL      *string   `json:"card_url"`
+	Hash         *int64    `json:"-"`
+	Error        *string   `json:"error"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// InventoryImportBatch is one bulk upload of listings from a CSV or XLSX file
+type InventoryImportBatch struct {
+	ID        string               `json:"id"`
+	UserID    string               `json:"user_id"`
+	Filename  string               `json:"filename"`
+	Status    string               `json:"status"` // queued, running, completed
+	TotalRows int32                `json:"total_rows"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Rows      []InventoryImportRow `json:"rows"`
+}
+
+type InventoryImportRow struct {
+	ID          string    `json:"id"`
+	BatchID     string    `json:"batch_id"`
+	RowNumber   int32     `json:"row_number"`
+	Name        string    `json:"name"`
+	Request     string    `json:"-"` // CreateInventoryRequest as JSON, without images
+	ImageURLs   []string  `json:"image_urls"`
+	Status      string    `json:"status"` // queued, submitted, failed
+	Error       *string   `json:"error"`
+	JobID       *string   `json:"job_id"`
+	JobStatus   *string   `json:"job_status"` // status of the creation job once submitted
+	InventoryID *string   `json:"inventory_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// InventoryExportRow is one line of a listings export. The json names are also the csv columns.
+type InventoryExportRow struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Slug            string    `json:"slug"`
+	ProductPurpose  string    `json:"product_purpose"`
+	CategorySlug    string    `json:"category_slug"`
+	SubcategorySlug string    `json:"subcategory_slug"`
+	StateSlug       string    `json:"state_slug"`
+	LgaSlug         string    `json:"lga_slug"`
+	OfferPrice      float64   `json:"offer_price"`
+	MinimumPrice    float64   `json:"minimum_price"`
+	SecurityDeposit float64   `json:"security_deposit"`
+	Quantity        float64   `json:"quantity"`
+	RentalDuration  string    `json:"rental_duration"`
+	IsAvailable     string    `json:"is_available"`
+	Negotiable      string    `json:"negotiable"`
+	Visibility      string    `json:"visibility"`
+	Deactivated     bool      `json:"deactivated"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// BookingExportRow is one line of a bookings export
+type BookingExportRow struct {
+	ID                string    `json:"id"`
+	InventoryID       string    `json:"inventory_id"`
+	InventoryName     string    `json:"inventory_name"`
+	VariantID         string    `json:"variant_id"`
+	RenterID          string    `json:"renter_id"`
+	RenterName        string    `json:"renter_name"`
+	OwnerID           string    `json:"owner_id"`
+	OwnerName         string    `json:"owner_name"`
+	StartDate         string    `json:"start_date"`
+	StartTime         string    `json:"start_time"`
+	EndDate           string    `json:"end_date"`
+	EndTime           string    `json:"end_time"`
+	RentalType        string    `json:"rental_type"`
+	RentalDuration    float64   `json:"rental_duration"`
+	Quantity          float64   `json:"quantity"`
+	OfferPricePerUnit float64   `json:"offer_price_per_unit"`
+	SecurityDeposit   float64   `json:"security_deposit"`
+	TotalAmount       float64   `json:"total_amount"`
+	Status            string    `json:"status"`
+	PaymentStatus     string    `json:"payment_status"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// SaleExportRow is one line of a purchases export
+type SaleExportRow struct {
+	ID                string    `json:"id"`
+	InventoryID       string    `json:"inventory_id"`
+	InventoryName     string    `json:"inventory_name"`
+	VariantID         string    `json:"variant_id"`
+	BuyerID           string    `json:"buyer_id"`
+	BuyerName         string    `json:"buyer_name"`
+	SellerID          string    `json:"seller_id"`
+	SellerName        string    `json:"seller_name"`
+	Quantity          float64   `json:"quantity"`
+	OfferPricePerUnit float64   `json:"offer_price_per_unit"`
+	TotalAmount       float64   `json:"total_amount"`
+	Status            string    `json:"status"`
+	PaymentStatus     string    `json:"payment_status"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// DuplicateCandidate is one of a seller's live listings, with what duplicate detection compares
+type DuplicateCandidate struct {
+	ID          string
+	Name        string
+	Description string
+	ImageHashes []int64
+}
+
+// DuplicateMatch is a listing found to look like the one being created or updated
+type DuplicateMatch struct {
+	InventoryID    string  `json:"inventory_id"`
+	Name           string  `json:"name"`
+	TextSimilarity float64 `json:"text_similarity"`
+	ImageDistance  *int32  `json:"image_distance"` // bits between the closest pair of images, nil when either has none
+}
+
+// InventoryDuplicateFlag records that a listing was held for review as a likely duplicate
+type InventoryDuplicateFlag struct {
+	ID              string     `json:"id"`
+	InventoryID     string     `json:"inventory_id"`
+	DuplicateOfID   string     `json:"duplicate_of_id"`
+	DuplicateOfName string     `json:"duplicate_of_name"`
+	TextSimilarity  float64    `json:"text_similarity"`
+	ImageDistance   *int32     `json:"image_distance"`
+	Status          string     `json:"status"` // open, cleared
+	CreatedAt       time.Time  `json:"created_at"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+}
+
+// InventoryHistoryEntry is one change to a listing. Changes maps each changed field to its
+// values before and after; a created listing has no before values.
+type InventoryHistoryEntry struct {
+	ID          string                 `json:"id"`
+	InventoryID string                 `json:"inventory_id"`
+	Action      string                 `json:"action"`
+	Changes     map[string]FieldChange `json:"changes"`
+	ActorID     *string                `json:"actor_id"`
+	Source      string                 `json:"source"` // http, grpc, admin, system
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// DeletedInventory is a soft-deleted listing that its owner can still restore
+type DeletedInventory struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Slug          string    `json:"slug"`
+	PrimaryImage  string    `json:"primary_image"`
+	DeletedAt     time.Time `json:"deleted_at"`
+	RestoreBefore time.Time `json:"restore_before"` // when the purge job may remove it
+}
+
+// Notification is a message for a user from this service, e.g. that a listing is about to expire
+type Notification struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Kind        string     `json:"kind"`
+	InventoryID *string    `json:"inventory_id"`
+	Message     string     `json:"message"`
+	ReadAt      *time.Time `json:"read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// PostingQuota is what is left of a user's listing allowance. PendingPostings have already been
+// taken by listings that are still being created and are refunded if their creation fails.
+type PostingQuota struct {
+	UserID            string     `json:"user_id"`
+	AvailablePostings int32      `json:"available_postings"`
+	PendingPostings   int32      `json:"pending_postings"`
+	Plan              *string    `json:"plan"`
+	Status            *string    `json:"status"`
+	EndDate           *time.Time `json:"end_date"`
+}
+
+// InventoryPromotion is a paid boost of a listing in one placement for a period. Scope is category,
+// state or homepage; ScopeValue is the listing's category or state slug.
+type InventoryPromotion struct {
+	ID               string    `json:"id"`
+	InventoryID      string    `json:"inventory_id"`
+	UserID           string    `json:"user_id"`
+	Scope            string    `json:"scope"`
+	ScopeValue       *string   `json:"scope_value"`
+	StartsAt         time.Time `json:"starts_at"`
+	EndsAt           time.Time `json:"ends_at"`
+	Amount           float64   `json:"amount"`
+	PaymentReference string    `json:"payment_reference"`
+	Impressions      int64     `json:"impressions"`
+	Clicks           int64     `json:"clicks"`
+	Active           bool      `json:"active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// SubcategoryAttribute defines one field of the metadata of listings in a subcategory. Type is
+// text, number, boolean or enum; enum values, and text values when set, must be in AllowedValues.
+type SubcategoryAttribute struct {
+	ID            string    `json:"id"`
+	SubcategoryID string    `json:"subcategory_id"`
+	Name          string    `json:"name"`
+	Label         string    `json:"label"`
+	Type          string    `json:"type"`
+	Unit          *string   `json:"unit"`
+	AllowedValues []string  `json:"allowed_values"`
+	Required      bool      `json:"required"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AttributeFilter narrows a search to listings whose attribute Name compares to Value with Op,
+// one of = != > >= < <=. Number and Bool are Value read as a number or a yes/no, when it is one;
+// they are what number and boolean attributes are compared with.
+type AttributeFilter struct {
+	Name   string
+	Op     string
+	Value  string
+	Number *float64
+	Bool   *bool
+}
+
+// TagCount is a tag with the number of live listings that have it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// InventoryStats counts the events of a listing, each once per visitor per day
+type InventoryStats struct {
+	Views       int64 `json:"views"`
+	Impressions int64 `json:"impressions"`
+	Saves       int64 `json:"saves"`
+	Chats       int64 `json:"chats"`
+	Bookings    int64 `json:"bookings"` // booking and purchase attempts
+}
+
+// InventoryDailyStats is one day of a listing's stats
+type InventoryDailyStats struct {
+	Day time.Time `json:"day"`
+	InventoryStats
+}
+
+// InventoryStatsSummary is a listing with its stats over a period
+type InventoryStatsSummary struct {
+	InventoryID string `json:"inventory_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	InventoryStats
+}
+
+// InventoryQuestion is a public question on a listing and the owner's answer to it
+type InventoryQuestion struct {
+	ID          string     `json:"id"`
+	InventoryID string     `json:"inventory_id"`
+	AskerID     string     `json:"asker_id"`
+	Question    string     `json:"question"`
+	Answer      *string    `json:"answer"`
+	AnsweredAt  *time.Time `json:"answered_at"`
+	ReportCount int32      `json:"report_count"`
+	Hidden      bool       `json:"hidden"`
+	HiddenBy    *string    `json:"hidden_by"` // owner or moderation
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// GeoPoint is a location in degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// SitemapEntry is one public page. Only the slugs its sitemap section uses are set.
+type SitemapEntry struct {
+	Slug         string
+	CategorySlug string
+	StateSlug    string
+	Subdomain    string
+	UpdatedAt    time.Time
+}
+
+// SitemapPage is one file of a sitemap section: the key of its first page, as
+// StreamSitemapEntries takes it, and when its latest page was updated
+type SitemapPage struct {
+	From    string
+	LastMod time.Time
+}
+
+// ProductFeedItem is a public sale listing in the product feed
+type ProductFeedItem struct {
+	ID              string
+	Name            string
+	Description     string
+	Slug            string
+	CategorySlug    string
+	SubcategorySlug string
+	StateSlug       string
+	CategoryName    string
+	SubcategoryName string
+	PrimaryImage    string
+	Condition       string
+	IsAvailable     string
+	OfferPrice      float64
+	Quantity        float64
+	UpdatedAt       time.Time
+}
+
+// InventoryUnit is one physical unit of a listing, such as a single camera of a camera listing
+type InventoryUnit struct {
+	ID           string    `json:"id"`
+	InventoryID  string    `json:"inventory_id"`
+	VariantID    *string   `json:"variant_id"`
+	SerialNumber *string   `json:"serial_number"`
+	AssetTag     *string   `json:"asset_tag"`
+	Condition    string    `json:"condition"`
+	Status       string    `json:"status"` // available, out, maintenance or retired
+	Notes        string    `json:"notes"`
+	BookingID    *string   `json:"booking_id"` // the booking the unit is out on
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// InventoryUnitRental is one handover of a unit for a booking, open until the unit is returned
+type InventoryUnitRental struct {
+	ID                string     `json:"id"`
+	UnitID            string     `json:"unit_id"`
+	BookingID         string     `json:"booking_id"`
+	RenterID          string     `json:"renter_id"`
+	StartDate         time.Time  `json:"start_date"`
+	EndDate           time.Time  `json:"end_date"`
+	ConditionOut      string     `json:"condition_out"`
+	ConditionReturned *string    `json:"condition_returned"`
+	Notes             string     `json:"notes"`
+	HandedOverAt      time.Time  `json:"handed_over_at"`
+	ReturnedAt        *time.Time `json:"returned_at"`
+}
+
+// StockLevel is how much of a listing can still be ordered or booked
+type StockLevel struct {
+	InventoryID       string   `json:"inventory_id"`
+	Quantity          float64  `json:"quantity"`
+	Committed         float64  `json:"committed"` // held by open orders and current bookings
+	Available         float64  `json:"available"`
+	LowStockThreshold *float64 `json:"low_stock_threshold"`
+	Alerted           string   `json:"alerted,omitempty"` // the alert this check sent, low_stock or sold_out
+}
+
+// BundleComponent is a listing that is part of a bundle, and how many of it one bundle takes
+type BundleComponent struct {
+	ComponentID  string `json:"component_id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	PrimaryImage string `json:"primary_image"`
+	Quantity     int32  `json:"quantity"`
+}
+
+// BundleComponentAvailability is how much of a component is free over a booking window
+type BundleComponentAvailability struct {
+	BundleComponent
+	Stock     float64 `json:"stock"`     // nothing when the component is unavailable, deactivated or deleted
+	Reserved  float64 `json:"reserved"`  // by bookings that overlap the window, made for it or for a bundle
+	Available float64 `json:"available"` // stock less reserved
+	Bundles   int32   `json:"bundles"`   // how many bundles the available stock covers
+}
+
+// BundleAvailability is how many of a bundle can be booked over a window, the fewest any of its components covers
+type BundleAvailability struct {
+	BundleID   string                        `json:"bundle_id"`
+	StartDate  time.Time                     `json:"start_date"`
+	EndDate    time.Time                     `json:"end_date"`
+	Available  int32                         `json:"available"`
+	Components []BundleComponentAvailability `json:"components"`
+}
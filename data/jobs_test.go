@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// jobTestTables are the columns creation jobs and their postings read and write
+const jobTestTables = `
+	CREATE TEMP TABLE user_subscriptions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(), user_id UUID NOT NULL, available_postings INT NOT NULL,
+		status TEXT NOT NULL, end_date TIMESTAMPTZ, created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TEMP TABLE inventory_creation_jobs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(), user_id UUID NOT NULL, status TEXT NOT NULL, request TEXT NOT NULL,
+		error TEXT, inventory_id UUID, attempts INT NOT NULL DEFAULT 0, posting_subscription_id UUID,
+		created_at TIMESTAMPTZ NOT NULL, updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE TEMP TABLE inventory_creation_job_images (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(), job_id UUID NOT NULL, position INT NOT NULL, is_primary BOOLEAN NOT NULL,
+		image_type TEXT, image_data BYTEA, status TEXT NOT NULL, url TEXT, thumbnail_url TEXT, card_url TEXT, phash BIGINT,
+		error TEXT, created_at TIMESTAMPTZ NOT NULL, updated_at TIMESTAMPTZ NOT NULL
+	);`
+
+// a job takes a posting when it is queued, gives it back once when it fails and keeps it once it
+// has created its listing
+func TestInventoryJobPostings(t *testing.T) {
+	db := openTestDB(t, jobTestTables)
+	repo := &PostgresRepository{Conn: db}
+	ctx := context.Background()
+
+	const owner = "7a937e9d-1dc2-4e6d-ba38-d1648b05730c"
+	if _, err := db.Exec(`INSERT INTO user_subscriptions (user_id, available_postings, status) VALUES ($1, 1, 'active')`, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	postings := func(step string, want int) {
+		t.Helper()
+		var got int
+		if err := db.QueryRow(`SELECT available_postings FROM user_subscriptions`).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("%s: %d postings left; want %d", step, got, want)
+		}
+	}
+
+	job, err := repo.CreateInventoryJob(ctx, &InventoryJob{UserID: owner, Request: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings("queued", 0)
+
+	if _, err := repo.CreateInventoryJob(ctx, &InventoryJob{UserID: owner, Request: "{}"}); !errors.Is(err, ErrPostingQuotaExceeded) {
+		t.Fatalf("queueing without postings: got %v; want %v", err, ErrPostingQuotaExceeded)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.FailInventoryJob(ctx, job.ID, "failed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	postings("failed twice", 1)
+
+	if err := repo.RetryInventoryJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	postings("retried", 0)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := completeInventoryJob(ctx, tx, job.ID, "1f426485-e2ad-4f1b-839f-5714dea928ff"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a late failure, such as a commit error after the commit went through, leaves the job alone
+	if err := repo.FailInventoryJob(ctx, job.ID, "failed"); err != nil {
+		t.Fatal(err)
+	}
+	postings("failed after succeeding", 0)
+
+	completed, err := repo.GetInventoryJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.Status != InventoryJobSucceeded || completed.InventoryID == nil {
+		t.Errorf("got status %s with inventory %v; want a succeeded job with its inventory", completed.Status, completed.InventoryID)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,6 +19,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/lib/pq"
+	"github.com/obynonwane/inventory-service/utility"
 	"github.com/obynonwane/rental-service-proto/inventory"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -28,13 +32,19 @@ var db *sql.DB
 
 type PostgresRepository struct {
 	Conn *sql.DB
+
+	earthDistance bool // whether radius search can use the earthdistance extension
 }
 
 // new instance of the PostgresRepository struct
 func NewPostgresRepository(db *sql.DB) *PostgresRepository {
-	return &PostgresRepository{
+	r := &PostgresRepository{
 		Conn: db,
 	}
+	if db != nil {
+		r.earthDistance = detectEarthDistance(db)
+	}
+	return r
 }
 
 func (p *PostgresRepository) BeginTransaction(ctx context.Context) (*sql.Tx, error) {
@@ -363,23 +373,27 @@ type CreateInventoryParams struct {
 	SubcategorySlug string
 	OfferPrice      float64
 	MinimumPrice    float64
-	URLs            []string
+	Images          []ImageURLs
 
 	ProductPurpose  string
 	Quantity        float64
 	IsAvailable     string
 	RentalDuration  string
 	SecurityDeposit float64
-	Tags            string
+	Tags            []string // normalised, see utility.NormalizeTags
 	Metadata        string
 	Negotiable      string
-	PrimaryImage    string
+	PrimaryImage    ImageURLs
 	Condition       string
 	UsageGuide      string
 	Included        string
+	PublishAt       *time.Time
+	ExpiresAt       *time.Time
+	Attributes      string // the metadata validated against the subcategory's attributes, as JSON
+	JobID           string // the creation job the listing comes from; it is completed in the same transaction
 }
 
-func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
+func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) (*Inventory, error) {
 
 	log.Printf("%v", req)
 
@@ -401,21 +415,27 @@ func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
 	countrySlug := req.CountrySlug
 	categorySlug := req.CategorySlug
 	subcategorySlug := req.SubcategorySlug
-	urls := req.URLs
+	images := req.Images
 
 	productPurpose := req.ProductPurpose
 	quantity := req.Quantity
 	isAvailable := req.IsAvailable
 	rentalDuration := req.RentalDuration
 	securityDeposit := req.SecurityDeposit
-	tags := req.Tags
+	tags := strings.Join(req.Tags, ", ")
 	metadata := req.Metadata
 	negotiable := req.Negotiable
-	primaryImage := req.PrimaryImage
+	primaryImage := req.PrimaryImage.Full
 	minimumPrice := req.MinimumPrice
 	usageGuide := req.UsageGuide
 	condition := req.Condition
 	included := req.Included
+	publishAt := req.PublishAt
+	expiresAt := req.ExpiresAt
+	attributes := req.Attributes
+	if attributes == "" {
+		attributes = "{}"
+	}
 
 	query := `INSERT INTO inventories (
 				name, 
@@ -448,10 +468,13 @@ func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
 				usage_guide,
 				condition,
 				included,
+				publish_at,
+				expires_at,
+				attributes,
 
 				updated_at, 
 				created_at)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8,$9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, NOW(), NOW()) 
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8,$9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, NOW(), NOW()) 
 			RETURNING 
 				id, 
 				name, 
@@ -525,6 +548,9 @@ func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
 		usageGuide,
 		condition,
 		included,
+		publishAt,
+		expiresAt,
+		attributes,
 	).Scan(
 		&inventory.ID,
 		&inventory.Name,
@@ -561,7 +587,7 @@ func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
 		&inventory.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create inventory: %w", err)
+		return nil, fmt.Errorf("failed to create inventory: %w", err)
 	}
 
 	if userTags.Valid {
@@ -587,34 +613,44 @@ func (u *PostgresRepository) CreateInventory(req *CreateInventoryParams) error {
 		inventory.Included = &wrapperspb.StringValue{}
 	}
 
+	// the primary image is filed as the first image so the gallery order and
+	// inventories.primary_image always agree
+	if primaryImage != "" {
+		images = append([]ImageURLs{req.PrimaryImage}, images...)
+	}
+
 	// Insert image URLs into a separate table
-	for _, url := range urls {
+	for position, img := range images {
 		imageQuery := `
-				INSERT INTO inventory_images (live_url, local_url, inventory_id, updated_at, created_at) 
-				VALUES ($1, $2, $3, NOW(), NOW())`
-		_, err := tx.ExecContext(ctx, imageQuery, url, url, inventory.ID)
+				INSERT INTO inventory_images (live_url, local_url, thumbnail_url, card_url, full_url, phash, inventory_id, position, updated_at, created_at) 
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())`
+		_, err := tx.ExecContext(ctx, imageQuery, img.Full, img.Full, img.Thumbnail, img.Card, img.Full, img.Hash, inventory.ID, position)
 		if err != nil {
-			return fmt.Errorf("failed to insert image URL: %w", err)
+			return nil, fmt.Errorf("failed to insert image URL: %w", err)
 		}
 	}
 
-	// Reduce the posting count on userSubscription
-	if inventory.UserId != "" {
-		updateUserSubscription := `
-		UPDATE user_subscriptions
-		SET available_postings = available_postings - 1
-		WHERE user_id = $1`
+	// the posting slot was taken when the creation job was queued, see CreateInventoryJob
 
-		_, err = tx.ExecContext(ctx, updateUserSubscription, inventory.UserId)
-		if err != nil {
-			log.Printf("%v", err)
-		}
+	storedTags, err := setInventoryTags(ctx, tx, inventory.ID, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Tags = wrapperspb.String(storedTags)
 
-	} else {
-		log.Printf("%s", "User ID cant be found to Update Usersubscriptions Table")
+	if err := recordInventoryChange(ctx, tx, inventory.ID, InventoryCreated, nil); err != nil {
+		return nil, err
 	}
 
-	return nil
+	// a job and its listing are saved together, so a crash can not leave a listing behind a job
+	// that still holds, or refunds, its posting
+	if req.JobID != "" {
+		if err := completeInventoryJob(ctx, tx, req.JobID, inventory.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &inventory, nil
 }
 
 func (u *PostgresRepository) GetInventoryByID(ctx context.Context, inventory_id string) (*Inventory, error) {
@@ -692,7 +728,7 @@ func (u *PostgresRepository) GetInventoryByID(ctx context.Context, inventory_id
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Println("no inventory found", err)
-			return nil, fmt.Errorf("no inventory found")
+			return nil, ErrInventoryNotFound
 		}
 
 		log.Println("no inventory found", err)
@@ -710,9 +746,10 @@ func (u *PostgresRepository) GetInventoryByID(ctx context.Context, inventory_id
 
 	// Fetch images for the single inventory
 	imgSQL := `
-		SELECT id, live_url, local_url, inventory_id, created_at, updated_at
+		SELECT id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
 		FROM inventory_images
 		WHERE inventory_id = ANY($1)
+		ORDER BY position, created_at
 	`
 
 	imgRows, err := u.Conn.QueryContext(ctx, imgSQL, pq.Array([]string{inventory.ID}))
@@ -726,8 +763,8 @@ func (u *PostgresRepository) GetInventoryByID(ctx context.Context, inventory_id
 		img := &InventoryImage{}
 		var createdAt, updatedAt time.Time
 		if err := imgRows.Scan(
-			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.InventoryId,
-			&createdAt, &updatedAt,
+			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+			&img.Position, &createdAt, &updatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan image: %w", err)
 		}
@@ -737,6 +774,18 @@ func (u *PostgresRepository) GetInventoryByID(ctx context.Context, inventory_id
 
 	inventory.Images = images
 
+	variants, err := getInventoryVariants(ctx, u.Conn, inventory.ID)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Variants = variants
+
+	components, err := getBundleComponents(ctx, u.Conn, inventory.ID)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Components = components
+
 	// inventory rating
 	// Average rating query for one inventory
 	ratingSQL := `
@@ -831,6 +880,9 @@ func (u *PostgresRepository) GetLgaByID(ctx context.Context, id string) (*Lga, e
 	return &lga, nil
 }
 
+// ErrInventoryNotFound is returned when no live listing has the id or slug asked for
+var ErrInventoryNotFound = errors.New("no inventory found")
+
 func (u *PostgresRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ulid, inventory_id string) (*Inventory, error) {
 	var (
 		query string
@@ -923,7 +975,7 @@ func (u *PostgresRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ul
 		if err == sql.ErrNoRows {
 
 			log.Println(err, "THE ERROR IN MODEL 0")
-			return nil, fmt.Errorf("no inventory found")
+			return nil, ErrInventoryNotFound
 		}
 
 		log.Println(err, "THE ERROR IN MODEL 1")
@@ -965,9 +1017,10 @@ func (u *PostgresRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ul
 	//============================================================================================================================
 	// Fetch images for the single inventory
 	imgSQL := `
-		SELECT id, live_url, local_url, inventory_id, created_at, updated_at
+		SELECT id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
 		FROM inventory_images
 		WHERE inventory_id = ANY($1)
+		ORDER BY position, created_at
 	`
 
 	imgRows, err := u.Conn.QueryContext(ctx, imgSQL, pq.Array([]string{inventory.ID}))
@@ -983,8 +1036,8 @@ func (u *PostgresRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ul
 		img := &InventoryImage{}
 		var createdAt, updatedAt time.Time
 		if err := imgRows.Scan(
-			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.InventoryId,
-			&createdAt, &updatedAt,
+			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+			&img.Position, &createdAt, &updatedAt,
 		); err != nil {
 
 			log.Println(err, "THE ERROR IN MODEL 3")
@@ -996,6 +1049,18 @@ func (u *PostgresRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ul
 
 	inventory.Images = images
 
+	variants, err := getInventoryVariants(ctx, u.Conn, inventory.ID)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Variants = variants
+
+	components, err := getBundleComponents(ctx, u.Conn, inventory.ID)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Components = components
+
 	//============================================================================================================================
 	// Average rating and count query for one inventory
 	ratingSQL := `
@@ -1230,6 +1295,9 @@ func (r *PostgresRepository) GetUserBySlug(ctx context.Context, slug string) (*U
 	return &user, nil
 }
 
+// ErrBusinessNotFound is returned when no business has the subdomain asked for
+var ErrBusinessNotFound = errors.New("no business found")
+
 func (u *PostgresRepository) GetBusinessBySubdomain(ctx context.Context, domain string) (*BusinessKyc, error) {
 
 	query := `
@@ -1293,7 +1361,7 @@ func (u *PostgresRepository) GetBusinessBySubdomain(ctx context.Context, domain
 	if err != nil {
 		log.Printf("%v", err)
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no business found with subdomain %s", domain)
+			return nil, fmt.Errorf("%w with subdomain %s", ErrBusinessNotFound, domain)
 		}
 
 		log.Printf("%v", err)
@@ -1765,10 +1833,13 @@ func (u *PostgresRepository) CreateUserRatingReply(ctx context.Context, param *R
 
 // InventoryCollection matches your proto message.
 type InventoryCollection struct {
-	Inventories []*inventory.Inventory
-	TotalCount  int32
-	Offset      int32
-	Limit       int32
+	Inventories    []*inventory.Inventory
+	TotalCount     int32
+	Offset         int32
+	Limit          int32
+	DuplicateFlags map[string][]InventoryDuplicateFlag `json:",omitempty"` // open flags by inventory id, admin listings only
+	Promotions     map[string]string                   `json:",omitempty"` // promotion shown by inventory id, search only
+	Distances      map[string]float64                  `json:",omitempty"` // metres from SearchPayload.Near by inventory id
 }
 
 type SearchPayload struct {
@@ -1791,6 +1862,74 @@ type SearchPayload struct {
 	ProductPurpose  string `json:"product_purpose"`
 	UserSlug        string `json:"user_slug"`
 	Subdomain       string `json:"subdomain"`
+
+	Attributes []AttributeFilter `json:"-"` // parsed from attr.name>=value terms in the search text
+	Tags       []string          `json:"-"` // listings must have every one of these tags
+
+	Near         *GeoPoint `json:"-"` // listings within RadiusMeters of this point, nearest first unless sorted otherwise
+	RadiusMeters float64   `json:"-"`
+
+	Sort string `json:"sort"` // one of the Sort constants; running promotions first, or nearest first near a point, when empty
+}
+
+// The orders SearchInventory can sort listings in
+const (
+	SortRelevance   = "relevance" // how well the name and description match the search text
+	SortPriceAsc    = "price_asc"
+	SortPriceDesc   = "price_desc"
+	SortRating      = "rating" // highest average rating first
+	SortRatingCount = "rating_count"
+	SortNewest      = "newest"
+	SortNearest     = "nearest"
+	SortPromoted    = "promoted"
+)
+
+// ErrInvalidSearchSort is returned when a search is sorted in an unknown order, or in one it can not be sorted in
+var ErrInvalidSearchSort = errors.New("invalid sort")
+
+// searchDocumentSQL is the text of a listing that full text search matches
+const searchDocumentSQL = `to_tsvector('english', coalesce(l.name, '') || ' ' || coalesce(l.description, ''))`
+
+// searchOrderBy returns the ORDER BY of a search; textIdx is the argument holding the search text, 0
+// without one. Every order ends with the listing's creation time and id, so the pages of a search
+// neither repeat nor skip listings.
+func searchOrderBy(p *SearchPayload, textIdx int) (string, error) {
+	const tieBreak = "l.created_at DESC, l.id DESC"
+
+	order := p.Sort
+	if order == "" {
+		order = SortPromoted
+		if p.Near != nil {
+			order = SortNearest
+		}
+	}
+
+	switch order {
+	case SortRelevance:
+		if textIdx == 0 {
+			return "", fmt.Errorf("%w: sorting by relevance needs search text", ErrInvalidSearchSort)
+		}
+		return fmt.Sprintf("ts_rank(%s, websearch_to_tsquery('english', $%d)) DESC, %s", searchDocumentSQL, textIdx, tieBreak), nil
+	case SortPriceAsc:
+		return "l.offer_price ASC NULLS LAST, " + tieBreak, nil
+	case SortPriceDesc:
+		return "l.offer_price DESC NULLS LAST, " + tieBreak, nil
+	case SortRating:
+		return "COALESCE(rs.rating_average, 0) DESC, COALESCE(rs.rating_count, 0) DESC, " + tieBreak, nil
+	case SortRatingCount:
+		return "COALESCE(rs.rating_count, 0) DESC, COALESCE(rs.rating_average, 0) DESC, " + tieBreak, nil
+	case SortNewest:
+		return tieBreak, nil
+	case SortNearest:
+		if p.Near == nil {
+			return "", fmt.Errorf("%w: sorting by distance needs a near: point", ErrInvalidSearchSort)
+		}
+		return "distance, " + tieBreak, nil
+	case SortPromoted:
+		return "pr.id IS NOT NULL DESC, " + tieBreak, nil
+	}
+
+	return "", fmt.Errorf("%w: %q is not a sort order", ErrInvalidSearchSort, p.Sort)
 }
 
 type GetCategoryByIDPayload struct {
@@ -1832,6 +1971,9 @@ func (r *PostgresRepository) SearchInventory(
 
 	// Always filter out deleted inventories
 	conditions = append(conditions, "l.deleted = false")
+	// and those that are scheduled for later or have expired
+	conditions = append(conditions, "(l.publish_at IS NULL OR l.publish_at <= NOW())")
+	conditions = append(conditions, "(l.expires_at IS NULL OR l.expires_at > NOW())")
 	conditions = append(conditions, fmt.Sprintf("l.visibility = $%d::visibility_enum", argIdx))
 	args = append(args, "public")
 	argIdx++
@@ -1851,13 +1993,16 @@ func (r *PostgresRepository) SearchInventory(
 		args = append(args, p.LgaID)
 		argIdx++
 	}
+	textIdx := 0
 	if p.Text != "" {
 		conditions = append(conditions, fmt.Sprintf(`
-		(to_tsvector('english', coalesce(l.name, '') || ' ' || coalesce(l.description, '')) @@ websearch_to_tsquery('english', $%d)
+		(%s @@ websearch_to_tsquery('english', $%d)
 		OR l.name ILIKE '%%' || $%d || '%%'
-		OR l.description ILIKE '%%' || $%d || '%%')
-	`, argIdx, argIdx, argIdx))
+		OR l.description ILIKE '%%' || $%d || '%%'
+		OR EXISTS (SELECT 1 FROM inventory_variants v WHERE v.inventory_id = l.id AND v.name ILIKE '%%' || $%d || '%%'))
+	`, searchDocumentSQL, argIdx, argIdx, argIdx, argIdx))
 		args = append(args, p.Text)
+		textIdx = argIdx
 		argIdx++
 	}
 	if p.CategoryID != "" {
@@ -1910,12 +2055,64 @@ func (r *PostgresRepository) SearchInventory(
 		args = append(args, p.ProductPurpose)
 		argIdx++
 	}
+	for _, f := range p.Attributes {
+		// stored values are typed by their attribute, so the value is compared as a number, a
+		// boolean or text to match; the CASE keeps other values from being cast
+		var number, boolean any
+		if f.Number != nil {
+			number = strconv.FormatFloat(*f.Number, 'f', -1, 64)
+		}
+		if f.Bool != nil {
+			boolean = strconv.FormatBool(*f.Bool)
+		}
+		switch f.Op {
+		case "=", "!=":
+			match := fmt.Sprintf(`CASE jsonb_typeof(l.attributes -> $%[1]d)
+				WHEN 'number' THEN (l.attributes ->> $%[1]d)::numeric = $%[2]d::numeric
+				WHEN 'boolean' THEN (l.attributes ->> $%[1]d)::boolean = $%[3]d::boolean
+				ELSE lower(l.attributes ->> $%[1]d) = lower($%[4]d)
+			END`, argIdx, argIdx+1, argIdx+2, argIdx+3)
+			if f.Op == "!=" {
+				match = fmt.Sprintf("l.attributes -> $%d IS NOT NULL AND NOT COALESCE(%s, false)", argIdx, match)
+			}
+			conditions = append(conditions, match)
+		case ">", ">=", "<", "<=":
+			conditions = append(conditions, fmt.Sprintf(
+				"(CASE WHEN jsonb_typeof(l.attributes -> $%d) = 'number' THEN (l.attributes ->> $%d)::numeric END) %s $%d::numeric",
+				argIdx, argIdx, f.Op, argIdx+1))
+		default:
+			return nil, fmt.Errorf("unsupported attribute operator %q", f.Op)
+		}
+		args = append(args, f.Name, number, boolean, f.Value)
+		argIdx += 4
+	}
+	for _, tag := range p.Tags {
+		// a synonym finds the listings tagged with the tag it stands for
+		conditions = append(conditions, fmt.Sprintf(`
+		EXISTS (SELECT 1 FROM inventory_tags it JOIN tags t ON t.name = $%d
+			WHERE it.inventory_id = l.id AND it.tag_id = COALESCE(t.canonical_id, t.id))`, argIdx))
+		args = append(args, tag)
+		argIdx++
+	}
+	distanceSQL := "NULL::double precision"
+	if p.Near != nil {
+		var near string
+		near, distanceSQL = r.nearSQL(argIdx)
+		conditions = append(conditions, near)
+		args = append(args, p.Near.Latitude, p.Near.Longitude, p.RadiusMeters)
+		argIdx += 3
+	}
 
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
+	orderBy, err := searchOrderBy(p, textIdx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Count total results
 	var total int32
 	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM inventories l %s`, whereClause)
@@ -1923,6 +2120,12 @@ func (r *PostgresRepository) SearchInventory(
 		return nil, fmt.Errorf("count inventories: %w", err)
 	}
 
+	// listings with a running promotion for this kind of search are ranked first when sorted by
+	// promotion, as searches are by default except radius searches, which are nearest first
+	args = append(args, pq.Array(promotionScopes(p)))
+	scopeIdx := argIdx
+	argIdx++
+
 	// Build SELECT query with LEFT JOINs
 	selectSQL := fmt.Sprintf(`
 		SELECT
@@ -1932,7 +2135,7 @@ func (r *PostgresRepository) SearchInventory(
 			l.user_id,
 			l.category_id,
 			l.subcategory_id,
-			l.promoted,
+			pr.id IS NOT NULL,
 			l.deactivated,
 			l.created_at,
 			l.updated_at,
@@ -1965,16 +2168,26 @@ func (r *PostgresRepository) SearchInventory(
 			u.email,
 			u.first_name,
 			u.last_name,
-			u.phone
+			u.phone,
+			pr.id,
+			%s AS distance,
+			COALESCE(rs.rating_average, 0)
 		FROM inventories l
 		LEFT JOIN countries co ON l.country_id = co.id
 		LEFT JOIN states st ON l.state_id = st.id
 		LEFT JOIN lgas la ON l.lga_id = la.id
 		LEFT JOIN users u ON l.user_id = u.id
+		LEFT JOIN inventory_rating_stats rs ON rs.inventory_id = l.id
+		LEFT JOIN LATERAL (
+			SELECT id FROM inventory_promotions
+			WHERE inventory_id = l.id AND scope = ANY($%d) AND starts_at <= NOW() AND ends_at > NOW()
+			ORDER BY ends_at DESC
+			LIMIT 1
+		) pr ON true
 		%s
-		ORDER BY l.created_at DESC
+		ORDER BY %s
 		LIMIT $%d OFFSET $%d
-	`, whereClause, argIdx, argIdx+1)
+	`, distanceSQL, scopeIdx, whereClause, orderBy, argIdx, argIdx+1)
 
 	args = append(args, limit, offset)
 
@@ -1987,8 +2200,10 @@ func (r *PostgresRepository) SearchInventory(
 
 	// Parse inventory rows
 	var (
-		page []*inventory.Inventory
-		ids  []string
+		page       []*inventory.Inventory
+		ids        []string
+		promotions = make(map[string]string)
+		distances  = make(map[string]float64)
 	)
 	for rows.Next() {
 		inv := &inventory.Inventory{
@@ -2011,6 +2226,9 @@ func (r *PostgresRepository) SearchInventory(
 			categorySlug    sql.NullString
 			subcategorySlug sql.NullString
 			primageImage    sql.NullString
+			promotionID     sql.NullString
+			distance        sql.NullFloat64
+			averageRating   float64
 		)
 
 		if err := rows.Scan(
@@ -2054,9 +2272,19 @@ func (r *PostgresRepository) SearchInventory(
 			&inv.User.FirstName,
 			&inv.User.LastName,
 			&inv.User.Phone,
+			&promotionID,
+			&distance,
+			&averageRating,
 		); err != nil {
 			return nil, fmt.Errorf("scan inventory: %w", err)
 		}
+		inv.AverageRating = &averageRating
+		if promotionID.Valid {
+			promotions[inv.Id] = promotionID.String
+		}
+		if distance.Valid {
+			distances[inv.Id] = distance.Float64
+		}
 
 		if slug.Valid {
 			inv.Slug = slug.String
@@ -2121,6 +2349,7 @@ func (r *PostgresRepository) SearchInventory(
 			SELECT id, live_url, local_url, inventory_id, created_at, updated_at
 			FROM inventory_images
 			WHERE inventory_id = ANY($1)
+			ORDER BY inventory_id, position, created_at
 		`
 		imgRows, err := r.Conn.QueryContext(ctx, imgSQL, pq.Array(ids))
 		if err != nil {
@@ -2147,49 +2376,33 @@ func (r *PostgresRepository) SearchInventory(
 		}
 	}
 
-	// Fetch average ratings in batch — ONLY this part is new
-	if len(ids) > 0 {
-		ratingSQL := `
-			SELECT inventory_id, COALESCE(AVG(rating), 0) AS average_rating
-			FROM inventory_ratings
-			WHERE inventory_id = ANY($1)
-			GROUP BY inventory_id
-		`
-		ratingRows, err := r.Conn.QueryContext(ctx, ratingSQL, pq.Array(ids))
-		if err != nil {
-			return nil, fmt.Errorf("select average ratings: %w", err)
-		}
-		defer ratingRows.Close()
-
-		ratingMap := make(map[string]float64)
-		for ratingRows.Next() {
-			var inventoryID string
-			var avgRating float64
-			if err := ratingRows.Scan(&inventoryID, &avgRating); err != nil {
-				return nil, fmt.Errorf("scan rating: %w", err)
-			}
-			ratingMap[inventoryID] = avgRating
-		}
-		for _, inv := range page {
-
-			if avg, ok := ratingMap[inv.Id]; ok {
-				inv.AverageRating = &avg
-			} else {
-				inv.AverageRating = float64Ptr(0.0)
-			}
-
-		}
-	}
-
 	// Return paginated result
 	return &InventoryCollection{
 		Inventories: page,
 		TotalCount:  total,
 		Offset:      int32(offset),
 		Limit:       int32(limit),
+		Promotions:  promotions,
+		Distances:   distances,
 	}, nil
 }
 
+// promotionScopes returns the promotion placements a search shows: category and state pages
+// boost promotions for their category or state, an unfiltered search is the homepage
+func promotionScopes(p *SearchPayload) []string {
+	var scopes []string
+	if p.CategoryID != "" || p.CategorySlug != "" || p.SubcategoryID != "" || p.SubcategorySlug != "" {
+		scopes = append(scopes, PromotionScopeCategory)
+	}
+	if p.StateID != "" || p.StateSlug != "" || p.LgaID != "" || p.LgaSlug != "" {
+		scopes = append(scopes, PromotionScopeState)
+	}
+	if len(scopes) == 0 && p.Text == "" && p.UserID == "" && p.Ulid == "" && len(p.Tags) == 0 && len(p.Attributes) == 0 && p.Near == nil {
+		scopes = append(scopes, PromotionScopeHomepage)
+	}
+	return scopes
+}
+
 func float64Ptr(f float64) *float64 {
 	return &f
 }
@@ -2198,6 +2411,7 @@ type CreateBookingPayload struct {
 	OwnerId           string
 	RenterId          string
 	InventoryId       string
+	VariantId         *string
 	RentalType        string
 	RentalDuration    int32
 	SecurityDeposit   float64
@@ -2210,9 +2424,52 @@ type CreateBookingPayload struct {
 	StartTime         string
 }
 
+// CreateBooking books a listing. A listing that is part of a bundle shares its stock with the
+// bundle's bookings, so it is locked and checked against every booking overlapping the dates, the
+// way CreateBundleBooking checks the components of a bundle.
 func (b *PostgresRepository) CreateBooking(ctx context.Context, p *CreateBookingPayload) (*InventoryBooking, error) {
 
 	log.Println(p)
+	tx, err := b.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		isComponent     bool
+		stock, reserved float64
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM inventory_bundle_components WHERE component_id = i.id),
+			`+bundleComponentStockSQL+`, `+reservedInWindowSQL("i.id")+`
+		FROM inventories i
+		WHERE i.id = $1
+		FOR UPDATE
+	`, p.InventoryId, p.StartDate, p.EndDate).Scan(&isComponent, &stock, &reserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check availability: %w", err)
+	}
+	if available := math.Max(stock-reserved, 0); isComponent && available < float64(p.Quantity) {
+		return nil, fmt.Errorf("%w, only %v available", ErrComponentUnavailable, available)
+	}
+
+	booking, err := insertBooking(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+func insertBooking(ctx context.Context, q queryer, p *CreateBookingPayload) (*InventoryBooking, error) {
 	query := `INSERT INTO inventory_bookings 
 		(
 			inventory_id, 
@@ -2228,13 +2485,15 @@ func (b *PostgresRepository) CreateBooking(ctx context.Context, p *CreateBooking
 			rental_type, 
 			rental_duration,
 			start_time,
+			variant_id,
 			created_at, 
 			updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW()) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW()) 
 		RETURNING 
 			id,  
 			inventory_id,  
+			variant_id,
 			renter_id,  
 			owner_id,  
 			start_date,  
@@ -2253,7 +2512,7 @@ func (b *PostgresRepository) CreateBooking(ctx context.Context, p *CreateBooking
 			updated_at`
 
 	var inventoryBooking InventoryBooking
-	err := b.Conn.QueryRowContext(
+	err := q.QueryRowContext(
 		ctx,
 		query,
 		p.InventoryId,
@@ -2269,9 +2528,11 @@ func (b *PostgresRepository) CreateBooking(ctx context.Context, p *CreateBooking
 		p.RentalType,
 		p.RentalDuration,
 		p.StartTime,
+		p.VariantId,
 	).Scan(
 		&inventoryBooking.ID,
 		&inventoryBooking.InventoryID,
+		&inventoryBooking.VariantID,
 		&inventoryBooking.RenterID,
 		&inventoryBooking.OwnerID,
 		&inventoryBooking.StartDate,
@@ -2300,6 +2561,7 @@ type CreatePurchaseOrderPayload struct {
 	SellerId          string
 	BuyerId           string
 	InventoryId       string
+	VariantId         *string
 	OfferPricePerUnit float64
 	Quantity          int32
 	TotalAmount       float64
@@ -2315,13 +2577,15 @@ func (b *PostgresRepository) CreatePurchaseOrder(ctx context.Context, p *CreateP
 			offer_price_per_unit, 
 			quantity, 
 			total_amount,
+			variant_id,
 			created_at, 
 			updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW()) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW()) 
 		RETURNING 
 			id,  
 			inventory_id, 
+			variant_id,
 			seller_id, 
 			buyer_id, 
 			offer_price_per_unit, 
@@ -2342,9 +2606,11 @@ func (b *PostgresRepository) CreatePurchaseOrder(ctx context.Context, p *CreateP
 		p.OfferPricePerUnit,
 		p.Quantity,
 		p.TotalAmount,
+		p.VariantId,
 	).Scan(
 		&inventorySale.ID,
 		&inventorySale.InventoryID,
+		&inventorySale.VariantID,
 		&inventorySale.SellerID,
 		&inventorySale.BuyerID,
 		&inventorySale.OfferPricePerUnit,
@@ -3102,25 +3368,164 @@ type DeleteInventoryPayload struct {
 }
 
 func (r *PostgresRepository) DeleteInventory(ctx context.Context, detail DeleteInventoryPayload) error {
-	result, err := r.Conn.ExecContext(ctx, `
-		UPDATE inventories
-		SET deleted = true,
-		    deleted_at = NOW()
-		WHERE user_id = $1 AND id = $2
-	`, detail.UserId, detail.InventoryId)
+	tx, err := r.Conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	err = auditInventory(ctx, tx, detail.InventoryId, InventoryDeleted, func() error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE inventories
+			SET deleted = true,
+			    deleted_at = NOW()
+			WHERE user_id = $1 AND id = $2
+		`, detail.UserId, detail.InventoryId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("no inventory found for user %s with id %s", detail.UserId, detail.InventoryId)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("no inventory found for user %s with id %s", detail.UserId, detail.InventoryId)
+
+	return tx.Commit()
+}
+
+type UpdateInventoryParams struct {
+	UserId      string
+	InventoryId string
+
+	Name            *string
+	Slug            *string
+	Description     *string
+	OfferPrice      *float64
+	MinimumPrice    *float64
+	SecurityDeposit *float64
+
+	CategoryID      *string
+	SubcategoryID   *string
+	CountryID       *string
+	StateID         *string
+	LgaID           *string
+	CategorySlug    *string
+	SubcategorySlug *string
+	CountrySlug     *string
+	StateSlug       *string
+	LgaSlug         *string
+
+	Tags       []string // replaces the listing's tags when not nil; normalised, see utility.NormalizeTags
+	UsageGuide *string
+	Condition  *string
+	Included   *string
+	Metadata   *string
+	Attributes *string // set together with Metadata or SubcategoryID, see CreateInventoryParams.Attributes
+
+	Coordinates      *GeoPoint
+	ClearCoordinates bool // falls back to the business location or LGA centroid again
+}
+
+// UpdateInventory applies only the non-nil fields of detail to the inventory owned by detail.UserId
+func (r *PostgresRepository) UpdateInventory(ctx context.Context, detail UpdateInventoryParams) error {
+
+	var (
+		sets   []string
+		args   []interface{}
+		argIdx = 1
+	)
+
+	columns := []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"name", detail.Name, detail.Name != nil},
+		{"slug", detail.Slug, detail.Slug != nil},
+		{"description", detail.Description, detail.Description != nil},
+		{"offer_price", detail.OfferPrice, detail.OfferPrice != nil},
+		{"minimum_price", detail.MinimumPrice, detail.MinimumPrice != nil},
+		{"security_deposit", detail.SecurityDeposit, detail.SecurityDeposit != nil},
+		{"category_id", detail.CategoryID, detail.CategoryID != nil},
+		{"subcategory_id", detail.SubcategoryID, detail.SubcategoryID != nil},
+		{"country_id", detail.CountryID, detail.CountryID != nil},
+		{"state_id", detail.StateID, detail.StateID != nil},
+		{"lga_id", detail.LgaID, detail.LgaID != nil},
+		{"category_slug", detail.CategorySlug, detail.CategorySlug != nil},
+		{"subcategory_slug", detail.SubcategorySlug, detail.SubcategorySlug != nil},
+		{"country_slug", detail.CountrySlug, detail.CountrySlug != nil},
+		{"state_slug", detail.StateSlug, detail.StateSlug != nil},
+		{"lga_slug", detail.LgaSlug, detail.LgaSlug != nil},
+		{"usage_guide", detail.UsageGuide, detail.UsageGuide != nil},
+		{"condition", detail.Condition, detail.Condition != nil},
+		{"included", detail.Included, detail.Included != nil},
+		{"metadata", detail.Metadata, detail.Metadata != nil},
+		{"attributes", detail.Attributes, detail.Attributes != nil},
+		{"latitude", detail.Coordinates.latitude(), detail.Coordinates != nil || detail.ClearCoordinates},
+		{"longitude", detail.Coordinates.longitude(), detail.Coordinates != nil || detail.ClearCoordinates},
+	}
+
+	for _, c := range columns {
+		if !c.set {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = $%d", c.name, argIdx))
+		args = append(args, c.value)
+		argIdx++
 	}
 
-	return nil
+	if len(sets) == 0 && detail.Tags == nil {
+		return fmt.Errorf("no field supplied for update")
+	}
+	sets = append(sets, "updated_at = NOW()")
+
+	query := fmt.Sprintf(`
+		UPDATE inventories
+		SET %s
+		WHERE id = $%d AND user_id = $%d AND deleted = false
+	`, strings.Join(sets, ", "), argIdx, argIdx+1)
+	args = append(args, detail.InventoryId, detail.UserId)
+
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditInventory(ctx, tx, detail.InventoryId, InventoryUpdated, func() error {
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to update inventory: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("no inventory found for user %s with id %s", detail.UserId, detail.InventoryId)
+		}
+
+		if detail.Tags != nil {
+			if _, err := setInventoryTags(ctx, tx, detail.InventoryId, detail.Tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *PostgresRepository) GetUserSavedInventory(ctx context.Context, userId string) ([]*SavedInventory, error) {
@@ -3176,7 +3581,7 @@ func (r *PostgresRepository) GetUserSavedInventory(ctx context.Context, userId s
 					JOIN lgas lga ON lga.id = iv.lga_id
 					JOIN categories cat ON cat.id = iv.category_id
 					JOIN subcategories sub ON sub.id = iv.subcategory_id
-					WHERE si.user_id = $1`
+					WHERE si.user_id = $1 AND iv.deleted = false`
 
 	rows, err := r.Conn.QueryContext(ctx, query, userId)
 	if err != nil {
@@ -5159,6 +5564,8 @@ func (u *PostgresRepository) GetMyInventories(ctx context.Context, detail MyInve
 				metadata, 
 				negotiable, 
 				primary_image,
+				publish_at,
+				expires_at,
 				created_at,
 				updated_at
 		    FROM inventories 
@@ -5208,6 +5615,8 @@ func (u *PostgresRepository) GetMyInventories(ctx context.Context, detail MyInve
 			&inventory.Metadata,
 			&inventory.Negotiable,
 			&inventory.PrimaryImage,
+			&inventory.PublishAt,
+			&inventory.ExpiresAt,
 			&inventory.CreatedAt,
 			&inventory.UpdatedAt,
 		); err != nil {
@@ -5573,15 +5982,53 @@ func (repo *PostgresRepository) MarkInventoryAvailability(ctx context.Context, d
 		return fmt.Errorf("error processing: invalid quantity value")
 	}
 
+	// stock of a listing with variants is the sum of its variants
+	var hasVariants bool
+	err := repo.Conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM inventory_variants WHERE inventory_id = $1)`, detail.InventoryId).Scan(&hasVariants)
+	if err != nil {
+		return err
+	}
+	if hasVariants {
+		return fmt.Errorf("error processing: update the quantity of each variant instead")
+	}
+
+	// and of a bundle the stock of its components
+	var isBundle bool
+	err = repo.Conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM inventory_bundle_components WHERE bundle_id = $1)`, detail.InventoryId).Scan(&isBundle)
+	if err != nil {
+		return err
+	}
+	if isBundle {
+		return fmt.Errorf("error processing: update the quantity of each bundle component instead")
+	}
+
+	tx, err := repo.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Execute update
-	_, err := repo.Conn.ExecContext(ctx, `
-		UPDATE inventories
-		SET quantity = $1,
-			is_available = $2
-		WHERE id = $3 AND user_id = $4
-	`, count, detail.Available, detail.InventoryId, detail.UserId)
+	err = auditInventory(ctx, tx, detail.InventoryId, InventoryAvailabilityChanged, func() error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE inventories
+			SET quantity = $1,
+				is_available = $2,
+				sold_out_at = NULL,
+				sold_out_hidden = false
+			WHERE id = $3 AND user_id = $4
+		`, count, detail.Available, detail.InventoryId, detail.UserId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := syncBundleQuantities(ctx, tx, detail.InventoryId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (repo *PostgresRepository) GetPendingBookingCount(ctx context.Context, userId string) (int32, int32, error) {
@@ -5637,8 +6084,9 @@ func (repo *PostgresRepository) GetPendingPurchaseCount(ctx context.Context, use
 }
 
 type AdminPendingInventoryPayload struct {
-	Page  int32 `json:"page"`
-	Limit int32 `json:"limit"`
+	Page        int32 `json:"page"`
+	Limit       int32 `json:"limit"`
+	FlaggedOnly bool  `json:"flagged_only"` // only listings held as likely duplicates
 }
 
 func (r *PostgresRepository) GetAdminGetInventoryPending(ctx context.Context, detail AdminPendingInventoryPayload) (*InventoryCollection, error) {
@@ -5648,8 +6096,16 @@ func (r *PostgresRepository) GetAdminGetInventoryPending(ctx context.Context, de
 
 	var total int32 // Variable to hold the total count
 
+	flaggedOnly := ""
+	if detail.FlaggedOnly {
+		flaggedOnly = "EXISTS (SELECT 1 FROM inventory_duplicate_flags f WHERE f.inventory_id = l.id AND f.status = 'open')"
+	}
+
 	// Query to count total rows
-	countQuery := "SELECT COUNT(*) FROM inventories WHERE visibility = $1"
+	countQuery := "SELECT COUNT(*) FROM inventories l WHERE l.visibility = $1"
+	if flaggedOnly != "" {
+		countQuery += " AND " + flaggedOnly
+	}
 
 	row := r.Conn.QueryRowContext(ctx, countQuery, "private")
 	if err := row.Scan(&total); err != nil {
@@ -5668,6 +6124,10 @@ func (r *PostgresRepository) GetAdminGetInventoryPending(ctx context.Context, de
 	args = append(args, "private")
 	argIdx++
 
+	if flaggedOnly != "" {
+		conditions = append(conditions, flaggedOnly)
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -5871,6 +6331,7 @@ func (r *PostgresRepository) GetAdminGetInventoryPending(ctx context.Context, de
 			SELECT id, live_url, local_url, inventory_id, created_at, updated_at
 			FROM inventory_images
 			WHERE inventory_id = ANY($1)
+			ORDER BY inventory_id, position, created_at
 		`
 		imgRows, err := r.Conn.QueryContext(ctx, imgSQL, pq.Array(ids))
 		if err != nil {
@@ -5931,23 +6392,53 @@ func (r *PostgresRepository) GetAdminGetInventoryPending(ctx context.Context, de
 		}
 	}
 
+	// the admin sees why a listing was held back
+	flags, err := r.getDuplicateFlags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
 	// Return paginated result
 	return &InventoryCollection{
-		Inventories: page,
-		TotalCount:  total,
-		Offset:      int32(offset),
-		Limit:       int32(limit),
+		Inventories:    page,
+		TotalCount:     total,
+		Offset:         int32(offset),
+		Limit:          int32(limit),
+		DuplicateFlags: flags,
 	}, nil
 
 }
 
 func (r *PostgresRepository) AdminApproveInventory(ctx context.Context, id string) error {
-	_, err := r.Conn.ExecContext(ctx, `
-		UPDATE inventories
-		SET visibility = $1
-		WHERE id = $2 
-	`, "public", id)
-	return err
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditInventory(ctx, tx, id, InventoryApproved, func() error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE inventories
+			SET visibility = $1
+			WHERE id = $2 
+		`, "public", id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// approving a flagged listing accepts it as not being a duplicate
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_duplicate_flags
+		SET status = 'cleared', reviewed_at = NOW()
+		WHERE inventory_id = $1 AND status = 'open'
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 type AdminGetActiveSubscriptionPayload struct {
@@ -6825,3 +7316,4007 @@ func (u *PostgresRepository) GetBusinesses(ctx context.Context, detail AdminGetB
 		Limit:      detail.Limit,
 	}, nil
 }
+
+func (r *PostgresRepository) GetInventoryImages(ctx context.Context, inventoryID string) ([]InventoryImage, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
+		FROM inventory_images
+		WHERE inventory_id = $1
+		ORDER BY position, created_at
+	`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []InventoryImage
+	for rows.Next() {
+		var img InventoryImage
+		if err := rows.Scan(
+			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+			&img.Position, &img.CreatedAt, &img.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+
+	return images, rows.Err()
+}
+
+// AddInventoryImages appends the supplied urls to the end of the inventory's gallery
+func (r *PostgresRepository) AddInventoryImages(ctx context.Context, inventoryID string, uploads []ImageURLs) ([]InventoryImage, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockInventoryImages(ctx, tx, inventoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []InventoryImage
+	for i, upload := range uploads {
+		var img InventoryImage
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO inventory_images (live_url, local_url, thumbnail_url, card_url, full_url, phash, inventory_id, position, updated_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+			RETURNING id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
+		`, upload.Full, upload.Full, upload.Thumbnail, upload.Card, upload.Full, upload.Hash, inventoryID, len(current)+i).Scan(
+			&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+			&img.Position, &img.CreatedAt, &img.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert image URL: %w", err)
+		}
+		images = append(images, img)
+	}
+
+	if err := syncPrimaryImage(ctx, tx, inventoryID); err != nil {
+		return nil, err
+	}
+
+	if err := recordInventoryChange(ctx, tx, inventoryID, InventoryImagesAdded, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit images: %w", err)
+	}
+
+	return images, nil
+}
+
+var (
+	// ErrImageNotFound is returned when an image id does not belong to the inventory
+	ErrImageNotFound = errors.New("no image found on this inventory")
+	// ErrInvalidImageOrder is returned when a gallery order does not list every image exactly once
+	ErrInvalidImageOrder = errors.New("the image order must list every image of the inventory exactly once")
+)
+
+// DeleteInventoryImage removes one image, closes the gap in the gallery order and
+// returns the deleted row so the caller can remove the remote asset
+func (r *PostgresRepository) DeleteInventoryImage(ctx context.Context, inventoryID, imageID string) (*InventoryImage, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockInventoryImages(ctx, tx, inventoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var img InventoryImage
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM inventory_images
+		WHERE id = $1 AND inventory_id = $2
+		RETURNING id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
+	`, imageID, inventoryID).Scan(
+		&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+		&img.Position, &img.CreatedAt, &img.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("image %s: %w", imageID, ErrImageNotFound)
+		}
+		return nil, fmt.Errorf("failed to delete image: %w", err)
+	}
+
+	var remaining []string
+	for _, id := range current {
+		if id != imageID {
+			remaining = append(remaining, id)
+		}
+	}
+
+	if err := applyImageOrder(ctx, tx, remaining); err != nil {
+		return nil, err
+	}
+	if err := syncPrimaryImage(ctx, tx, inventoryID); err != nil {
+		return nil, err
+	}
+
+	if err := recordInventoryChange(ctx, tx, inventoryID, InventoryImageDeleted, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit image delete: %w", err)
+	}
+
+	return &img, nil
+}
+
+// ReorderInventoryImages sets the gallery order; imageIDs must list every image of the inventory exactly once
+func (r *PostgresRepository) ReorderInventoryImages(ctx context.Context, inventoryID string, imageIDs []string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockInventoryImages(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	if len(imageIDs) != len(current) {
+		return fmt.Errorf("expected %d image ids, got %d: %w", len(current), len(imageIDs), ErrInvalidImageOrder)
+	}
+
+	known := make(map[string]bool, len(current))
+	for _, id := range current {
+		known[id] = true
+	}
+	for _, id := range imageIDs {
+		if !known[id] {
+			return fmt.Errorf("image %s is not part of inventory %s or was listed twice: %w", id, inventoryID, ErrInvalidImageOrder)
+		}
+		delete(known, id)
+	}
+
+	if err := applyImageOrder(ctx, tx, imageIDs); err != nil {
+		return err
+	}
+	if err := syncPrimaryImage(ctx, tx, inventoryID); err != nil {
+		return err
+	}
+
+	if err := recordInventoryChange(ctx, tx, inventoryID, InventoryImagesReordered, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPrimaryInventoryImage moves an image to the front of the gallery, keeping the order of the rest
+func (r *PostgresRepository) SetPrimaryInventoryImage(ctx context.Context, inventoryID, imageID string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockInventoryImages(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	order := []string{imageID}
+	found := false
+	for _, id := range current {
+		if id == imageID {
+			found = true
+			continue
+		}
+		order = append(order, id)
+	}
+	if !found {
+		return fmt.Errorf("image %s: %w", imageID, ErrImageNotFound)
+	}
+
+	if err := applyImageOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	if err := syncPrimaryImage(ctx, tx, inventoryID); err != nil {
+		return err
+	}
+
+	if err := recordInventoryChange(ctx, tx, inventoryID, InventoryImagesReordered, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockInventoryImages locks the inventory row so concurrent gallery edits queue up,
+// and returns its image ids in gallery order
+func lockInventoryImages(ctx context.Context, tx *sql.Tx, inventoryID string) ([]string, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM inventories WHERE id = $1 AND deleted = false FOR UPDATE`, inventoryID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no inventory found")
+		}
+		return nil, fmt.Errorf("error retrieving inventory: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM inventory_images
+		WHERE inventory_id = $1
+		ORDER BY position, created_at
+	`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select images: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var imageID string
+		if err := rows.Scan(&imageID); err != nil {
+			return nil, fmt.Errorf("scan image: %w", err)
+		}
+		ids = append(ids, imageID)
+	}
+
+	return ids, rows.Err()
+}
+
+// applyImageOrder stores each image's index in imageIDs as its position
+func applyImageOrder(ctx context.Context, tx *sql.Tx, imageIDs []string) error {
+	for position, id := range imageIDs {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE inventory_images SET position = $1, updated_at = NOW() WHERE id = $2
+		`, position, id)
+		if err != nil {
+			return fmt.Errorf("failed to update image position: %w", err)
+		}
+	}
+	return nil
+}
+
+// syncPrimaryImage copies the first gallery image into inventories.primary_image
+func syncPrimaryImage(ctx context.Context, tx *sql.Tx, inventoryID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE inventories
+		SET primary_image = COALESCE((
+				SELECT live_url FROM inventory_images
+				WHERE inventory_id = $1
+				ORDER BY position, created_at
+				LIMIT 1
+			), ''),
+			updated_at = NOW()
+		WHERE id = $1
+	`, inventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to update primary image: %w", err)
+	}
+	return nil
+}
+
+const (
+	InventoryJobQueued    = "queued"
+	InventoryJobUploading = "uploading"
+	InventoryJobSaving    = "saving"
+	InventoryJobSucceeded = "succeeded"
+	InventoryJobFailed    = "failed"
+
+	InventoryJobImagePending  = "pending"
+	InventoryJobImageUploaded = "uploaded"
+	InventoryJobImageFailed   = "failed"
+)
+
+// CreateInventoryJob stores a queued job together with the raw bytes of its images. The job takes
+// one posting from the user's subscription in the same transaction, or fails with ErrPostingQuotaExceeded.
+func (r *PostgresRepository) CreateInventoryJob(ctx context.Context, job *InventoryJob) (*InventoryJob, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	subscriptionID, err := reservePosting(ctx, tx, job.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	created := *job
+	created.Images = nil
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_creation_jobs (user_id, status, request, posting_subscription_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, status, attempts, created_at, updated_at
+	`, job.UserID, InventoryJobQueued, job.Request, subscriptionID).Scan(
+		&created.ID, &created.Status, &created.Attempts, &created.CreatedAt, &created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inventory job: %w", err)
+	}
+
+	for _, img := range job.Images {
+		saved := img
+		saved.ImageData = nil
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO inventory_creation_job_images (job_id, position, is_primary, image_type, image_data, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+			RETURNING id, job_id, status, created_at, updated_at
+		`, created.ID, img.Position, img.IsPrimary, img.ImageType, img.ImageData, InventoryJobImagePending).Scan(
+			&saved.ID, &saved.JobID, &saved.Status, &saved.CreatedAt, &saved.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store job image: %w", err)
+		}
+		created.Images = append(created.Images, saved)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit inventory job: %w", err)
+	}
+
+	return &created, nil
+}
+
+// ErrInventoryJobNotFound is returned when no creation job has the requested id
+var ErrInventoryJobNotFound = errors.New("no inventory job found")
+
+// GetInventoryJob returns a job and its per-image outcomes. Image bytes are not loaded.
+func (r *PostgresRepository) GetInventoryJob(ctx context.Context, jobID string) (*InventoryJob, error) {
+	var job InventoryJob
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT id, user_id, status, request, error, inventory_id, attempts, created_at, updated_at
+		FROM inventory_creation_jobs
+		WHERE id = $1
+	`, jobID).Scan(
+		&job.ID, &job.UserID, &job.Status, &job.Request, &job.Error,
+		&job.InventoryID, &job.Attempts, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w with ID %s", ErrInventoryJobNotFound, jobID)
+		}
+		return nil, fmt.Errorf("error retrieving inventory job: %w", err)
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, job_id, position, is_primary, image_type, status, url, thumbnail_url, card_url, phash, error, created_at, updated_at
+		FROM inventory_creation_job_images
+		WHERE job_id = $1
+		ORDER BY is_primary DESC, position
+	`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("select job images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var img InventoryJobImage
+		if err := rows.Scan(
+			&img.ID, &img.JobID, &img.Position, &img.IsPrimary, &img.ImageType,
+			&img.Status, &img.URL, &img.ThumbnailURL, &img.CardURL, &img.Hash, &img.Error, &img.CreatedAt, &img.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan job image: %w", err)
+		}
+		job.Images = append(job.Images, img)
+	}
+
+	return &job, rows.Err()
+}
+
+func (r *PostgresRepository) GetInventoryJobImageData(ctx context.Context, imageID string) ([]byte, error) {
+	var imageData []byte
+	err := r.Conn.QueryRowContext(ctx, `SELECT image_data FROM inventory_creation_job_images WHERE id = $1`, imageID).Scan(&imageData)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving job image data: %w", err)
+	}
+	if imageData == nil {
+		return nil, fmt.Errorf("image data for %s is no longer available", imageID)
+	}
+	return imageData, nil
+}
+
+func (r *PostgresRepository) SetInventoryJobStatus(ctx context.Context, jobID, status string) error {
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE inventory_creation_jobs SET status = $1, updated_at = NOW() WHERE id = $2
+	`, status, jobID)
+	return err
+}
+
+// SetInventoryJobImageResult records the outcome of one upload attempt; urls is nil when it failed
+func (r *PostgresRepository) SetInventoryJobImageResult(ctx context.Context, imageID, status string, urls *ImageURLs, errMsg *string) error {
+	var full, thumbnail, card *string
+	var hash *int64
+	if urls != nil {
+		full, thumbnail, card, hash = &urls.Full, &urls.Thumbnail, &urls.Card, urls.Hash
+	}
+
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE inventory_creation_job_images
+		SET status = $1, url = $2, thumbnail_url = $3, card_url = $4, phash = $5, error = $6, updated_at = NOW()
+		WHERE id = $7
+	`, status, full, thumbnail, card, hash, errMsg, imageID)
+	return err
+}
+
+// FailInventoryJob records why a job stopped and refunds the posting it took. A job that has
+// already created its listing is left as it is.
+func (r *PostgresRepository) FailInventoryJob(ctx context.Context, jobID, message string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE inventory_creation_jobs SET status = $1, error = $2, updated_at = NOW() WHERE id = $3 AND status <> $4
+	`, InventoryJobFailed, message, jobID, InventoryJobSucceeded)
+	if err != nil {
+		return err
+	}
+	// a job that succeeded keeps its posting
+	if failed, err := result.RowsAffected(); err != nil || failed == 0 {
+		return err
+	}
+
+	// the listing was not created, so the posting slot goes back to the subscription
+	_, err = tx.ExecContext(ctx, `
+		WITH released AS (
+			UPDATE inventory_creation_jobs j
+			SET posting_subscription_id = NULL
+			FROM (SELECT id, posting_subscription_id FROM inventory_creation_jobs WHERE id = $1 FOR UPDATE) held
+			WHERE j.id = held.id AND held.posting_subscription_id IS NOT NULL
+			RETURNING held.posting_subscription_id
+		)
+		UPDATE user_subscriptions
+		SET available_postings = available_postings + 1
+		WHERE id IN (SELECT posting_subscription_id FROM released)
+	`, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to refund posting: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// completeInventoryJob marks the job as succeeded with the listing it created and drops the
+// stored image bytes
+func completeInventoryJob(ctx context.Context, tx *sql.Tx, jobID, inventoryID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE inventory_creation_jobs
+		SET status = $1, error = NULL, inventory_id = $2, updated_at = NOW()
+		WHERE id = $3
+	`, InventoryJobSucceeded, inventoryID, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to complete inventory job: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_creation_job_images SET image_data = NULL, updated_at = NOW() WHERE job_id = $1
+	`, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to release job images: %w", err)
+	}
+
+	return nil
+}
+
+// RetryInventoryJob puts a failed job back in the queue
+func (r *PostgresRepository) RetryInventoryJob(ctx context.Context, jobID string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var held *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, posting_subscription_id FROM inventory_creation_jobs WHERE id = $1 AND status = $2 FOR UPDATE
+	`, jobID, InventoryJobFailed).Scan(&userID, &held)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("only failed jobs can be retried")
+		}
+		return err
+	}
+
+	// the slot was refunded when the job failed, so the retry needs a new one
+	if held == nil {
+		subscriptionID, err := reservePosting(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		held = &subscriptionID
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_creation_jobs
+		SET status = $1, error = NULL, attempts = attempts + 1, posting_subscription_id = $2, updated_at = NOW()
+		WHERE id = $3
+	`, InventoryJobQueued, *held, jobID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUnfinishedInventoryJobs returns the jobs that are queued or were left uploading or saving,
+// oldest first. Images are not loaded.
+func (r *PostgresRepository) GetUnfinishedInventoryJobs(ctx context.Context) ([]InventoryJob, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, user_id, status, attempts, created_at, updated_at
+		FROM inventory_creation_jobs
+		WHERE status IN ($1, $2, $3)
+		ORDER BY created_at
+	`, InventoryJobQueued, InventoryJobUploading, InventoryJobSaving)
+	if err != nil {
+		return nil, fmt.Errorf("select unfinished inventory jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []InventoryJob
+	for rows.Next() {
+		var job InventoryJob
+		if err := rows.Scan(&job.ID, &job.UserID, &job.Status, &job.Attempts, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan inventory job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ErrPostingQuotaExceeded is returned when the user's subscription has no postings left
+var ErrPostingQuotaExceeded = errors.New("you have no postings left on your subscription")
+
+// reservePosting takes one posting from the user's active, unexpired subscription and returns the
+// subscription id. Postings left on lapsed or cancelled subscriptions can not be spent.
+func reservePosting(ctx context.Context, tx *sql.Tx, userID string) (string, error) {
+	var subscriptionID string
+	err := tx.QueryRowContext(ctx, `
+		UPDATE user_subscriptions
+		SET available_postings = available_postings - 1
+		WHERE id = (
+			SELECT id FROM user_subscriptions
+			WHERE user_id = $1 AND available_postings > 0
+			  AND status IN ('active', 'free') AND (end_date IS NULL OR end_date > NOW())
+			ORDER BY available_postings DESC
+			LIMIT 1
+			FOR UPDATE
+		) AND available_postings > 0
+		RETURNING id
+	`, userID).Scan(&subscriptionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrPostingQuotaExceeded
+		}
+		return "", fmt.Errorf("failed to reserve posting: %w", err)
+	}
+	return subscriptionID, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const variantColumns = `
+	v.id, v.inventory_id, v.name, v.attributes, v.quantity, v.offer_price, v.minimum_price,
+	v.image_id, img.live_url, v.created_at, v.updated_at`
+
+func scanVariant(scan func(dest ...any) error) (*InventoryVariant, error) {
+	var v InventoryVariant
+	var attributes []byte
+	if err := scan(
+		&v.ID, &v.InventoryId, &v.Name, &attributes, &v.Quantity, &v.OfferPrice, &v.MinimumPrice,
+		&v.ImageId, &v.ImageUrl, &v.CreatedAt, &v.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attributes, &v.Attributes); err != nil {
+		return nil, fmt.Errorf("decode variant attributes: %w", err)
+	}
+	return &v, nil
+}
+
+func getInventoryVariants(ctx context.Context, q queryer, inventoryID string) ([]InventoryVariant, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+variantColumns+`
+		FROM inventory_variants v
+		LEFT JOIN inventory_images img ON img.id = v.image_id
+		WHERE v.inventory_id = $1
+		ORDER BY v.created_at
+	`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []InventoryVariant
+	for rows.Next() {
+		v, err := scanVariant(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("scan variant: %w", err)
+		}
+		variants = append(variants, *v)
+	}
+
+	return variants, rows.Err()
+}
+
+func getInventoryVariant(ctx context.Context, q queryer, inventoryID, variantID string) (*InventoryVariant, error) {
+	v, err := scanVariant(q.QueryRowContext(ctx, `
+		SELECT `+variantColumns+`
+		FROM inventory_variants v
+		LEFT JOIN inventory_images img ON img.id = v.image_id
+		WHERE v.id = $1 AND v.inventory_id = $2
+	`, variantID, inventoryID).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no variant %s found on inventory %s", variantID, inventoryID)
+		}
+		return nil, fmt.Errorf("error retrieving variant: %w", err)
+	}
+	return v, nil
+}
+
+func (r *PostgresRepository) GetInventoryVariants(ctx context.Context, inventoryID string) ([]InventoryVariant, error) {
+	return getInventoryVariants(ctx, r.Conn, inventoryID)
+}
+
+func (r *PostgresRepository) GetInventoryVariant(ctx context.Context, inventoryID, variantID string) (*InventoryVariant, error) {
+	return getInventoryVariant(ctx, r.Conn, inventoryID, variantID)
+}
+
+func (r *PostgresRepository) CreateInventoryVariant(ctx context.Context, variant *InventoryVariant) (*InventoryVariant, error) {
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("encode variant attributes: %w", err)
+	}
+
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	imageIDs, err := lockInventoryImages(ctx, tx, variant.InventoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, variant.InventoryId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVariantImage(imageIDs, variant.ImageId); err != nil {
+		return nil, err
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_variants (inventory_id, name, attributes, quantity, offer_price, minimum_price, image_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id
+	`, variant.InventoryId, variant.Name, string(attributes), variant.Quantity, variant.OfferPrice, variant.MinimumPrice, variant.ImageId).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create variant: %w", err)
+	}
+
+	if err := syncVariantAggregates(ctx, tx, variant.InventoryId); err != nil {
+		return nil, err
+	}
+
+	created, err := getInventoryVariant(ctx, tx, variant.InventoryId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordInventoryChange(ctx, tx, variant.InventoryId, InventoryVariantCreated, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit variant: %w", err)
+	}
+
+	return created, nil
+}
+
+type UpdateInventoryVariantParams struct {
+	InventoryId  string
+	VariantId    string
+	Name         *string
+	Attributes   map[string]string // replaces the stored attributes when not nil
+	Quantity     *float64
+	OfferPrice   *float64
+	MinimumPrice *float64
+	ImageId      *string // an empty string removes the image
+}
+
+func (r *PostgresRepository) UpdateInventoryVariant(ctx context.Context, p UpdateInventoryVariantParams) (*InventoryVariant, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	imageIDs, err := lockInventoryImages(ctx, tx, p.InventoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, p.InventoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := getInventoryVariant(ctx, tx, p.InventoryId, p.VariantId)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Name != nil {
+		current.Name = *p.Name
+	}
+	if p.Attributes != nil {
+		current.Attributes = p.Attributes
+	}
+	if p.Quantity != nil {
+		current.Quantity = *p.Quantity
+	}
+	if p.OfferPrice != nil {
+		current.OfferPrice = *p.OfferPrice
+	}
+	if p.MinimumPrice != nil {
+		current.MinimumPrice = *p.MinimumPrice
+	}
+	if p.ImageId != nil {
+		current.ImageId = p.ImageId
+		if *p.ImageId == "" {
+			current.ImageId = nil
+		}
+	}
+	if err := checkVariantImage(imageIDs, current.ImageId); err != nil {
+		return nil, err
+	}
+
+	attributes, err := json.Marshal(current.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("encode variant attributes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_variants
+		SET name = $1, attributes = $2, quantity = $3, offer_price = $4, minimum_price = $5, image_id = $6, updated_at = NOW()
+		WHERE id = $7
+	`, current.Name, string(attributes), current.Quantity, current.OfferPrice, current.MinimumPrice, current.ImageId, current.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update variant: %w", err)
+	}
+
+	if err := syncVariantAggregates(ctx, tx, p.InventoryId); err != nil {
+		return nil, err
+	}
+
+	updated, err := getInventoryVariant(ctx, tx, p.InventoryId, p.VariantId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordInventoryChange(ctx, tx, p.InventoryId, InventoryVariantUpdated, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit variant: %w", err)
+	}
+
+	return updated, nil
+}
+
+func (r *PostgresRepository) DeleteInventoryVariant(ctx context.Context, inventoryID, variantID string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockInventoryImages(ctx, tx, inventoryID); err != nil {
+		return err
+	}
+
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM inventory_variants WHERE id = $1 AND inventory_id = $2`, variantID, inventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to delete variant: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no variant %s found on inventory %s", variantID, inventoryID)
+	}
+
+	if err := syncVariantAggregates(ctx, tx, inventoryID); err != nil {
+		return err
+	}
+
+	if err := recordInventoryChange(ctx, tx, inventoryID, InventoryVariantDeleted, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkVariantImage makes sure a variant only points at an image from its own listing's gallery
+func checkVariantImage(imageIDs []string, imageID *string) error {
+	if imageID == nil {
+		return nil
+	}
+	for _, id := range imageIDs {
+		if id == *imageID {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s does not belong to this inventory", *imageID)
+}
+
+// syncVariantAggregates rolls variant stock and prices up to the parent listing, so search and
+// listing reads see the total quantity and the lowest price. When the last variant is deleted the
+// listing keeps the last prices but has no stock until its owner sets its quantity again.
+func syncVariantAggregates(ctx context.Context, tx *sql.Tx, inventoryID string) error {
+	var quantity, offerPrice, minimumPrice sql.NullFloat64
+	err := tx.QueryRowContext(ctx, `
+		SELECT SUM(quantity), MIN(offer_price), MIN(minimum_price)
+		FROM inventory_variants
+		WHERE inventory_id = $1
+	`, inventoryID).Scan(&quantity, &offerPrice, &minimumPrice)
+	if err != nil {
+		return fmt.Errorf("failed to total variants: %w", err)
+	}
+	if !quantity.Valid {
+		// the variants' stock went with them
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventories SET quantity = 0, is_available = 'no', updated_at = NOW() WHERE id = $1
+		`, inventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to clear variant totals: %w", err)
+		}
+		return nil
+	}
+
+	available := "no"
+	if quantity.Float64 > 0 {
+		available = "yes"
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventories
+		SET quantity = $1, offer_price = $2, minimum_price = $3, is_available = $4, updated_at = NOW()
+		WHERE id = $5
+	`, quantity.Float64, offerPrice.Float64, minimumPrice.Float64, available, inventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to sync variant totals: %w", err)
+	}
+	return nil
+}
+
+const (
+	ImportBatchQueued    = "queued"
+	ImportBatchRunning   = "running"
+	ImportBatchCompleted = "completed"
+
+	ImportRowQueued    = "queued"
+	ImportRowSubmitted = "submitted"
+	ImportRowFailed    = "failed"
+)
+
+// IsBusinessAccount reports whether the user has registered a business
+func (r *PostgresRepository) IsBusinessAccount(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	err := r.Conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM business_kycs WHERE user_id = $1)`, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetAvailablePostings returns how many more listings the user's active subscription allows
+func (r *PostgresRepository) GetAvailablePostings(ctx context.Context, userID string) (int32, error) {
+	var available int32
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(available_postings), 0) FROM user_subscriptions
+		WHERE user_id = $1 AND status IN ('active', 'free') AND (end_date IS NULL OR end_date > NOW())
+	`, userID).Scan(&available)
+	return available, err
+}
+
+// ResolveTaxonomySlugs maps category/subcategory and state/lga slugs to their ids. The pairs must
+// belong together; the country is the state's.
+func (r *PostgresRepository) ResolveTaxonomySlugs(ctx context.Context, categorySlug, subcategorySlug, stateSlug, lgaSlug string) (*TaxonomyIDs, error) {
+	var ids TaxonomyIDs
+
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT c.id, sc.id
+		FROM categories c
+		JOIN subcategories sc ON sc.category_id = c.id
+		WHERE c.category_slug = $1 AND sc.subcategory_slug = $2
+	`, categorySlug, subcategorySlug).Scan(&ids.CategoryID, &ids.SubcategoryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no subcategory %q found in category %q", subcategorySlug, categorySlug)
+		}
+		return nil, fmt.Errorf("error resolving category: %w", err)
+	}
+
+	err = r.Conn.QueryRowContext(ctx, `
+		SELECT s.country_id, s.id, l.id
+		FROM states s
+		JOIN lgas l ON l.state_id = s.id
+		WHERE s.state_slug = $1 AND l.lga_slug = $2
+	`, stateSlug, lgaSlug).Scan(&ids.CountryID, &ids.StateID, &ids.LgaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no lga %q found in state %q", lgaSlug, stateSlug)
+		}
+		return nil, fmt.Errorf("error resolving location: %w", err)
+	}
+
+	return &ids, nil
+}
+
+type TaxonomyIDs struct {
+	CategoryID    string
+	SubcategoryID string
+	CountryID     string
+	StateID       string
+	LgaID         string
+}
+
+// CreateImportBatch stores a batch with its validated rows
+func (r *PostgresRepository) CreateImportBatch(ctx context.Context, batch *InventoryImportBatch) (*InventoryImportBatch, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	created := *batch
+	created.Rows = nil
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_import_batches (user_id, filename, status, total_rows, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, status, created_at, updated_at
+	`, batch.UserID, batch.Filename, ImportBatchQueued, len(batch.Rows)).Scan(
+		&created.ID, &created.Status, &created.CreatedAt, &created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import batch: %w", err)
+	}
+	created.TotalRows = int32(len(batch.Rows))
+
+	for _, row := range batch.Rows {
+		saved := row
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO inventory_import_rows (batch_id, row_number, name, request, image_urls, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+			RETURNING id, batch_id, status, created_at, updated_at
+		`, created.ID, row.RowNumber, row.Name, row.Request, pq.Array(row.ImageURLs), ImportRowQueued).Scan(
+			&saved.ID, &saved.BatchID, &saved.Status, &saved.CreatedAt, &saved.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store import row %d: %w", row.RowNumber, err)
+		}
+		created.Rows = append(created.Rows, saved)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import batch: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetImportBatch returns a batch and its rows together with the status of their creation jobs
+func (r *PostgresRepository) GetImportBatch(ctx context.Context, batchID string) (*InventoryImportBatch, error) {
+	var batch InventoryImportBatch
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT id, user_id, filename, status, total_rows, created_at, updated_at
+		FROM inventory_import_batches
+		WHERE id = $1
+	`, batchID).Scan(
+		&batch.ID, &batch.UserID, &batch.Filename, &batch.Status, &batch.TotalRows, &batch.CreatedAt, &batch.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no import batch found with ID %s", batchID)
+		}
+		return nil, fmt.Errorf("error retrieving import batch: %w", err)
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT ir.id, ir.batch_id, ir.row_number, ir.name, ir.request, ir.image_urls, ir.status,
+			COALESCE(ir.error, j.error), ir.job_id, j.status, j.inventory_id, ir.created_at, ir.updated_at
+		FROM inventory_import_rows ir
+		LEFT JOIN inventory_creation_jobs j ON j.id = ir.job_id
+		WHERE ir.batch_id = $1
+		ORDER BY ir.row_number
+	`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("select import rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row InventoryImportRow
+		if err := rows.Scan(
+			&row.ID, &row.BatchID, &row.RowNumber, &row.Name, &row.Request, pq.Array(&row.ImageURLs), &row.Status,
+			&row.Error, &row.JobID, &row.JobStatus, &row.InventoryID, &row.CreatedAt, &row.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan import row: %w", err)
+		}
+		batch.Rows = append(batch.Rows, row)
+	}
+
+	return &batch, rows.Err()
+}
+
+func (r *PostgresRepository) SetImportBatchStatus(ctx context.Context, batchID, status string) error {
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE inventory_import_batches SET status = $1, updated_at = NOW() WHERE id = $2
+	`, status, batchID)
+	return err
+}
+
+// SetImportRowResult records whether a row made it to a creation job
+func (r *PostgresRepository) SetImportRowResult(ctx context.Context, rowID, status string, jobID, errMsg *string) error {
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE inventory_import_rows SET status = $1, job_id = $2, error = $3, updated_at = NOW() WHERE id = $4
+	`, status, jobID, errMsg, rowID)
+	return err
+}
+
+// GetUnfinishedImportBatches returns the batches that are queued or were left running, oldest first
+func (r *PostgresRepository) GetUnfinishedImportBatches(ctx context.Context) ([]string, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id FROM inventory_import_batches WHERE status IN ($1, $2) ORDER BY created_at
+	`, ImportBatchQueued, ImportBatchRunning)
+	if err != nil {
+		return nil, fmt.Errorf("select unfinished import batches: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan import batch: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ExportFilter selects the rows of an export. From is inclusive and To exclusive; either may be nil.
+type ExportFilter struct {
+	UserId string
+	From   *time.Time
+	To     *time.Time
+}
+
+// exportDateRange appends the created_at bounds of a filter to a query's conditions
+func exportDateRange(column string, filter ExportFilter, conditions []string, args []any) ([]string, []any) {
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("%s < $%d", column, len(args)))
+	}
+	return conditions, args
+}
+
+// ExportMyInventories streams the user's listings to fn, oldest first, one row at a time
+func (u *PostgresRepository) ExportMyInventories(ctx context.Context, filter ExportFilter, fn func(InventoryExportRow) error) error {
+	conditions, args := exportDateRange("created_at", filter, []string{"user_id = $1", "deleted = false"}, []any{filter.UserId})
+
+	query := fmt.Sprintf(`
+		SELECT id, name, slug, product_purpose::text, category_slug, subcategory_slug, state_slug, lga_slug,
+			offer_price, minimum_price, security_deposit, quantity, COALESCE(rental_duration::text, ''),
+			is_available::text, negotiable::text, visibility::text, deactivated, created_at, updated_at
+		FROM inventories
+		WHERE %s
+		ORDER BY created_at, id
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := u.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row InventoryExportRow
+		if err := rows.Scan(
+			&row.ID, &row.Name, &row.Slug, &row.ProductPurpose, &row.CategorySlug, &row.SubcategorySlug, &row.StateSlug, &row.LgaSlug,
+			&row.OfferPrice, &row.MinimumPrice, &row.SecurityDeposit, &row.Quantity, &row.RentalDuration,
+			&row.IsAvailable, &row.Negotiable, &row.Visibility, &row.Deactivated, &row.CreatedAt, &row.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportBookings streams bookings to fn, oldest first. asOwner selects the bookings made on the
+// user's listings (GetBookingRequest) instead of the ones the user made (GetMyBookings).
+func (u *PostgresRepository) ExportBookings(ctx context.Context, filter ExportFilter, asOwner bool, fn func(BookingExportRow) error) error {
+	side := "ivb.renter_id = $1"
+	if asOwner {
+		side = "ivb.owner_id = $1"
+	}
+	conditions, args := exportDateRange("ivb.created_at", filter, []string{side}, []any{filter.UserId})
+
+	query := fmt.Sprintf(`
+		SELECT ivb.id, ivb.inventory_id, iv.name, COALESCE(ivb.variant_id::text, ''),
+			ivb.renter_id, TRIM(COALESCE(r.first_name, '') || ' ' || COALESCE(r.last_name, '')),
+			ivb.owner_id, TRIM(COALESCE(o.first_name, '') || ' ' || COALESCE(o.last_name, '')),
+			TO_CHAR(ivb.start_date, 'YYYY-MM-DD'), COALESCE(ivb.start_time::text, ''),
+			TO_CHAR(ivb.end_date, 'YYYY-MM-DD'), COALESCE(ivb.end_time::text, ''),
+			ivb.rental_type::text, ivb.rental_duration, ivb.quantity, ivb.offer_price_per_unit,
+			ivb.security_deposit, ivb.total_amount, ivb.status::text, ivb.payment_status::text, ivb.created_at
+		FROM inventory_bookings ivb
+		JOIN inventories iv ON iv.id = ivb.inventory_id
+		LEFT JOIN users r ON r.id = ivb.renter_id
+		LEFT JOIN users o ON o.id = ivb.owner_id
+		WHERE %s
+		ORDER BY ivb.created_at, ivb.id
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := u.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row BookingExportRow
+		if err := rows.Scan(
+			&row.ID, &row.InventoryID, &row.InventoryName, &row.VariantID,
+			&row.RenterID, &row.RenterName, &row.OwnerID, &row.OwnerName,
+			&row.StartDate, &row.StartTime, &row.EndDate, &row.EndTime,
+			&row.RentalType, &row.RentalDuration, &row.Quantity, &row.OfferPricePerUnit,
+			&row.SecurityDeposit, &row.TotalAmount, &row.Status, &row.PaymentStatus, &row.CreatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportSales streams purchase orders to fn, oldest first. asSeller selects the orders placed on
+// the user's listings (GetPurchaseRequest) instead of the ones the user placed (GetMyPurchases).
+func (u *PostgresRepository) ExportSales(ctx context.Context, filter ExportFilter, asSeller bool, fn func(SaleExportRow) error) error {
+	side := "ivs.buyer_id = $1"
+	if asSeller {
+		side = "ivs.seller_id = $1"
+	}
+	conditions, args := exportDateRange("ivs.created_at", filter, []string{side}, []any{filter.UserId})
+
+	query := fmt.Sprintf(`
+		SELECT ivs.id, ivs.inventory_id, iv.name, COALESCE(ivs.variant_id::text, ''),
+			COALESCE(ivs.buyer_id::text, ''), TRIM(COALESCE(b.first_name, '') || ' ' || COALESCE(b.last_name, '')),
+			ivs.seller_id, TRIM(COALESCE(s.first_name, '') || ' ' || COALESCE(s.last_name, '')),
+			ivs.quantity, ivs.offer_price_per_unit, ivs.total_amount, ivs.status::text, ivs.payment_status::text, ivs.created_at
+		FROM inventory_sales ivs
+		JOIN inventories iv ON iv.id = ivs.inventory_id
+		LEFT JOIN users b ON b.id = ivs.buyer_id
+		LEFT JOIN users s ON s.id = ivs.seller_id
+		WHERE %s
+		ORDER BY ivs.created_at, ivs.id
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := u.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row SaleExportRow
+		if err := rows.Scan(
+			&row.ID, &row.InventoryID, &row.InventoryName, &row.VariantID,
+			&row.BuyerID, &row.BuyerName, &row.SellerID, &row.SellerName,
+			&row.Quantity, &row.OfferPricePerUnit, &row.TotalAmount, &row.Status, &row.PaymentStatus, &row.CreatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetDuplicateCandidates returns the seller's live listings other than excludeID with the hashes of their images
+func (r *PostgresRepository) GetDuplicateCandidates(ctx context.Context, userID, excludeID string) ([]DuplicateCandidate, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT i.id, i.name, i.description,
+			COALESCE(ARRAY_AGG(img.phash) FILTER (WHERE img.phash IS NOT NULL), '{}')
+		FROM inventories i
+		LEFT JOIN inventory_images img ON img.inventory_id = i.id
+		WHERE i.user_id = $1 AND i.deleted = false AND i.deactivated = false AND i.id::text <> $2
+		GROUP BY i.id
+	`, userID, excludeID)
+	if err != nil {
+		return nil, fmt.Errorf("select duplicate candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []DuplicateCandidate
+	for rows.Next() {
+		var c DuplicateCandidate
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, pq.Array(&c.ImageHashes)); err != nil {
+			return nil, fmt.Errorf("scan duplicate candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// GetInventoryImageHashes returns the perceptual hashes recorded for a listing's images
+func (r *PostgresRepository) GetInventoryImageHashes(ctx context.Context, inventoryID string) ([]int64, error) {
+	var hashes []int64
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT COALESCE(ARRAY_AGG(phash) FILTER (WHERE phash IS NOT NULL), '{}')
+		FROM inventory_images
+		WHERE inventory_id = $1
+	`, inventoryID).Scan(pq.Array(&hashes))
+	return hashes, err
+}
+
+// FlagInventoryDuplicates records the matches of a listing and takes it out of public view until an
+// admin approves it again
+func (r *PostgresRepository) FlagInventoryDuplicates(ctx context.Context, inventoryID string, matches []DuplicateMatch) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, m := range matches {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO inventory_duplicate_flags (inventory_id, duplicate_of, text_similarity, image_distance, status, created_at)
+			VALUES ($1, $2, $3, $4, 'open', NOW())
+			ON CONFLICT (inventory_id, duplicate_of)
+			DO UPDATE SET text_similarity = EXCLUDED.text_similarity, image_distance = EXCLUDED.image_distance,
+				status = 'open', created_at = NOW(), reviewed_at = NULL
+		`, inventoryID, m.InventoryID, m.TextSimilarity, m.ImageDistance)
+		if err != nil {
+			return fmt.Errorf("failed to flag duplicate: %w", err)
+		}
+	}
+
+	err = auditInventory(ctx, tx, inventoryID, InventoryFlaggedDuplicate, func() error {
+		_, err := tx.ExecContext(ctx, `UPDATE inventories SET visibility = 'private', updated_at = NOW() WHERE id = $1`, inventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to hide flagged inventory: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) getDuplicateFlags(ctx context.Context, inventoryIDs []string) (map[string][]InventoryDuplicateFlag, error) {
+	if len(inventoryIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT f.id, f.inventory_id, f.duplicate_of, d.name, f.text_similarity, f.image_distance, f.status, f.created_at, f.reviewed_at
+		FROM inventory_duplicate_flags f
+		JOIN inventories d ON d.id = f.duplicate_of
+		WHERE f.inventory_id = ANY($1) AND f.status = 'open'
+		ORDER BY f.created_at
+	`, pq.Array(inventoryIDs))
+	if err != nil {
+		return nil, fmt.Errorf("select duplicate flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := make(map[string][]InventoryDuplicateFlag)
+	for rows.Next() {
+		var f InventoryDuplicateFlag
+		if err := rows.Scan(
+			&f.ID, &f.InventoryID, &f.DuplicateOfID, &f.DuplicateOfName, &f.TextSimilarity, &f.ImageDistance,
+			&f.Status, &f.CreatedAt, &f.ReviewedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan duplicate flag: %w", err)
+		}
+		flags[f.InventoryID] = append(flags[f.InventoryID], f)
+	}
+
+	return flags, rows.Err()
+}
+
+// Where a change to a listing came from, as recorded in its history
+const (
+	ChangeSourceHTTP   = "http"
+	ChangeSourceGRPC   = "grpc"
+	ChangeSourceAdmin  = "admin"
+	ChangeSourceSystem = "system"
+)
+
+// Actions recorded in a listing's history
+const (
+	InventoryCreated             = "created"
+	InventoryUpdated             = "updated"
+	InventoryAvailabilityChanged = "availability_changed"
+	InventoryApproved            = "approved"
+	InventoryDeleted             = "deleted"
+	InventoryFlaggedDuplicate    = "flagged_duplicate"
+	InventoryVariantCreated      = "variant_created"
+	InventoryVariantUpdated      = "variant_updated"
+	InventoryVariantDeleted      = "variant_deleted"
+	InventoryImagesAdded         = "images_added"
+	InventoryImageDeleted        = "image_deleted"
+	InventoryStockAlertChanged   = "stock_alert_changed"
+	InventoryImagesReordered     = "images_reordered"
+	InventoryRestored            = "restored"
+	InventoryPurged              = "purged"
+	InventoryScheduled           = "scheduled"
+	InventoryRenewed             = "renewed"
+	InventoryBundleChanged       = "bundle_changed"
+)
+
+// auditedInventoryFields are the parts of a listing whose changes are kept in its history.
+// variants, images and components are summaries of the child rows.
+var auditedInventoryFields = []string{
+	"name", "description", "slug", "product_purpose", "offer_price", "minimum_price", "security_deposit",
+	"quantity", "is_available", "rental_duration", "negotiable", "visibility", "promoted", "deactivated", "deleted",
+	"category_id", "subcategory_id", "country_id", "state_id", "lga_id",
+	"tags", "usage_guide", "condition", "included", "metadata", "primary_image", "publish_at", "expires_at", "variants", "images",
+	"low_stock_threshold", "components",
+}
+
+type changeActorKey struct{}
+
+type changeActor struct {
+	userID string
+	source string
+}
+
+// WithChangeActor tags ctx with who is changing listings and through which channel. Changes made
+// with an untagged context are recorded as coming from the system.
+func WithChangeActor(ctx context.Context, userID, source string) context.Context {
+	return context.WithValue(ctx, changeActorKey{}, changeActor{userID: userID, source: source})
+}
+
+func changeActorFrom(ctx context.Context) (*string, string) {
+	actor, ok := ctx.Value(changeActorKey{}).(changeActor)
+	if !ok {
+		return nil, ChangeSourceSystem
+	}
+	if actor.userID == "" {
+		return nil, actor.source
+	}
+	return &actor.userID, actor.source
+}
+
+// inventorySnapshot locks a listing and returns its audited fields, or nil when it does not exist
+func inventorySnapshot(ctx context.Context, tx *sql.Tx, inventoryID string) (map[string]any, error) {
+	var raw []byte
+	err := tx.QueryRowContext(ctx, `
+		SELECT to_jsonb(i) || jsonb_build_object(
+			'variants', COALESCE((
+				SELECT jsonb_agg(jsonb_build_object(
+					'id', v.id, 'name', v.name, 'quantity', v.quantity,
+					'offer_price', v.offer_price, 'minimum_price', v.minimum_price
+				) ORDER BY v.created_at, v.id)
+				FROM inventory_variants v WHERE v.inventory_id = i.id
+			), '[]'::jsonb),
+			'images', COALESCE((
+				SELECT jsonb_agg(img.live_url ORDER BY img.position, img.created_at)
+				FROM inventory_images img WHERE img.inventory_id = i.id
+			), '[]'::jsonb),
+			'components', COALESCE((
+				SELECT jsonb_agg(jsonb_build_object('id', c.component_id, 'quantity', c.quantity) ORDER BY c.component_id)
+				FROM inventory_bundle_components c WHERE c.bundle_id = i.id
+			), '[]'::jsonb)
+		)
+		FROM inventories i
+		WHERE i.id = $1
+		FOR UPDATE OF i
+	`, inventoryID).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read inventory for history: %w", err)
+	}
+
+	var row map[string]any
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, fmt.Errorf("failed to decode inventory for history: %w", err)
+	}
+
+	snapshot := make(map[string]any, len(auditedInventoryFields))
+	for _, field := range auditedInventoryFields {
+		snapshot[field] = row[field]
+	}
+	return snapshot, nil
+}
+
+// recordInventoryChange compares a listing with a snapshot taken earlier in the same transaction
+// and appends the fields that changed to its history. Nothing is recorded when nothing changed.
+func recordInventoryChange(ctx context.Context, tx *sql.Tx, inventoryID, action string, before map[string]any) error {
+	after, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[string]FieldChange)
+	for _, field := range auditedInventoryFields {
+		var b, a any
+		if before != nil {
+			b = before[field]
+		}
+		if after != nil {
+			a = after[field]
+		}
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes[field] = FieldChange{Before: b, After: a}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode inventory changes: %w", err)
+	}
+
+	actorID, source := changeActorFrom(ctx)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO inventory_history (inventory_id, action, changes, actor_id, source, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`, inventoryID, action, string(encoded), actorID, source)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory history: %w", err)
+	}
+	return nil
+}
+
+// auditInventory runs mutate inside tx and records what it changed on the listing
+func auditInventory(ctx context.Context, tx *sql.Tx, inventoryID, action string, mutate func() error) error {
+	before, err := inventorySnapshot(ctx, tx, inventoryID)
+	if err != nil {
+		return err
+	}
+
+	if err := mutate(); err != nil {
+		return err
+	}
+
+	return recordInventoryChange(ctx, tx, inventoryID, action, before)
+}
+
+// GetInventoryHistory returns every recorded change of a listing, oldest first
+func (r *PostgresRepository) GetInventoryHistory(ctx context.Context, inventoryID string) ([]InventoryHistoryEntry, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, inventory_id, action, changes, actor_id, source, created_at
+		FROM inventory_history
+		WHERE inventory_id = $1
+		ORDER BY created_at, id
+	`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select inventory history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []InventoryHistoryEntry{}
+	for rows.Next() {
+		var entry InventoryHistoryEntry
+		var changes []byte
+		if err := rows.Scan(&entry.ID, &entry.InventoryID, &entry.Action, &changes, &entry.ActorID, &entry.Source, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan inventory history: %w", err)
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, fmt.Errorf("decode inventory history: %w", err)
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+// GetInventoryOwner returns the owner of a listing, including deleted ones
+func (r *PostgresRepository) GetInventoryOwner(ctx context.Context, inventoryID string) (string, error) {
+	var userID string
+	err := r.Conn.QueryRowContext(ctx, `SELECT user_id FROM inventories WHERE id = $1`, inventoryID).Scan(&userID)
+	return userID, err
+}
+
+// ErrInventoryNotRestorable is returned when a listing is not deleted or its restore window has passed
+var ErrInventoryNotRestorable = errors.New("this inventory can no longer be restored")
+
+// GetDeletedInventories lists the user's deleted listings that are still inside the restore window
+func (r *PostgresRepository) GetDeletedInventories(ctx context.Context, userID string, window time.Duration) ([]DeletedInventory, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, name, slug, COALESCE(primary_image, ''), deleted_at
+		FROM inventories
+		WHERE user_id = $1 AND deleted = true AND purged_at IS NULL
+		  AND deleted_at > NOW() - make_interval(secs => $2)
+		ORDER BY deleted_at DESC
+	`, userID, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("select deleted inventories: %w", err)
+	}
+	defer rows.Close()
+
+	inventories := []DeletedInventory{}
+	for rows.Next() {
+		var inv DeletedInventory
+		if err := rows.Scan(&inv.ID, &inv.Name, &inv.Slug, &inv.PrimaryImage, &inv.DeletedAt); err != nil {
+			return nil, fmt.Errorf("scan deleted inventory: %w", err)
+		}
+		inv.RestoreBefore = inv.DeletedAt.Add(window)
+		inventories = append(inventories, inv)
+	}
+
+	return inventories, rows.Err()
+}
+
+// RestoreInventory undoes DeleteInventory when the listing was deleted less than window ago
+func (r *PostgresRepository) RestoreInventory(ctx context.Context, userID, inventoryID string, window time.Duration) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditInventory(ctx, tx, inventoryID, InventoryRestored, func() error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE inventories
+			SET deleted = false,
+			    deleted_at = NULL,
+			    updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND deleted = true AND purged_at IS NULL
+			  AND deleted_at > NOW() - make_interval(secs => $3)
+		`, inventoryID, userID, window.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to restore inventory: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrInventoryNotRestorable
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPurgeableInventories returns up to limit listings deleted more than window ago that have not been purged
+func (r *PostgresRepository) GetPurgeableInventories(ctx context.Context, window time.Duration, limit int) ([]string, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id
+		FROM inventories
+		WHERE deleted = true AND purged_at IS NULL
+		  AND deleted_at <= NOW() - make_interval(secs => $1)
+		ORDER BY deleted_at
+		LIMIT $2
+	`, window.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("select purgeable inventories: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan purgeable inventory: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PurgeInventory removes a deleted listing's images, variants and saved entries. Bookings, sales,
+// ratings, promotions, bundles, questions and unit rentals read the listing row or would be
+// cascaded away with it, so when any exist it is kept as a tombstone with purged_at set; otherwise
+// it is deleted. The removed images are returned so the caller can delete the assets.
+func (r *PostgresRepository) PurgeInventory(ctx context.Context, inventoryID string) ([]InventoryImage, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var referenced bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM inventory_bookings WHERE inventory_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_sales WHERE inventory_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_ratings WHERE inventory_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_promotions WHERE inventory_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_bundle_components WHERE bundle_id = $1 OR component_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_booking_components WHERE inventory_id = $1)
+		    OR EXISTS (SELECT 1 FROM inventory_questions WHERE inventory_id = $1)
+		    OR EXISTS (
+		        SELECT 1
+		        FROM inventory_unit_rentals ur
+		        JOIN inventory_units u ON u.id = ur.unit_id
+		        WHERE u.inventory_id = $1
+		    )
+	`, inventoryID).Scan(&referenced)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check inventory references: %w", err)
+	}
+
+	var images []InventoryImage
+	err = auditInventory(ctx, tx, inventoryID, InventoryPurged, func() error {
+		var deleted bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT deleted AND purged_at IS NULL FROM inventories WHERE id = $1 FOR UPDATE
+		`, inventoryID).Scan(&deleted)
+		if err != nil {
+			return fmt.Errorf("failed to lock inventory: %w", err)
+		}
+		if !deleted {
+			return fmt.Errorf("inventory %s is not waiting to be purged", inventoryID)
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM inventory_images
+			WHERE inventory_id = $1
+			RETURNING id, live_url, local_url, thumbnail_url, card_url, full_url, inventory_id, position, created_at, updated_at
+		`, inventoryID)
+		if err != nil {
+			return fmt.Errorf("failed to delete inventory images: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var img InventoryImage
+			if err := rows.Scan(
+				&img.ID, &img.LiveUrl, &img.LocalUrl, &img.ThumbnailUrl, &img.CardUrl, &img.FullUrl, &img.InventoryId,
+				&img.Position, &img.CreatedAt, &img.UpdatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to scan deleted image: %w", err)
+			}
+			images = append(images, img)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM saved_inventories WHERE inventory_id = $1`, inventoryID); err != nil {
+			return fmt.Errorf("failed to delete saved inventories: %w", err)
+		}
+
+		if referenced {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE inventories
+				SET purged_at = NOW(),
+				    primary_image = ''
+				WHERE id = $1
+			`, inventoryID)
+			if err != nil {
+				return fmt.Errorf("failed to mark inventory purged: %w", err)
+			}
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM inventories WHERE id = $1`, inventoryID); err != nil {
+			return fmt.Errorf("failed to delete inventory: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return images, nil
+}
+
+// Kinds of Notification
+const (
+	NotificationListingExpiring = "listing_expiring"
+	NotificationQuestionAsked   = "question_asked"
+	NotificationQuestionAnswer  = "question_answered"
+	NotificationLowStock        = "low_stock"
+	NotificationSoldOut         = "sold_out"
+)
+
+// GetListingLifetimeDays returns how many days listings stay live on the user's current plan.
+// nil means the plan does not say and the service default applies; 0 means they never expire.
+func (r *PostgresRepository) GetListingLifetimeDays(ctx context.Context, userID string) (*int32, error) {
+	var days sql.NullInt32
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT p.listing_lifetime_days
+		FROM user_subscriptions us
+		JOIN plans p ON p.id = us.plan_id
+		WHERE us.user_id = $1
+		ORDER BY us.created_at DESC
+		LIMIT 1
+	`, userID).Scan(&days)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read listing lifetime: %w", err)
+	}
+	if !days.Valid {
+		return nil, nil
+	}
+	return &days.Int32, nil
+}
+
+// ScheduleInventory sets when a listing goes live and when it expires; nil means now and never.
+// A new expiry date gets a new reminder.
+func (r *PostgresRepository) ScheduleInventory(ctx context.Context, userID, inventoryID string, publishAt, expiresAt *time.Time) error {
+	return r.setInventoryLifetime(ctx, InventoryScheduled, `
+		UPDATE inventories
+		SET publish_at = $3,
+		    expires_at = $4,
+		    expiry_reminded_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted = false
+	`, inventoryID, userID, publishAt, expiresAt)
+}
+
+// RenewInventory moves a listing's expiry date to expiresAt, which may bring an expired listing back
+func (r *PostgresRepository) RenewInventory(ctx context.Context, userID, inventoryID string, expiresAt *time.Time) error {
+	return r.setInventoryLifetime(ctx, InventoryRenewed, `
+		UPDATE inventories
+		SET expires_at = $3,
+		    expiry_reminded_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted = false
+	`, inventoryID, userID, expiresAt)
+}
+
+func (r *PostgresRepository) setInventoryLifetime(ctx context.Context, action, query string, inventoryID, userID string, args ...any) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditInventory(ctx, tx, inventoryID, action, func() error {
+		result, err := tx.ExecContext(ctx, query, append([]any{inventoryID, userID}, args...)...)
+		if err != nil {
+			return fmt.Errorf("failed to update inventory: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("no inventory found for user %s with id %s", userID, inventoryID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetInventorySchedule returns when a listing goes live and when it expires
+func (r *PostgresRepository) GetInventorySchedule(ctx context.Context, inventoryID string) (publishAt, expiresAt *time.Time, err error) {
+	err = r.Conn.QueryRowContext(ctx, `
+		SELECT publish_at, expires_at FROM inventories WHERE id = $1 AND deleted = false
+	`, inventoryID).Scan(&publishAt, &expiresAt)
+	return publishAt, expiresAt, err
+}
+
+// QueueExpiryReminders notifies the owners of live listings that expire within the next `within`,
+// once per expiry date, and returns how many reminders were queued
+func (r *PostgresRepository) QueueExpiryReminders(ctx context.Context, within time.Duration) (int64, error) {
+	result, err := r.Conn.ExecContext(ctx, `
+		WITH due AS (
+			UPDATE inventories
+			SET expiry_reminded_at = NOW()
+			WHERE deleted = false
+			  AND expiry_reminded_at IS NULL
+			  AND expires_at > NOW()
+			  AND expires_at <= NOW() + make_interval(secs => $1)
+			RETURNING id, user_id, name, expires_at
+		)
+		INSERT INTO notifications (user_id, kind, inventory_id, message, created_at)
+		SELECT user_id, $2, id,
+		       format('Your listing "%s" expires on %s. Renew it to keep it live.', name, to_char(expires_at, 'YYYY-MM-DD')),
+		       NOW()
+		FROM due
+	`, within.Seconds(), NotificationListingExpiring)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue expiry reminders: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// BackfillListingExpiry gives the listings queued by the schedule migration a full lifetime from
+// now: the one of the owner's plan, or defaultDays when the plan does not set one. A lifetime of 0
+// leaves the listing without an expiry date. Every queued listing is taken off the queue, so this
+// only does work on the first run after the migration. It returns how many listings got a date.
+func (r *PostgresRepository) BackfillListingExpiry(ctx context.Context, defaultDays int) (int64, error) {
+	result, err := r.Conn.ExecContext(ctx, `
+		WITH queued AS (
+			DELETE FROM inventory_expiry_backfill
+			RETURNING inventory_id
+		), lifetimes AS (
+			SELECT i.id,
+			       COALESCE((
+			           SELECT p.listing_lifetime_days
+			           FROM user_subscriptions us
+			           JOIN plans p ON p.id = us.plan_id
+			           WHERE us.user_id = i.user_id
+			           ORDER BY us.created_at DESC
+			           LIMIT 1
+			       ), $1) AS days
+			FROM inventories i
+			JOIN queued q ON q.inventory_id = i.id
+			WHERE i.deleted = false AND i.expires_at IS NULL
+		)
+		UPDATE inventories i
+		SET expires_at = NOW() + make_interval(days => l.days)
+		FROM lifetimes l
+		WHERE i.id = l.id AND l.days > 0
+	`, defaultDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill listing expiry: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetNotifications returns the user's latest notifications, newest first
+func (r *PostgresRepository) GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit int32) ([]Notification, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT id, user_id, kind, inventory_id, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND ($2 = false OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.InventoryID, &n.Message, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks the given notifications of the user as read, or all of them when ids is empty
+func (r *PostgresRepository) MarkNotificationsRead(ctx context.Context, userID string, ids []string) error {
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::uuid[]) = 0 OR id = ANY($2::uuid[]))
+	`, userID, pq.Array(ids))
+	return err
+}
+
+// GetPostingQuota returns how many listings the user can still post and how many are being created.
+// Only an active, unexpired subscription counts, as reservePosting spends nothing else.
+func (r *PostgresRepository) GetPostingQuota(ctx context.Context, userID string) (*PostingQuota, error) {
+	quota := PostingQuota{UserID: userID}
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT GREATEST(us.available_postings, 0), p.name, us.status, us.end_date
+		FROM user_subscriptions us
+		LEFT JOIN plans p ON p.id = us.plan_id
+		WHERE us.user_id = $1
+		  AND us.status IN ('active', 'free') AND (us.end_date IS NULL OR us.end_date > NOW())
+		ORDER BY us.available_postings DESC
+		LIMIT 1
+	`, userID).Scan(&quota.AvailablePostings, &quota.Plan, &quota.Status, &quota.EndDate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read posting quota: %w", err)
+	}
+
+	err = r.Conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM inventory_creation_jobs
+		WHERE user_id = $1 AND posting_subscription_id IS NOT NULL AND status IN ($2, $3, $4)
+	`, userID, InventoryJobQueued, InventoryJobUploading, InventoryJobSaving).Scan(&quota.PendingPostings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pending postings: %w", err)
+	}
+
+	return &quota, nil
+}
+
+// Placements an InventoryPromotion can be bought for
+const (
+	PromotionScopeCategory = "category"
+	PromotionScopeState    = "state"
+	PromotionScopeHomepage = "homepage"
+)
+
+var (
+	// ErrPromotionOverlaps is returned when the listing already has a promotion in the same placement for part of the period
+	ErrPromotionOverlaps = errors.New("this listing is already promoted in that placement for part of the period")
+	// ErrPaymentReferenceUsed is returned when a payment has already paid for a promotion
+	ErrPaymentReferenceUsed = errors.New("this payment reference has already been used")
+	// ErrPaymentNotVerified is returned when a reference is not a successful payment of the promotion's owner
+	ErrPaymentNotVerified = errors.New("no successful payment was found for this payment reference")
+	// ErrPaymentTooSmall is returned when a payment does not cover the price of a promotion
+	ErrPaymentTooSmall = errors.New("the payment does not cover the price of the promotion")
+	// ErrPromotionNotForSale is returned for a placement that has no rate
+	ErrPromotionNotForSale = errors.New("this placement can not be bought at the moment")
+)
+
+// GetPromotionPrice returns what a promotion in scope costs for days, from the placement's daily rate
+func (r *PostgresRepository) GetPromotionPrice(ctx context.Context, scope string, days int) (float64, error) {
+	var price float64
+	err := r.Conn.QueryRowContext(ctx, `
+		SELECT daily_rate * $2 FROM promotion_rates WHERE scope = $1
+	`, scope, days).Scan(&price)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrPromotionNotForSale
+		}
+		return 0, fmt.Errorf("failed to read promotion rate: %w", err)
+	}
+	return price, nil
+}
+
+const promotionColumns = `
+	id, inventory_id, user_id, scope, scope_value, starts_at, ends_at, amount, payment_reference,
+	impressions, clicks, starts_at <= NOW() AND ends_at > NOW(), created_at`
+
+func scanPromotion(row interface{ Scan(...any) error }) (*InventoryPromotion, error) {
+	var p InventoryPromotion
+	err := row.Scan(
+		&p.ID, &p.InventoryID, &p.UserID, &p.Scope, &p.ScopeValue, &p.StartsAt, &p.EndsAt, &p.Amount, &p.PaymentReference,
+		&p.Impressions, &p.Clicks, &p.Active, &p.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateInventoryPromotion stores a paid promotion. The payment reference must be a successful
+// payment of the promotion's user that covers its Amount. The scope value is taken from the listing.
+func (r *PostgresRepository) CreateInventoryPromotion(ctx context.Context, promotion *InventoryPromotion) (*InventoryPromotion, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the listing row is locked so two purchases for the same placement can not both pass the overlap check
+	var overlaps bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM inventory_promotions
+			WHERE inventory_id = $1 AND scope = $2 AND starts_at < $4 AND ends_at > $3
+		)
+		FROM inventories WHERE id = $1 FOR UPDATE
+	`, promotion.InventoryID, promotion.Scope, promotion.StartsAt, promotion.EndsAt).Scan(&overlaps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing promotions: %w", err)
+	}
+	if overlaps {
+		return nil, ErrPromotionOverlaps
+	}
+
+	// the payment row is locked as well, so a reference can not pay for two promotions at once
+	var payer, paymentStatus string
+	var covers bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, status, amount >= $2 FROM promotion_payments WHERE reference = $1 FOR UPDATE
+	`, promotion.PaymentReference, promotion.Amount).Scan(&payer, &paymentStatus, &covers)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPaymentNotVerified
+		}
+		return nil, fmt.Errorf("failed to read payment: %w", err)
+	}
+	if paymentStatus != "success" || payer != promotion.UserID {
+		return nil, ErrPaymentNotVerified
+	}
+	if !covers {
+		return nil, ErrPaymentTooSmall
+	}
+
+	created, err := scanPromotion(tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_promotions (inventory_id, user_id, scope, scope_value, starts_at, ends_at, amount, payment_reference, created_at)
+		SELECT i.id, $2, $3,
+		       CASE $3 WHEN 'category' THEN i.category_slug WHEN 'state' THEN i.state_slug END,
+		       $4, $5, $6, $7, NOW()
+		FROM inventories i
+		WHERE i.id = $1
+		ON CONFLICT (payment_reference) DO NOTHING
+		RETURNING `+promotionColumns,
+		promotion.InventoryID, promotion.UserID, promotion.Scope, promotion.StartsAt, promotion.EndsAt,
+		promotion.Amount, promotion.PaymentReference,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPaymentReferenceUsed
+		}
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit promotion: %w", err)
+	}
+	return created, nil
+}
+
+// GetInventoryPromotions returns every promotion bought for a listing with its counters, latest first
+func (r *PostgresRepository) GetInventoryPromotions(ctx context.Context, inventoryID string) ([]InventoryPromotion, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT `+promotionColumns+`
+		FROM inventory_promotions
+		WHERE inventory_id = $1
+		ORDER BY starts_at DESC
+	`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select promotions: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := []InventoryPromotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan promotion: %w", err)
+		}
+		promotions = append(promotions, *p)
+	}
+
+	return promotions, rows.Err()
+}
+
+// RecordPromotionImpressions counts one impression for each promotion
+func (r *PostgresRepository) RecordPromotionImpressions(ctx context.Context, promotionIDs []string) error {
+	if len(promotionIDs) == 0 {
+		return nil
+	}
+	_, err := r.Conn.ExecContext(ctx, `
+		UPDATE inventory_promotions SET impressions = impressions + 1 WHERE id = ANY($1::uuid[])
+	`, pq.Array(promotionIDs))
+	return err
+}
+
+// RecordPromotionClick counts a click by a visitor on a listing's running promotion in scope, or on
+// its latest running promotion when scope is empty. A visitor counts once a day per promotion; a
+// listing that is not promoted is ignored.
+func (r *PostgresRepository) RecordPromotionClick(ctx context.Context, inventoryID, scope, visitorKey string) error {
+	_, err := r.Conn.ExecContext(ctx, `
+		WITH counted AS (
+			INSERT INTO promotion_clicks (promotion_id, visitor_key, day)
+			SELECT id, $3, CURRENT_DATE FROM inventory_promotions
+			WHERE inventory_id = $1 AND ($2 = '' OR scope = $2) AND starts_at <= NOW() AND ends_at > NOW()
+			ORDER BY starts_at DESC
+			LIMIT 1
+			ON CONFLICT DO NOTHING
+			RETURNING promotion_id
+		)
+		UPDATE inventory_promotions SET clicks = clicks + 1
+		WHERE id IN (SELECT promotion_id FROM counted)
+	`, inventoryID, scope, visitorKey)
+	return err
+}
+
+// Types of SubcategoryAttribute
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeEnum    = "enum"
+)
+
+const subcategoryAttributeColumns = `id, subcategory_id, name, label, type, unit, allowed_values, required, created_at, updated_at`
+
+// GetSubcategoryAttributes returns the attribute definitions of a subcategory by name
+func (r *PostgresRepository) GetSubcategoryAttributes(ctx context.Context, subcategoryID string) ([]SubcategoryAttribute, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT `+subcategoryAttributeColumns+`
+		FROM subcategory_attributes
+		WHERE subcategory_id = $1
+		ORDER BY name
+	`, subcategoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select subcategory attributes: %w", err)
+	}
+	defer rows.Close()
+
+	attributes := []SubcategoryAttribute{}
+	for rows.Next() {
+		var a SubcategoryAttribute
+		if err := rows.Scan(
+			&a.ID, &a.SubcategoryID, &a.Name, &a.Label, &a.Type, &a.Unit, pq.Array(&a.AllowedValues), &a.Required,
+			&a.CreatedAt, &a.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan subcategory attribute: %w", err)
+		}
+		attributes = append(attributes, a)
+	}
+
+	return attributes, rows.Err()
+}
+
+// SaveSubcategoryAttribute creates an attribute definition, or replaces the one with the same name.
+// Listings already saved are not re-validated.
+func (r *PostgresRepository) SaveSubcategoryAttribute(ctx context.Context, attribute *SubcategoryAttribute) (*SubcategoryAttribute, error) {
+	var saved SubcategoryAttribute
+	err := r.Conn.QueryRowContext(ctx, `
+		INSERT INTO subcategory_attributes (subcategory_id, name, label, type, unit, allowed_values, required, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (subcategory_id, name) DO UPDATE
+		SET label = EXCLUDED.label,
+		    type = EXCLUDED.type,
+		    unit = EXCLUDED.unit,
+		    allowed_values = EXCLUDED.allowed_values,
+		    required = EXCLUDED.required,
+		    updated_at = NOW()
+		RETURNING `+subcategoryAttributeColumns,
+		attribute.SubcategoryID, attribute.Name, attribute.Label, attribute.Type, attribute.Unit,
+		pq.Array(attribute.AllowedValues), attribute.Required,
+	).Scan(
+		&saved.ID, &saved.SubcategoryID, &saved.Name, &saved.Label, &saved.Type, &saved.Unit, pq.Array(&saved.AllowedValues), &saved.Required,
+		&saved.CreatedAt, &saved.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save subcategory attribute: %w", err)
+	}
+
+	// existing listings' values are retyped to match, so searches compare them the same way as new ones
+	_, err = r.Conn.ExecContext(ctx, `
+		UPDATE inventories
+		SET attributes = CASE
+		        WHEN typed_attribute_value(attributes -> $2, $3, $4) IS NULL THEN attributes - $2
+		        ELSE jsonb_set(attributes, ARRAY[$2], typed_attribute_value(attributes -> $2, $3, $4))
+		    END
+		WHERE subcategory_id = $1 AND attributes -> $2 IS NOT NULL
+	`, saved.SubcategoryID, saved.Name, saved.Type, pq.Array(saved.AllowedValues))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retype listing attributes: %w", err)
+	}
+	return &saved, nil
+}
+
+// DeleteSubcategoryAttribute removes an attribute definition. Listings keep the values they have.
+func (r *PostgresRepository) DeleteSubcategoryAttribute(ctx context.Context, subcategoryID, name string) error {
+	result, err := r.Conn.ExecContext(ctx, `
+		DELETE FROM subcategory_attributes WHERE subcategory_id = $1 AND name = $2
+	`, subcategoryID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete subcategory attribute: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("no attribute %s found on subcategory %s", name, subcategoryID)
+	}
+	return nil
+}
+
+// ErrTagSynonymLoop is returned when a tag would become a synonym of itself
+var ErrTagSynonymLoop = errors.New("a tag can not be a synonym of itself")
+
+// inventoryTagsSQL lists the tags of the listing i, in the order the owner gave them
+const inventoryTagsSQL = `(
+	SELECT string_agg(t.name, ', ' ORDER BY it.position)
+	FROM inventory_tags it
+	JOIN tags t ON t.id = it.tag_id
+	WHERE it.inventory_id = i.id
+)`
+
+// setInventoryTags replaces the tags of a listing with names, which are already normalised.
+// Synonyms are stored as the tag they stand for and inventories.tags is rewritten to match,
+// so the listing shows the tags it can be found by. It returns the rewritten tags.
+func setInventoryTags(ctx context.Context, tx *sql.Tx, inventoryID string, names []string) (string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM inventory_tags WHERE inventory_id = $1`, inventoryID); err != nil {
+		return "", fmt.Errorf("failed to clear inventory tags: %w", err)
+	}
+
+	if len(names) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO tags (name) SELECT unnest($1::text[])
+			ON CONFLICT (name) DO NOTHING
+		`, pq.Array(names))
+		if err != nil {
+			return "", fmt.Errorf("failed to save tags: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO inventory_tags (inventory_id, tag_id, position)
+			SELECT $1, COALESCE(t.canonical_id, t.id), MIN(n.position)
+			FROM unnest($2::text[]) WITH ORDINALITY AS n(name, position)
+			JOIN tags t ON t.name = n.name
+			GROUP BY COALESCE(t.canonical_id, t.id)
+		`, inventoryID, pq.Array(names))
+		if err != nil {
+			return "", fmt.Errorf("failed to tag inventory: %w", err)
+		}
+	}
+
+	var tags sql.NullString
+	err := tx.QueryRowContext(ctx, `
+		UPDATE inventories i SET tags = `+inventoryTagsSQL+`
+		WHERE i.id = $1
+		RETURNING i.tags
+	`, inventoryID).Scan(&tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to update inventory tags: %w", err)
+	}
+	return tags.String, nil
+}
+
+// GetPopularTags returns the tags with the most live listings, in a category when categoryID is set
+func (r *PostgresRepository) GetPopularTags(ctx context.Context, categoryID string, limit int) ([]TagCount, error) {
+	conditions := []string{
+		"l.deleted = false",
+		"l.visibility = 'public'",
+		"(l.publish_at IS NULL OR l.publish_at <= NOW())",
+		"(l.expires_at IS NULL OR l.expires_at > NOW())",
+	}
+	args := []interface{}{limit}
+	if categoryID != "" {
+		conditions = append(conditions, "l.category_id = $2")
+		args = append(args, categoryID)
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT t.name, COUNT(*)
+		FROM inventory_tags it
+		JOIN tags t ON t.id = it.tag_id
+		JOIN inventories l ON l.id = it.inventory_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY t.name
+		ORDER BY COUNT(*) DESC, t.name
+		LIMIT $1
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select popular tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("scan popular tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// SetTagSynonym makes synonym stand for canonical, creating either tag if needed. Listings tagged
+// with synonym, or with one of its own synonyms, move to canonical. Listing history is not
+// recorded for this, as the owners did not change anything.
+func (r *PostgresRepository) SetTagSynonym(ctx context.Context, synonym, canonical string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, canonical)
+	if err != nil {
+		return fmt.Errorf("failed to save tag %s: %w", canonical, err)
+	}
+
+	// a canonical tag that is itself a synonym hands over to the tag it stands for
+	var canonicalID string
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(canonical_id, id) FROM tags WHERE name = $1`, canonical).Scan(&canonicalID)
+	if err != nil {
+		return fmt.Errorf("failed to find tag %s: %w", canonical, err)
+	}
+
+	var synonymID string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM tags WHERE name = $1`, synonym).Scan(&synonymID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to find tag %s: %w", synonym, err)
+	}
+	if synonymID == canonicalID {
+		return ErrTagSynonymLoop
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tags (name, canonical_id) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET canonical_id = EXCLUDED.canonical_id
+		RETURNING id
+	`, synonym, canonicalID).Scan(&synonymID)
+	if err != nil {
+		return fmt.Errorf("failed to save tag %s: %w", synonym, err)
+	}
+
+	// tags that stood for the synonym now stand for the canonical tag directly
+	_, err = tx.ExecContext(ctx, `UPDATE tags SET canonical_id = $1 WHERE canonical_id = $2`, canonicalID, synonymID)
+	if err != nil {
+		return fmt.Errorf("failed to update synonyms of %s: %w", synonym, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO inventory_tags (inventory_id, tag_id, position)
+		SELECT inventory_id, $1, position FROM inventory_tags WHERE tag_id = $2
+		ON CONFLICT (inventory_id, tag_id) DO NOTHING
+	`, canonicalID, synonymID)
+	if err != nil {
+		return fmt.Errorf("failed to retag inventories: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM inventory_tags WHERE tag_id = $1 RETURNING inventory_id`, synonymID)
+	if err != nil {
+		return fmt.Errorf("failed to untag inventories: %w", err)
+	}
+	var moved []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan retagged inventory: %w", err)
+		}
+		moved = append(moved, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(moved) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventories i SET tags = `+inventoryTagsSQL+`
+			WHERE i.id = ANY($1)
+		`, pq.Array(moved))
+		if err != nil {
+			return fmt.Errorf("failed to update inventory tags: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Kinds of slug in slug_redirects
+const (
+	SlugKindInventory = "inventory"
+	SlugKindBusiness  = "business"
+)
+
+// ResolveSlugRedirect returns the current slug of the listing, or subdomain of the business, that
+// used to have slug, or "" when none did. Old slugs are recorded by triggers on rename; a listing
+// slug from before that still resolves by the ULID at its end, which renames keep.
+func (r *PostgresRepository) ResolveSlugRedirect(ctx context.Context, kind, slug string) (string, error) {
+	var query string
+	args := []interface{}{slug}
+	switch kind {
+	case SlugKindInventory:
+		query = `
+			SELECT i.slug FROM slug_redirects r
+			JOIN inventories i ON i.id = r.resource_id
+			WHERE r.kind = 'inventory' AND r.old_slug = $1 AND i.deleted = false`
+		if id := utility.ULIDFromSlug(slug); id != "" {
+			query += `
+			UNION ALL
+			SELECT slug FROM inventories WHERE ulid = $2 AND deleted = false`
+			args = append(args, id)
+		}
+	case SlugKindBusiness:
+		query = `
+			SELECT b.subdomain FROM slug_redirects r
+			JOIN business_kycs b ON b.id = r.resource_id
+			WHERE r.kind = 'business' AND r.old_slug = $1`
+	default:
+		return "", fmt.Errorf("unknown slug kind %q", kind)
+	}
+
+	var current sql.NullString
+	err := r.Conn.QueryRowContext(ctx, query+` LIMIT 1`, args...).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve slug redirect: %w", err)
+	}
+	if current.String == slug {
+		return "", nil
+	}
+	return current.String, nil
+}
+
+// Kinds of inventory event
+const (
+	InventoryEventView       = "view"
+	InventoryEventImpression = "impression"
+	InventoryEventSave       = "save"
+	InventoryEventChat       = "chat"
+	InventoryEventBooking    = "booking"
+)
+
+// inventoryEventColumns maps each kind of event to its counter in inventory_daily_stats
+var inventoryEventColumns = map[string]string{
+	InventoryEventView:       "views",
+	InventoryEventImpression: "impressions",
+	InventoryEventSave:       "saves",
+	InventoryEventChat:       "chats",
+	InventoryEventBooking:    "bookings",
+}
+
+// RecordInventoryEvents counts an event of kind by the visitor on each listing, unless the visitor
+// already had one today. Unknown listings are skipped.
+func (r *PostgresRepository) RecordInventoryEvents(ctx context.Context, kind, visitorKey string, inventoryIDs []string) error {
+	column, ok := inventoryEventColumns[kind]
+	if !ok {
+		return fmt.Errorf("unknown inventory event %q", kind)
+	}
+	if len(inventoryIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.Conn.ExecContext(ctx, `
+		WITH counted AS (
+			INSERT INTO inventory_events (inventory_id, kind, visitor_key, day)
+			SELECT id, $2, $3, CURRENT_DATE FROM inventories WHERE id = ANY($1::uuid[])
+			ON CONFLICT DO NOTHING
+			RETURNING inventory_id
+		)
+		INSERT INTO inventory_daily_stats (inventory_id, day, `+column+`)
+		SELECT inventory_id, CURRENT_DATE, 1 FROM counted
+		ON CONFLICT (inventory_id, day) DO UPDATE SET `+column+` = inventory_daily_stats.`+column+` + 1
+	`, pq.Array(inventoryIDs), kind, visitorKey)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory events: %w", err)
+	}
+	return nil
+}
+
+// PruneInventoryEvents deletes the events and promotion clicks of earlier days; they are only needed
+// to count each visitor once a day and are already in the counters
+func (r *PostgresRepository) PruneInventoryEvents(ctx context.Context) (int64, error) {
+	result, err := r.Conn.ExecContext(ctx, `DELETE FROM inventory_events WHERE day < CURRENT_DATE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune inventory events: %w", err)
+	}
+	events, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = r.Conn.ExecContext(ctx, `DELETE FROM promotion_clicks WHERE day < CURRENT_DATE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune promotion clicks: %w", err)
+	}
+	clicks, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return events + clicks, nil
+}
+
+// GetInventoryDailyStats returns a listing's stats for every day from from until today, oldest first.
+// Days without events are included with zero counts.
+func (r *PostgresRepository) GetInventoryDailyStats(ctx context.Context, inventoryID string, from time.Time) ([]InventoryDailyStats, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT d.day::date,
+		       COALESCE(s.views, 0), COALESCE(s.impressions, 0), COALESCE(s.saves, 0),
+		       COALESCE(s.chats, 0), COALESCE(s.bookings, 0)
+		FROM generate_series($2::date, CURRENT_DATE, INTERVAL '1 day') AS d(day)
+		LEFT JOIN inventory_daily_stats s ON s.inventory_id = $1 AND s.day = d.day::date
+		ORDER BY d.day
+	`, inventoryID, from.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("select inventory stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []InventoryDailyStats{}
+	for rows.Next() {
+		var day InventoryDailyStats
+		if err := rows.Scan(&day.Day, &day.Views, &day.Impressions, &day.Saves, &day.Chats, &day.Bookings); err != nil {
+			return nil, fmt.Errorf("scan inventory stats: %w", err)
+		}
+		stats = append(stats, day)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetUserInventoryStats returns the stats from from until today of each of the user's live listings,
+// most viewed first
+func (r *PostgresRepository) GetUserInventoryStats(ctx context.Context, userID string, from time.Time) ([]InventoryStatsSummary, error) {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT i.id, i.name, COALESCE(i.slug, ''),
+		       COALESCE(SUM(s.views), 0), COALESCE(SUM(s.impressions), 0), COALESCE(SUM(s.saves), 0),
+		       COALESCE(SUM(s.chats), 0), COALESCE(SUM(s.bookings), 0)
+		FROM inventories i
+		LEFT JOIN inventory_daily_stats s ON s.inventory_id = i.id AND s.day >= $2::date
+		WHERE i.user_id = $1 AND i.deleted = false
+		GROUP BY i.id
+		ORDER BY COALESCE(SUM(s.views), 0) DESC, i.created_at DESC
+	`, userID, from.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("select user inventory stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []InventoryStatsSummary{}
+	for rows.Next() {
+		var summary InventoryStatsSummary
+		if err := rows.Scan(
+			&summary.InventoryID, &summary.Name, &summary.Slug,
+			&summary.Views, &summary.Impressions, &summary.Saves, &summary.Chats, &summary.Bookings,
+		); err != nil {
+			return nil, fmt.Errorf("scan user inventory stats: %w", err)
+		}
+		stats = append(stats, summary)
+	}
+
+	return stats, rows.Err()
+}
+
+// questionReportHideLimit is how many reports hide a question until the owner or an admin shows it again
+const questionReportHideLimit = 3
+
+// ErrQuestionNotFound is returned when a question does not exist, or is not on the user's listing
+var ErrQuestionNotFound = errors.New("no question found")
+
+// Who hid an InventoryQuestion
+const (
+	QuestionHiddenByOwner      = "owner"
+	QuestionHiddenByModeration = "moderation"
+)
+
+// ErrQuestionModerated is returned when an owner tries to show a question hidden by an admin or reports
+var ErrQuestionModerated = errors.New("this question was hidden by moderation and can not be shown again")
+
+const inventoryQuestionColumns = `id, inventory_id, asker_id, question, answer, answered_at, report_count, hidden, hidden_by, created_at, updated_at`
+
+func scanInventoryQuestion(row interface{ Scan(...any) error }, q *InventoryQuestion) error {
+	return row.Scan(&q.ID, &q.InventoryID, &q.AskerID, &q.Question, &q.Answer, &q.AnsweredAt, &q.ReportCount, &q.Hidden, &q.HiddenBy, &q.CreatedAt, &q.UpdatedAt)
+}
+
+// AskInventoryQuestion saves a question on a listing and notifies the listing's owner
+func (r *PostgresRepository) AskInventoryQuestion(ctx context.Context, question *InventoryQuestion) (*InventoryQuestion, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var saved InventoryQuestion
+	err = scanInventoryQuestion(tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_questions (inventory_id, asker_id, question, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING `+inventoryQuestionColumns,
+		question.InventoryID, question.AskerID, question.Question,
+	), &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save question: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, kind, inventory_id, message, created_at)
+		SELECT user_id, $2, id, format('New question on "%s": %s', name, left($3, 100)), NOW()
+		FROM inventories WHERE id = $1
+	`, saved.InventoryID, NotificationQuestionAsked, saved.Question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to notify owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// AnswerInventoryQuestion answers, or re-answers, a question on one of the owner's listings and
+// notifies the user who asked it
+func (r *PostgresRepository) AnswerInventoryQuestion(ctx context.Context, ownerID, questionID, answer string) (*InventoryQuestion, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var saved InventoryQuestion
+	err = scanInventoryQuestion(tx.QueryRowContext(ctx, `
+		UPDATE inventory_questions q
+		SET answer = $3, answered_at = NOW(), updated_at = NOW()
+		FROM inventories i
+		WHERE q.id = $2 AND i.id = q.inventory_id AND i.user_id = $1
+		RETURNING q.id, q.inventory_id, q.asker_id, q.question, q.answer, q.answered_at, q.report_count, q.hidden, q.hidden_by, q.created_at, q.updated_at
+	`, ownerID, questionID, answer), &saved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQuestionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer question: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, kind, inventory_id, message, created_at)
+		SELECT $1, $2, id, format('Your question on "%s" was answered.', name), NOW()
+		FROM inventories WHERE id = $3
+	`, saved.AskerID, NotificationQuestionAnswer, saved.InventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to notify asker: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetInventoryQuestions returns a listing's questions, newest first. Unless all is set, only answered
+// questions that are not hidden are returned.
+func (r *PostgresRepository) GetInventoryQuestions(ctx context.Context, inventoryID string, all bool) ([]InventoryQuestion, error) {
+	query := `SELECT ` + inventoryQuestionColumns + ` FROM inventory_questions WHERE inventory_id = $1`
+	if !all {
+		query += ` AND answer IS NOT NULL AND hidden = false`
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := r.Conn.QueryContext(ctx, query, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select inventory questions: %w", err)
+	}
+	defer rows.Close()
+
+	questions := []InventoryQuestion{}
+	for rows.Next() {
+		var q InventoryQuestion
+		if err := scanInventoryQuestion(rows, &q); err != nil {
+			return nil, fmt.Errorf("scan inventory question: %w", err)
+		}
+		questions = append(questions, q)
+	}
+
+	return questions, rows.Err()
+}
+
+// ReportInventoryQuestion counts a user's report of a question once. A question is hidden when it
+// reaches questionReportHideLimit reports.
+func (r *PostgresRepository) ReportInventoryQuestion(ctx context.Context, userID, questionID string) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO track_reported_inventory_questions (user_id, question_id, created_at)
+		SELECT $1, id, NOW() FROM inventory_questions WHERE id = $2
+		ON CONFLICT (user_id, question_id) DO NOTHING
+	`, userID, questionID)
+	if err != nil {
+		return fmt.Errorf("failed to record report: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// an unknown question, or one the user already reported
+		return tx.Commit()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_questions
+		SET report_count = report_count + 1,
+		    hidden = hidden OR report_count + 1 >= $2,
+		    hidden_by = CASE WHEN report_count + 1 >= $2 THEN $3 ELSE hidden_by END,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, questionID, questionReportHideLimit, QuestionHiddenByModeration)
+	if err != nil {
+		return fmt.Errorf("failed to update report count: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SetInventoryQuestionHidden hides a question from the public or shows it again. With an ownerID
+// only questions on that user's listings can be changed, and questions hidden by moderation stay
+// hidden; admins pass an empty ownerID and can change any question.
+func (r *PostgresRepository) SetInventoryQuestionHidden(ctx context.Context, ownerID, questionID string, hidden bool) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hiddenBy *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT q.hidden_by
+		FROM inventory_questions q
+		JOIN inventories i ON i.id = q.inventory_id
+		WHERE q.id = $1 AND ($2 = '' OR i.user_id::text = $2)
+		FOR UPDATE OF q
+	`, questionID, ownerID).Scan(&hiddenBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrQuestionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read question: %w", err)
+	}
+
+	moderated := hiddenBy != nil && *hiddenBy == QuestionHiddenByModeration
+	var by *string
+	switch {
+	case !hidden && ownerID != "" && moderated:
+		return ErrQuestionModerated
+	case !hidden:
+		by = nil
+	case ownerID == "" || moderated:
+		// the owner hiding it as well does not take it out of moderation
+		by = new(string)
+		*by = QuestionHiddenByModeration
+	default:
+		by = new(string)
+		*by = QuestionHiddenByOwner
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_questions SET hidden = $2, hidden_by = $3, updated_at = NOW() WHERE id = $1
+	`, questionID, hidden, by)
+	if err != nil {
+		return fmt.Errorf("failed to update question: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// inventoryCoordinateSQL is a listing's latitude or longitude: its own, else its owner's business
+// location, else the centroid of its LGA
+func inventoryCoordinateSQL(column string) string {
+	return fmt.Sprintf(`COALESCE(l.%[1]s,
+			(SELECT b.%[1]s FROM business_kycs b WHERE b.user_id = l.user_id ORDER BY b.id LIMIT 1),
+			(SELECT g.%[1]s FROM lgas g WHERE g.id = l.lga_id))`, column)
+}
+
+// detectEarthDistance reports whether the earthdistance extension is installed; the geo migration
+// only installs it where the database allows. It is checked once, when the repository is made, so
+// no request's deadline decides it.
+func detectEarthDistance(db *sql.DB) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var installed bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'earthdistance')`).Scan(&installed)
+	if err != nil {
+		log.Printf("failed to check for earthdistance, using haversine: %v", err)
+		return false
+	}
+	return installed
+}
+
+// nearSQL returns the search condition for listings within a radius of a point, and the distance
+// to each in metres. The point's latitude, longitude and the radius are the parameters from argIdx.
+func (r *PostgresRepository) nearSQL(argIdx int) (condition, distance string) {
+	lat, lng, radius := argIdx, argIdx+1, argIdx+2
+	latitude, longitude := inventoryCoordinateSQL("latitude"), inventoryCoordinateSQL("longitude")
+
+	if !r.earthDistance {
+		distance = fmt.Sprintf("haversine_distance($%d, $%d, %s, %s)", lat, lng, latitude, longitude)
+		return fmt.Sprintf("%s <= $%d", distance, radius), distance
+	}
+
+	// the boxes match the gist indexes on each source of coordinates; the distance then trims
+	// them to the circle and picks the source a listing actually uses
+	box := fmt.Sprintf("earth_box(ll_to_earth($%d, $%d), $%d)", lat, lng, radius)
+	distance = fmt.Sprintf("earth_distance(ll_to_earth($%d, $%d), ll_to_earth(%s, %s))", lat, lng, latitude, longitude)
+	condition = fmt.Sprintf(`
+		((l.latitude IS NOT NULL AND ll_to_earth(l.latitude, l.longitude) <@ %[1]s)
+		OR (l.latitude IS NULL AND (
+			l.user_id IN (SELECT b.user_id FROM business_kycs b WHERE b.latitude IS NOT NULL AND ll_to_earth(b.latitude, b.longitude) <@ %[1]s)
+			OR l.lga_id IN (SELECT g.id FROM lgas g WHERE g.latitude IS NOT NULL AND ll_to_earth(g.latitude, g.longitude) <@ %[1]s))))
+		AND %[2]s <= $%[3]d`, box, distance, radius)
+	return condition, distance
+}
+
+func (p *GeoPoint) latitude() interface{} {
+	if p == nil {
+		return nil
+	}
+	return p.Latitude
+}
+
+func (p *GeoPoint) longitude() interface{} {
+	if p == nil {
+		return nil
+	}
+	return p.Longitude
+}
+
+// SetBusinessLocation sets the coordinates of a user's business, or clears them when point is nil.
+// Listings without their own coordinates are found near it.
+func (r *PostgresRepository) SetBusinessLocation(ctx context.Context, userID string, point *GeoPoint) error {
+	result, err := r.Conn.ExecContext(ctx, `
+		UPDATE business_kycs SET latitude = $2, longitude = $3
+		WHERE user_id = $1
+	`, userID, point.latitude(), point.longitude())
+	if err != nil {
+		return fmt.Errorf("failed to update business location: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrBusinessNotFound
+	}
+	return nil
+}
+
+// SetLgaCentroid sets the centre of an LGA, the location of its listings that have no other
+func (r *PostgresRepository) SetLgaCentroid(ctx context.Context, lgaID string, point *GeoPoint) error {
+	result, err := r.Conn.ExecContext(ctx, `
+		UPDATE lgas SET latitude = $2, longitude = $3 WHERE id = $1
+	`, lgaID, point.latitude(), point.longitude())
+	if err != nil {
+		return fmt.Errorf("failed to update lga centroid: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("no lga found")
+	}
+	return nil
+}
+
+// The sections of the sitemap
+const (
+	SitemapListings   = "listings"
+	SitemapCategories = "categories"
+	SitemapStates     = "states"
+	SitemapBusinesses = "businesses"
+)
+
+// publicInventorySQL selects the listings anyone can see, as search does
+const publicInventorySQL = `l.deleted = false AND l.deactivated = false AND l.visibility = 'public'
+	AND (l.publish_at IS NULL OR l.publish_at <= NOW())
+	AND (l.expires_at IS NULL OR l.expires_at > NOW())`
+
+// ErrInvalidSitemapCursor is returned for a sitemap file that does not start at a page of its section
+var ErrInvalidSitemapCursor = errors.New("no sitemap found")
+
+// sitemapQueries select the slug, category slug, state slug, subdomain and updated_at of each
+// section's pages, from and where clauses apart so the pages can share them. Files are paged by
+// key rather than offset: cursor is a row's key as text, after compares the key with one ($1),
+// and cursorPattern, when set, checks one before it reaches the query.
+var sitemapQueries = map[string]struct {
+	columns, updated, from, order, cursor, after string
+	cursorPattern                                *regexp.Regexp
+}{
+	SitemapListings: {
+		columns:       `l.slug, COALESCE(l.category_slug, ''), COALESCE(l.state_slug, ''), '', l.updated_at`,
+		updated:       `l.updated_at`,
+		from:          `inventories l WHERE l.slug IS NOT NULL AND ` + publicInventorySQL,
+		order:         `l.created_at, l.id`,
+		cursor:        `to_char(l.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US') || '_' || l.id::text`,
+		after:         `(l.created_at, l.id) >= (split_part($1, '_', 1)::timestamp, split_part($1, '_', 2)::uuid)`,
+		cursorPattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}_[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+	},
+	SitemapCategories: {
+		columns: `'', c.category_slug, '', '', c.updated_at`,
+		updated: `c.updated_at`,
+		from:    `categories c WHERE c.category_slug IS NOT NULL`,
+		order:   `c.category_slug`,
+		cursor:  `c.category_slug`,
+		after:   `c.category_slug >= $1`,
+	},
+	SitemapStates: {
+		columns: `'', '', s.state_slug, '', s.updated_at`,
+		updated: `s.updated_at`,
+		from:    `states s WHERE s.state_slug IS NOT NULL`,
+		order:   `s.state_slug`,
+		cursor:  `s.state_slug`,
+		after:   `s.state_slug >= $1`,
+	},
+	SitemapBusinesses: {
+		columns: `'', '', '', b.subdomain, b.updated_at`,
+		updated: `b.updated_at`,
+		from:    `business_kycs b WHERE b.subdomain IS NOT NULL AND b.subdomain <> ''`,
+		order:   `b.subdomain`,
+		cursor:  `b.subdomain`,
+		after:   `b.subdomain >= $1`,
+	},
+}
+
+// GetSitemapPages splits a sitemap section into files of at most size pages and returns each
+// file's first key and latest update, in order
+func (r *PostgresRepository) GetSitemapPages(ctx context.Context, section string, size int) ([]SitemapPage, error) {
+	q, ok := sitemapQueries[section]
+	if !ok {
+		return nil, fmt.Errorf("unknown sitemap section %q", section)
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT MIN(CASE WHEN (rn - 1) %% $1 = 0 THEN cursor END), MAX(updated_at)
+		FROM (
+			SELECT %s AS cursor, %s AS updated_at, row_number() OVER (ORDER BY %s) AS rn
+			FROM %s
+		) ranked
+		GROUP BY (rn - 1) / $1
+		ORDER BY (rn - 1) / $1
+	`, q.cursor, q.updated, q.order, q.from), size)
+	if err != nil {
+		return nil, fmt.Errorf("select %s sitemap pages: %w", section, err)
+	}
+	defer rows.Close()
+
+	var pages []SitemapPage
+	for rows.Next() {
+		var page SitemapPage
+		if err := rows.Scan(&page.From, &page.LastMod); err != nil {
+			return nil, fmt.Errorf("scan %s sitemap page: %w", section, err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, rows.Err()
+}
+
+// StreamSitemapEntries streams up to limit pages of a sitemap section to fn, one row at a time,
+// starting at the key from as returned by GetSitemapPages, or at the first page when from is empty
+func (r *PostgresRepository) StreamSitemapEntries(ctx context.Context, section, from string, limit int, fn func(SitemapEntry) error) error {
+	q, ok := sitemapQueries[section]
+	if !ok {
+		return fmt.Errorf("unknown sitemap section %q", section)
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s LIMIT $1`, q.columns, q.from, q.order)
+	args := []any{limit}
+	if from != "" {
+		if q.cursorPattern != nil && !q.cursorPattern.MatchString(from) {
+			return ErrInvalidSitemapCursor
+		}
+		query = fmt.Sprintf(`SELECT %s FROM %s AND %s ORDER BY %s LIMIT $2`, q.columns, q.from, q.after, q.order)
+		args = []any{from, limit}
+	}
+
+	rows, err := r.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry SitemapEntry
+		if err := rows.Scan(&entry.Slug, &entry.CategorySlug, &entry.StateSlug, &entry.Subdomain, &entry.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StreamProductFeed streams the public sale listings with a price and an image to fn, one row at a time
+func (r *PostgresRepository) StreamProductFeed(ctx context.Context, fn func(ProductFeedItem) error) error {
+	rows, err := r.Conn.QueryContext(ctx, `
+		SELECT l.id, l.name, COALESCE(l.description, ''), l.slug, COALESCE(l.category_slug, ''),
+			COALESCE(l.subcategory_slug, ''), COALESCE(l.state_slug, ''), COALESCE(c.name, ''), COALESCE(sc.name, ''),
+			l.primary_image, COALESCE(l.condition, ''), COALESCE(l.is_available::text, ''), l.offer_price, l.quantity, l.updated_at
+		FROM inventories l
+		LEFT JOIN categories c ON c.id = l.category_id
+		LEFT JOIN subcategories sc ON sc.id = l.subcategory_id
+		WHERE l.product_purpose = 'sale' AND l.offer_price > 0 AND l.slug IS NOT NULL
+			AND l.primary_image IS NOT NULL AND l.primary_image <> ''
+			AND `+publicInventorySQL+`
+		ORDER BY l.created_at, l.id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item ProductFeedItem
+		if err := rows.Scan(
+			&item.ID, &item.Name, &item.Description, &item.Slug, &item.CategorySlug,
+			&item.SubcategorySlug, &item.StateSlug, &item.CategoryName, &item.SubcategoryName,
+			&item.PrimaryImage, &item.Condition, &item.IsAvailable, &item.OfferPrice, &item.Quantity, &item.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// The statuses of an InventoryUnit. A unit is out from its handover for a booking until it is returned.
+const (
+	UnitAvailable   = "available"
+	UnitOut         = "out"
+	UnitMaintenance = "maintenance"
+	UnitRetired     = "retired"
+)
+
+var (
+	// ErrUnitNotFound is returned when a unit does not exist on the listing, or is not out on the booking
+	ErrUnitNotFound = errors.New("no unit found")
+	// ErrUnitDuplicate is returned when another unit of the listing has the same serial number or asset tag
+	ErrUnitDuplicate = errors.New("another unit of this inventory has the same serial number or asset tag")
+	// ErrUnitUnavailable is returned when a unit that is not available is handed over
+	ErrUnitUnavailable = errors.New("this unit is not available")
+	// ErrUnitOut is returned when the status of a unit that is out on a booking is changed
+	ErrUnitOut = errors.New("this unit is out on a booking, return it first")
+	// ErrBookingNotFound is returned when a booking does not exist or is not on the user's listing
+	ErrBookingNotFound = errors.New("no booking found")
+	// ErrTooManyUnits is returned when more units are handed over than the booking is for
+	ErrTooManyUnits = errors.New("more units than the booking's quantity")
+	// ErrBookingNotAccepted is returned when units are handed over on a booking the owner has not accepted
+	ErrBookingNotAccepted = errors.New("units can only be handed over on an accepted booking")
+	// ErrNoUnitFields is returned when a unit update changes nothing
+	ErrNoUnitFields = errors.New("no field supplied for update")
+)
+
+const unitColumns = `
+	u.id, u.inventory_id, u.variant_id, u.serial_number, u.asset_tag, u.condition, u.status, u.notes,
+	r.booking_id, u.created_at, u.updated_at`
+
+const unitFrom = `
+	FROM inventory_units u
+	LEFT JOIN inventory_unit_rentals r ON r.unit_id = u.id AND r.returned_at IS NULL`
+
+func scanUnit(scan func(dest ...any) error) (*InventoryUnit, error) {
+	var u InventoryUnit
+	if err := scan(
+		&u.ID, &u.InventoryID, &u.VariantID, &u.SerialNumber, &u.AssetTag, &u.Condition, &u.Status, &u.Notes,
+		&u.BookingID, &u.CreatedAt, &u.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func getInventoryUnit(ctx context.Context, q queryer, inventoryID, unitID string) (*InventoryUnit, error) {
+	u, err := scanUnit(q.QueryRowContext(ctx, `SELECT `+unitColumns+unitFrom+` WHERE u.id = $1 AND u.inventory_id = $2`, unitID, inventoryID).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnitNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select unit: %w", err)
+	}
+	return u, nil
+}
+
+// checkUnitIdentifiers returns ErrUnitDuplicate when a unit of the listing other than unitID
+// already has the serial number or asset tag
+func checkUnitIdentifiers(ctx context.Context, q queryer, inventoryID, unitID string, serialNumber, assetTag *string) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM inventory_units
+			WHERE inventory_id = $1 AND id::text <> $2 AND (serial_number = $3 OR asset_tag = $4)
+		)
+	`, inventoryID, unitID, serialNumber, assetTag).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check unit identifiers: %w", err)
+	}
+	if exists {
+		return ErrUnitDuplicate
+	}
+	return nil
+}
+
+// CreateInventoryUnit adds a unit to a listing
+func (r *PostgresRepository) CreateInventoryUnit(ctx context.Context, unit *InventoryUnit) (*InventoryUnit, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkUnitIdentifiers(ctx, tx, unit.InventoryID, "", unit.SerialNumber, unit.AssetTag); err != nil {
+		return nil, err
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_units (inventory_id, variant_id, serial_number, asset_tag, condition, status, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id
+	`, unit.InventoryID, unit.VariantID, unit.SerialNumber, unit.AssetTag, unit.Condition, unit.Status, unit.Notes).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unit: %w", err)
+	}
+
+	created, err := getInventoryUnit(ctx, tx, unit.InventoryID, id)
+	if err != nil {
+		return nil, err
+	}
+	return created, tx.Commit()
+}
+
+// UpdateInventoryUnitParams changes the non-nil fields of a unit. Status can not be set to out,
+// or changed while the unit is out; see HandOverBookingUnits and ReturnBookingUnits.
+type UpdateInventoryUnitParams struct {
+	InventoryID  string
+	UnitID       string
+	VariantID    *string // "" removes the variant
+	SerialNumber *string // "" removes the serial number
+	AssetTag     *string // "" removes the asset tag
+	Condition    *string
+	Status       *string
+	Notes        *string
+}
+
+// UpdateInventoryUnit applies params to a unit of a listing
+func (r *PostgresRepository) UpdateInventoryUnit(ctx context.Context, params UpdateInventoryUnitParams) (*InventoryUnit, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM inventory_units WHERE id = $1 AND inventory_id = $2 FOR UPDATE`, params.UnitID, params.InventoryID); err != nil {
+		return nil, fmt.Errorf("lock unit: %w", err)
+	}
+	current, err := getInventoryUnit(ctx, tx, params.InventoryID, params.UnitID)
+	if err != nil {
+		return nil, err
+	}
+	if params.Status != nil && *params.Status != current.Status && current.Status == UnitOut {
+		return nil, ErrUnitOut
+	}
+
+	// "" clears the optional columns
+	nullable := func(v *string) *string {
+		if v == nil || *v == "" {
+			return nil
+		}
+		return v
+	}
+	serialNumber, assetTag := current.SerialNumber, current.AssetTag
+	if params.SerialNumber != nil {
+		serialNumber = nullable(params.SerialNumber)
+	}
+	if params.AssetTag != nil {
+		assetTag = nullable(params.AssetTag)
+	}
+	if err := checkUnitIdentifiers(ctx, tx, params.InventoryID, params.UnitID, serialNumber, assetTag); err != nil {
+		return nil, err
+	}
+
+	var (
+		sets   []string
+		args   []interface{}
+		argIdx = 1
+	)
+	columns := []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"variant_id", nullable(params.VariantID), params.VariantID != nil},
+		{"serial_number", serialNumber, params.SerialNumber != nil},
+		{"asset_tag", assetTag, params.AssetTag != nil},
+		{"condition", params.Condition, params.Condition != nil},
+		{"status", params.Status, params.Status != nil},
+		{"notes", params.Notes, params.Notes != nil},
+	}
+	for _, c := range columns {
+		if !c.set {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = $%d", c.name, argIdx))
+		args = append(args, c.value)
+		argIdx++
+	}
+	if len(sets) == 0 {
+		return nil, ErrNoUnitFields
+	}
+	sets = append(sets, "updated_at = NOW()")
+
+	args = append(args, params.UnitID)
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`UPDATE inventory_units SET %s WHERE id = $%d`, strings.Join(sets, ", "), argIdx), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update unit: %w", err)
+	}
+
+	updated, err := getInventoryUnit(ctx, tx, params.InventoryID, params.UnitID)
+	if err != nil {
+		return nil, err
+	}
+	return updated, tx.Commit()
+}
+
+// GetInventoryUnits lists the units of a listing in the order they were added
+func (r *PostgresRepository) GetInventoryUnits(ctx context.Context, inventoryID string) ([]InventoryUnit, error) {
+	rows, err := r.Conn.QueryContext(ctx, `SELECT `+unitColumns+unitFrom+` WHERE u.inventory_id = $1 ORDER BY u.created_at, u.id`, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("select units: %w", err)
+	}
+	defer rows.Close()
+
+	units := []InventoryUnit{}
+	for rows.Next() {
+		u, err := scanUnit(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("scan unit: %w", err)
+		}
+		units = append(units, *u)
+	}
+
+	return units, rows.Err()
+}
+
+const unitRentalSelect = `
+	SELECT r.id, r.unit_id, r.booking_id, b.renter_id, b.start_date, b.end_date,
+		r.condition_out, r.condition_returned, r.notes, r.handed_over_at, r.returned_at
+	FROM inventory_unit_rentals r
+	JOIN inventory_bookings b ON b.id = r.booking_id`
+
+func getUnitRentals(ctx context.Context, q queryer, query string, args ...any) ([]InventoryUnitRental, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select unit rentals: %w", err)
+	}
+	defer rows.Close()
+
+	rentals := []InventoryUnitRental{}
+	for rows.Next() {
+		var rental InventoryUnitRental
+		if err := rows.Scan(
+			&rental.ID, &rental.UnitID, &rental.BookingID, &rental.RenterID, &rental.StartDate, &rental.EndDate,
+			&rental.ConditionOut, &rental.ConditionReturned, &rental.Notes, &rental.HandedOverAt, &rental.ReturnedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan unit rental: %w", err)
+		}
+		rentals = append(rentals, rental)
+	}
+
+	return rentals, rows.Err()
+}
+
+// GetInventoryUnitRentals returns the rental history of a unit of a listing, latest first
+func (r *PostgresRepository) GetInventoryUnitRentals(ctx context.Context, inventoryID, unitID string) ([]InventoryUnitRental, error) {
+	if _, err := getInventoryUnit(ctx, r.Conn, inventoryID, unitID); err != nil {
+		return nil, err
+	}
+	return getUnitRentals(ctx, r.Conn, unitRentalSelect+` WHERE r.unit_id = $1 ORDER BY r.handed_over_at DESC`, unitID)
+}
+
+// UnitHandover is a unit handed over for a booking, in the condition it left in
+type UnitHandover struct {
+	UnitID    string
+	Condition string // "" keeps the unit's condition
+	Notes     string
+}
+
+// UnitReturn is a unit coming back from a booking
+type UnitReturn struct {
+	UnitID      string
+	Condition   string // "" keeps the unit's condition
+	Notes       string
+	Maintenance bool // the unit goes to maintenance instead of being available again
+}
+
+// lockOwnerBooking locks a booking on one of the owner's listings for a handover or return. Units
+// only go out on accepted bookings; units already out can come back whatever became of the booking.
+func lockOwnerBooking(ctx context.Context, tx *sql.Tx, ownerID, bookingID string, handover bool) (inventoryID string, variantID *string, quantity float64, err error) {
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		SELECT inventory_id, variant_id, quantity, status FROM inventory_bookings
+		WHERE id = $1 AND owner_id = $2
+		FOR UPDATE
+	`, bookingID, ownerID).Scan(&inventoryID, &variantID, &quantity, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, 0, ErrBookingNotFound
+	}
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("select booking: %w", err)
+	}
+	if handover && status != "accepted" {
+		return "", nil, 0, ErrBookingNotAccepted
+	}
+	return inventoryID, variantID, quantity, nil
+}
+
+// HandOverBookingUnits records units of the booked listing going out to the renter. Each unit must
+// be available and, when both have one, of the booked variant; a booking gets at most its quantity
+// of units out at a time. It returns all the booking's unit rentals.
+func (r *PostgresRepository) HandOverBookingUnits(ctx context.Context, ownerID, bookingID string, handovers []UnitHandover) ([]InventoryUnitRental, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inventoryID, variantID, quantity, err := lockOwnerBooking(ctx, tx, ownerID, bookingID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var out int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM inventory_unit_rentals WHERE booking_id = $1 AND returned_at IS NULL
+	`, bookingID).Scan(&out); err != nil {
+		return nil, fmt.Errorf("count booking units: %w", err)
+	}
+	if float64(out+len(handovers)) > quantity {
+		return nil, ErrTooManyUnits
+	}
+
+	for _, h := range handovers {
+		var unitVariantID *string
+		var status string
+		err := tx.QueryRowContext(ctx, `
+			SELECT variant_id, status FROM inventory_units WHERE id = $1 AND inventory_id = $2 FOR UPDATE
+		`, h.UnitID, inventoryID).Scan(&unitVariantID, &status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnitNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("select unit: %w", err)
+		}
+		if status != UnitAvailable {
+			return nil, ErrUnitUnavailable
+		}
+		if variantID != nil && unitVariantID != nil && *variantID != *unitVariantID {
+			return nil, fmt.Errorf("%w: it is not of the booked variant", ErrUnitUnavailable)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventory_units
+			SET status = $2, condition = CASE WHEN $3 = '' THEN condition ELSE $3 END, updated_at = NOW()
+			WHERE id = $1
+		`, h.UnitID, UnitOut, h.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update unit: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO inventory_unit_rentals (unit_id, booking_id, condition_out, notes, handed_over_at)
+			SELECT id, $2, condition, $3, NOW() FROM inventory_units WHERE id = $1
+		`, h.UnitID, bookingID, h.Notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record handover: %w", err)
+		}
+	}
+
+	rentals, err := getUnitRentals(ctx, tx, unitRentalSelect+` WHERE r.booking_id = $1 ORDER BY r.handed_over_at`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	return rentals, tx.Commit()
+}
+
+// ReturnBookingUnits records units coming back from a booking and releases them. With no returns
+// every unit still out on the booking comes back as it is. It returns all the booking's unit rentals.
+func (r *PostgresRepository) ReturnBookingUnits(ctx context.Context, ownerID, bookingID string, returns []UnitReturn) ([]InventoryUnitRental, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, _, _, err := lockOwnerBooking(ctx, tx, ownerID, bookingID, false); err != nil {
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		open, err := getUnitRentals(ctx, tx, unitRentalSelect+` WHERE r.booking_id = $1 AND r.returned_at IS NULL`, bookingID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rental := range open {
+			returns = append(returns, UnitReturn{UnitID: rental.UnitID})
+		}
+	}
+
+	for _, ret := range returns {
+		var condition *string
+		if ret.Condition != "" {
+			condition = &ret.Condition
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE inventory_unit_rentals
+			SET returned_at = NOW(), condition_returned = $3,
+			    notes = CASE WHEN $4 = '' THEN notes WHEN notes = '' THEN $4 ELSE notes || E'\n' || $4 END
+			WHERE booking_id = $1 AND unit_id = $2 AND returned_at IS NULL
+		`, bookingID, ret.UnitID, condition, ret.Notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record return: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			return nil, ErrUnitNotFound
+		}
+
+		status := UnitAvailable
+		if ret.Maintenance {
+			status = UnitMaintenance
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventory_units
+			SET status = $2, condition = COALESCE($3, condition), updated_at = NOW()
+			WHERE id = $1
+		`, ret.UnitID, status, condition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to release unit: %w", err)
+		}
+	}
+
+	rentals, err := getUnitRentals(ctx, tx, unitRentalSelect+` WHERE r.booking_id = $1 ORDER BY r.handed_over_at`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	return rentals, tx.Commit()
+}
+
+// committedStockSQL is how much of a listing ($1) its open orders and its pending or accepted
+// bookings that have not ended hold, including bookings of bundles it is part of. Future bookings
+// count from the day they are made, so a listing booked out for later is not sold as well. Bookings
+// are added up whether or not their dates overlap, which errs on the side of holding too much.
+const committedStockSQL = `
+	COALESCE((SELECT SUM(quantity) FROM inventory_sales
+		WHERE inventory_id = $1 AND status IN ('available', 'sold')), 0)
+	+ COALESCE((SELECT SUM(quantity) FROM inventory_bookings
+		WHERE inventory_id = $1 AND status IN ('pending', 'accepted')
+			AND end_date >= CURRENT_DATE), 0)
+	+ COALESCE((SELECT SUM(bc.quantity) FROM inventory_booking_components bc
+		JOIN inventory_bookings b ON b.id = bc.booking_id
+		WHERE bc.inventory_id = $1 AND b.status IN ('pending', 'accepted')
+			AND b.end_date >= CURRENT_DATE), 0)`
+
+// stockLevelSelect reads a listing's ($1) stock along with what the stock check keeps track of
+const stockLevelSelect = `
+	SELECT quantity, ` + committedStockSQL + `, low_stock_threshold, low_stock_alerted_at,
+		sold_out_at, sold_out_hidden, is_available::text, product_purpose::text,
+		EXISTS (SELECT 1 FROM inventory_bundle_components WHERE bundle_id = $1)
+	FROM inventories
+	WHERE id = $1 AND deleted = false`
+
+// stockState is what stockLevelSelect reads besides the StockLevel itself
+type stockState struct {
+	alertedAt sql.NullTime
+	soldOutAt sql.NullTime
+	hidden    bool
+	available string
+	purpose   string
+	bundle    bool
+}
+
+func readStockLevel(ctx context.Context, q queryer, inventoryID, lock string) (*StockLevel, *stockState, error) {
+	var (
+		level = StockLevel{InventoryID: inventoryID}
+		state stockState
+	)
+	err := q.QueryRowContext(ctx, stockLevelSelect+lock, inventoryID).Scan(
+		&level.Quantity, &level.Committed, &level.LowStockThreshold, &state.alertedAt,
+		&state.soldOutAt, &state.hidden, &state.available, &state.purpose, &state.bundle,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read stock: %w", err)
+	}
+	level.Available = math.Max(level.Quantity-level.Committed, 0)
+	return &level, &state, nil
+}
+
+// GetInventoryStock works out a listing's available stock without alerting anyone or changing it
+func (r *PostgresRepository) GetInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error) {
+	level, _, err := readStockLevel(ctx, r.Conn, inventoryID, "")
+	return level, err
+}
+
+// CheckInventoryStock works out a listing's available stock and alerts its owner when it drops
+// below the listing's threshold. A sale listing with nothing left is marked unavailable and its
+// owner told it is sold out, and is made available again once stock is back, unless the owner
+// changed its availability in between. Rentals only get the low stock alert, since their stock
+// comes back when bookings end. Each alert is sent once until stock recovers.
+func (r *PostgresRepository) CheckInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	level, state, err := readStockLevel(ctx, tx, inventoryID, " FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+
+	// a bundle's stock is that of its components, which alert on their own
+	if state.bundle {
+		return level, tx.Commit()
+	}
+
+	notify := func(kind, message string) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO notifications (user_id, kind, inventory_id, message, created_at)
+			SELECT user_id, $2, id, format($3, name, $4::text), NOW()
+			FROM inventories WHERE id = $1
+		`, inventoryID, kind, message, strconv.FormatFloat(level.Available, 'f', -1, 64))
+		if err != nil {
+			return fmt.Errorf("failed to notify owner: %w", err)
+		}
+		return nil
+	}
+
+	// setAvailability marks the listing available or not on behalf of the stock check
+	setAvailability := func(available string, hidden bool) error {
+		err := auditInventory(ctx, tx, inventoryID, InventoryAvailabilityChanged, func() error {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE inventories SET is_available = $2, sold_out_hidden = $3, updated_at = NOW() WHERE id = $1
+			`, inventoryID, available, hidden)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update inventory availability: %w", err)
+		}
+		return syncBundleQuantities(ctx, tx, inventoryID)
+	}
+
+	if level.Available == 0 && state.purpose == "sale" && !state.soldOutAt.Valid {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE inventories SET sold_out_at = NOW(), low_stock_alerted_at = NOW() WHERE id = $1
+		`, inventoryID); err != nil {
+			return nil, fmt.Errorf("failed to record sold out: %w", err)
+		}
+		if state.available == "yes" {
+			if err := setAvailability("no", true); err != nil {
+				return nil, err
+			}
+		}
+		if err := notify(NotificationSoldOut, `Your listing "%s" is sold out and has been marked unavailable.`); err != nil {
+			return nil, err
+		}
+		level.Alerted = NotificationSoldOut
+		return level, tx.Commit()
+	}
+
+	// stock is back, so a listing the sold out check hid is shown again and alerts start over
+	if level.Available > 0 && state.soldOutAt.Valid {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE inventories SET sold_out_at = NULL, low_stock_alerted_at = NULL WHERE id = $1
+		`, inventoryID); err != nil {
+			return nil, fmt.Errorf("failed to clear sold out: %w", err)
+		}
+		if state.hidden && state.available == "no" {
+			if err := setAvailability("yes", false); err != nil {
+				return nil, err
+			}
+		}
+		state.alertedAt.Valid = false
+	}
+
+	low := level.LowStockThreshold != nil && level.Available < *level.LowStockThreshold
+	switch {
+	case low && !state.alertedAt.Valid:
+		if _, err := tx.ExecContext(ctx, `UPDATE inventories SET low_stock_alerted_at = NOW() WHERE id = $1`, inventoryID); err != nil {
+			return nil, fmt.Errorf("failed to record low stock alert: %w", err)
+		}
+		if err := notify(NotificationLowStock, `Your listing "%s" is running low, only %s left.`); err != nil {
+			return nil, err
+		}
+		level.Alerted = NotificationLowStock
+
+	case !low && state.alertedAt.Valid:
+		// stock has recovered, so the next drop alerts again
+		if _, err := tx.ExecContext(ctx, `UPDATE inventories SET low_stock_alerted_at = NULL WHERE id = $1`, inventoryID); err != nil {
+			return nil, fmt.Errorf("failed to reset low stock alert: %w", err)
+		}
+	}
+
+	return level, tx.Commit()
+}
+
+// SetLowStockThreshold sets the available quantity below which the owner of a listing is alerted,
+// or turns the alerts off when threshold is nil
+func (r *PostgresRepository) SetLowStockThreshold(ctx context.Context, userID, inventoryID string, threshold *float64) error {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = auditInventory(ctx, tx, inventoryID, InventoryStockAlertChanged, func() error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE inventories SET low_stock_threshold = $3, low_stock_alerted_at = NULL, updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND deleted = false
+		`, inventoryID, userID, threshold)
+		if err != nil {
+			return fmt.Errorf("failed to update low stock threshold: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrInventoryNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var (
+	// ErrBundleNotAllowed is returned when components are set on a listing that can not be a bundle
+	ErrBundleNotAllowed = errors.New("a bundle must be a rental listing without variants that is not part of another bundle")
+	// ErrBundleComponentInvalid is returned when a component is not another of the owner's rental listings
+	// without variants, or is a bundle itself
+	ErrBundleComponentInvalid = errors.New("bundle components must be other rental listings of yours without variants and not bundles themselves")
+	// ErrBundleUnavailable is returned when a bundle is booked for more than its components cover over the window
+	ErrBundleUnavailable = errors.New("not enough of every bundle component is free for these dates")
+	// ErrComponentUnavailable is returned when a listing that is part of a bundle is booked for more
+	// than is left over the window after its own bookings and those of its bundles
+	ErrComponentUnavailable = errors.New("not enough of this listing is free for these dates")
+)
+
+// bundleComponentStockSQL is a component's stock, nothing when it can not be booked
+const bundleComponentStockSQL = `
+	CASE WHEN i.deleted OR i.deactivated OR i.is_available::text <> 'yes' THEN 0 ELSE i.quantity END`
+
+// reservedInWindowSQL is how much of a listing (the column given) is held from $2 to $3 by pending or
+// accepted bookings that overlap the window, of the listing itself or of a bundle it is part of
+func reservedInWindowSQL(column string) string {
+	return `
+	COALESCE((SELECT SUM(b.quantity) FROM inventory_bookings b
+		WHERE b.inventory_id = ` + column + ` AND b.status IN ('pending', 'accepted')
+			AND b.start_date <= $3 AND b.end_date >= $2), 0)
+	+ COALESCE((SELECT SUM(bc.quantity) FROM inventory_booking_components bc
+		JOIN inventory_bookings b ON b.id = bc.booking_id
+		WHERE bc.inventory_id = ` + column + ` AND b.status IN ('pending', 'accepted')
+			AND b.start_date <= $3 AND b.end_date >= $2), 0)`
+}
+
+func getBundleComponents(ctx context.Context, q queryer, bundleID string) ([]BundleComponent, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.component_id, i.name, i.slug, COALESCE(i.primary_image, ''), c.quantity
+		FROM inventory_bundle_components c
+		JOIN inventories i ON i.id = c.component_id
+		WHERE c.bundle_id = $1
+		ORDER BY i.name, c.component_id
+	`, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("select bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	var components []BundleComponent
+	for rows.Next() {
+		var c BundleComponent
+		if err := rows.Scan(&c.ComponentID, &c.Name, &c.Slug, &c.PrimaryImage, &c.Quantity); err != nil {
+			return nil, fmt.Errorf("scan bundle component: %w", err)
+		}
+		components = append(components, c)
+	}
+
+	return components, rows.Err()
+}
+
+func (r *PostgresRepository) GetBundleComponents(ctx context.Context, bundleID string) ([]BundleComponent, error) {
+	return getBundleComponents(ctx, r.Conn, bundleID)
+}
+
+// syncBundleQuantities sets the quantity of a bundle, and of every bundle a listing is part of, to
+// how many bundles the stock of its components makes up, so search and listing reads show it like
+// any other listing
+func syncBundleQuantities(ctx context.Context, tx *sql.Tx, inventoryID string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.bundle_id, MIN(FLOOR((`+bundleComponentStockSQL+`) / c.quantity))
+		FROM inventory_bundle_components c
+		JOIN inventories i ON i.id = c.component_id
+		WHERE c.bundle_id IN (
+			SELECT bundle_id FROM inventory_bundle_components WHERE bundle_id = $1 OR component_id = $1
+		)
+		GROUP BY c.bundle_id
+	`, inventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to total bundle components: %w", err)
+	}
+
+	quantities := make(map[string]float64)
+	for rows.Next() {
+		var bundleID string
+		var quantity float64
+		if err := rows.Scan(&bundleID, &quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan bundle quantity: %w", err)
+		}
+		quantities[bundleID] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for bundleID, quantity := range quantities {
+		available := "no"
+		if quantity > 0 {
+			available = "yes"
+		}
+		err := auditInventory(ctx, tx, bundleID, InventoryAvailabilityChanged, func() error {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE inventories SET quantity = $1, is_available = $2, updated_at = NOW()
+				WHERE id = $3 AND (quantity <> $1 OR is_available::text <> $2)
+			`, quantity, available, bundleID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sync bundle quantity: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetBundleComponents makes one of an owner's rental listings a bundle of others, replacing its
+// components. No components turns it back into an ordinary listing.
+func (r *PostgresRepository) SetBundleComponents(ctx context.Context, ownerID, bundleID string, components []BundleComponent) ([]BundleComponent, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var purpose string
+	var hasVariants, isComponent bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT product_purpose::text,
+			EXISTS (SELECT 1 FROM inventory_variants WHERE inventory_id = i.id),
+			EXISTS (SELECT 1 FROM inventory_bundle_components WHERE component_id = i.id)
+		FROM inventories i
+		WHERE i.id = $1 AND i.user_id = $2 AND i.deleted = false
+		FOR UPDATE
+	`, bundleID, ownerID).Scan(&purpose, &hasVariants, &isComponent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	ids := make([]string, 0, len(components))
+	quantities := make([]int32, 0, len(components))
+	for _, c := range components {
+		if c.ComponentID == bundleID {
+			return nil, ErrBundleComponentInvalid
+		}
+		ids = append(ids, c.ComponentID)
+		quantities = append(quantities, c.Quantity)
+	}
+
+	if len(components) > 0 {
+		if purpose != "rental" || hasVariants || isComponent {
+			return nil, ErrBundleNotAllowed
+		}
+
+		// components are locked in id order, as bundle bookings lock them
+		rows, err := tx.QueryContext(ctx, `
+			SELECT i.id FROM inventories i
+			WHERE i.id = ANY($1) AND i.user_id = $2 AND i.deleted = false
+				AND i.product_purpose::text = 'rental'
+				AND NOT EXISTS (SELECT 1 FROM inventory_variants v WHERE v.inventory_id = i.id)
+				AND NOT EXISTS (SELECT 1 FROM inventory_bundle_components c WHERE c.bundle_id = i.id)
+			ORDER BY i.id
+			FOR UPDATE
+		`, pq.Array(ids), ownerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check bundle components: %w", err)
+		}
+		valid := 0
+		for rows.Next() {
+			valid++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if valid != len(ids) {
+			return nil, ErrBundleComponentInvalid
+		}
+	}
+
+	err = auditInventory(ctx, tx, bundleID, InventoryBundleChanged, func() error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM inventory_bundle_components WHERE bundle_id = $1`, bundleID); err != nil {
+			return fmt.Errorf("failed to clear bundle components: %w", err)
+		}
+		if len(components) == 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO inventory_bundle_components (bundle_id, component_id, quantity, created_at)
+			SELECT $1, c.id, c.quantity, NOW()
+			FROM unnest($2::uuid[], $3::int[]) AS c (id, quantity)
+		`, bundleID, pq.Array(ids), pq.Array(quantities))
+		if err != nil {
+			return fmt.Errorf("failed to save bundle components: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syncBundleQuantities(ctx, tx, bundleID); err != nil {
+		return nil, err
+	}
+
+	saved, err := getBundleComponents(ctx, tx, bundleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return saved, tx.Commit()
+}
+
+// bundleAvailability works out how much of each component of a bundle is free from start to end,
+// counting every pending or accepted booking that overlaps the window, of the component itself or
+// of a bundle it is part of
+func bundleAvailability(ctx context.Context, q queryer, bundleID string, start, end time.Time) (*BundleAvailability, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.component_id, i.name, i.slug, COALESCE(i.primary_image, ''), c.quantity,
+			`+bundleComponentStockSQL+`, `+reservedInWindowSQL("c.component_id")+`
+		FROM inventory_bundle_components c
+		JOIN inventories i ON i.id = c.component_id
+		WHERE c.bundle_id = $1
+		ORDER BY i.name, c.component_id
+	`, bundleID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("select bundle availability: %w", err)
+	}
+	defer rows.Close()
+
+	availability := BundleAvailability{BundleID: bundleID, StartDate: start, EndDate: end, Components: []BundleComponentAvailability{}}
+	for rows.Next() {
+		var c BundleComponentAvailability
+		if err := rows.Scan(&c.ComponentID, &c.Name, &c.Slug, &c.PrimaryImage, &c.Quantity, &c.Stock, &c.Reserved); err != nil {
+			return nil, fmt.Errorf("scan bundle availability: %w", err)
+		}
+		c.Available = math.Max(c.Stock-c.Reserved, 0)
+		c.Bundles = int32(c.Available / float64(c.Quantity))
+		if len(availability.Components) == 0 || c.Bundles < availability.Available {
+			availability.Available = c.Bundles
+		}
+		availability.Components = append(availability.Components, c)
+	}
+
+	return &availability, rows.Err()
+}
+
+// GetBundleAvailability returns how many of a bundle can be booked from start to end
+func (r *PostgresRepository) GetBundleAvailability(ctx context.Context, bundleID string, start, end time.Time) (*BundleAvailability, error) {
+	return bundleAvailability(ctx, r.Conn, bundleID, start, end)
+}
+
+// CreateBundleBooking books a bundle, holding the stock of every component for the booking's dates.
+// The components are locked while their availability is checked, so two bookings can not both take
+// the last of one.
+func (r *PostgresRepository) CreateBundleBooking(ctx context.Context, p *CreateBookingPayload) (*InventoryBooking, error) {
+	tx, err := r.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// in id order, so bookings of bundles that share components do not deadlock
+	_, err = tx.ExecContext(ctx, `
+		SELECT 1 FROM inventories
+		WHERE id IN (SELECT component_id FROM inventory_bundle_components WHERE bundle_id = $1)
+		ORDER BY id
+		FOR UPDATE
+	`, p.InventoryId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock bundle components: %w", err)
+	}
+
+	availability, err := bundleAvailability(ctx, tx, p.InventoryId, p.StartDate, p.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	if len(availability.Components) == 0 {
+		return nil, ErrInventoryNotFound
+	}
+	if availability.Available < p.Quantity {
+		return nil, fmt.Errorf("%w, only %d available", ErrBundleUnavailable, availability.Available)
+	}
+
+	booking, err := insertBooking(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO inventory_booking_components (booking_id, inventory_id, quantity)
+		SELECT $1, component_id, quantity * $3
+		FROM inventory_bundle_components
+		WHERE bundle_id = $2
+	`, booking.ID, p.InventoryId, p.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hold bundle components: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bundle booking: %w", err)
+	}
+
+	return booking, nil
+}
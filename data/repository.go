@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Repository interface {
@@ -18,7 +19,7 @@ type Repository interface {
 	GetCategoryByID(ctx context.Context, p *GetCategoryByIDPayload) (*Category, error)
 	GetcategorySubcategories(ctx context.Context, id string) ([]*Subcategory, error)
 	GetSubcategoryByID(ctx context.Context, id string) (*Subcategory, error)
-	CreateInventory(req *CreateInventoryParams) error
+	CreateInventory(req *CreateInventoryParams) (*Inventory, error)
 	CreateInventoryRating(ctx context.Context, inventoryId string, raterId string, userId string, comment string, rating int32) (*InventoryRating, error)
 	CreateUserRating(ctx context.Context, userId string, rating int32, comment string, raterId string) (*UserRating, error)
 	GetUserByID(ctx context.Context, id string) (*User, error)
@@ -47,6 +48,113 @@ type Repository interface {
 	SaveInventory(ctx context.Context, userId, inventoryId string) error
 	DeleteSaveInventory(ctx context.Context, id, userId, inventoryId string) error
 	DeleteInventory(ctx context.Context, detail DeleteInventoryPayload) error
+	UpdateInventory(ctx context.Context, detail UpdateInventoryParams) error
+	GetInventoryImages(ctx context.Context, inventoryID string) ([]InventoryImage, error)
+	AddInventoryImages(ctx context.Context, inventoryID string, uploads []ImageURLs) ([]InventoryImage, error)
+	DeleteInventoryImage(ctx context.Context, inventoryID, imageID string) (*InventoryImage, error)
+	ReorderInventoryImages(ctx context.Context, inventoryID string, imageIDs []string) error
+	SetPrimaryInventoryImage(ctx context.Context, inventoryID, imageID string) error
+
+	CreateInventoryJob(ctx context.Context, job *InventoryJob) (*InventoryJob, error)
+	GetInventoryJob(ctx context.Context, jobID string) (*InventoryJob, error)
+	GetInventoryJobImageData(ctx context.Context, imageID string) ([]byte, error)
+	SetInventoryJobStatus(ctx context.Context, jobID, status string) error
+	SetInventoryJobImageResult(ctx context.Context, imageID, status string, urls *ImageURLs, errMsg *string) error
+	FailInventoryJob(ctx context.Context, jobID, message string) error
+	RetryInventoryJob(ctx context.Context, jobID string) error
+	GetUnfinishedInventoryJobs(ctx context.Context) ([]InventoryJob, error)
+
+	GetInventoryVariants(ctx context.Context, inventoryID string) ([]InventoryVariant, error)
+	GetInventoryVariant(ctx context.Context, inventoryID, variantID string) (*InventoryVariant, error)
+	CreateInventoryVariant(ctx context.Context, variant *InventoryVariant) (*InventoryVariant, error)
+	UpdateInventoryVariant(ctx context.Context, p UpdateInventoryVariantParams) (*InventoryVariant, error)
+	DeleteInventoryVariant(ctx context.Context, inventoryID, variantID string) error
+
+	IsBusinessAccount(ctx context.Context, userID string) (bool, error)
+	GetAvailablePostings(ctx context.Context, userID string) (int32, error)
+	ResolveTaxonomySlugs(ctx context.Context, categorySlug, subcategorySlug, stateSlug, lgaSlug string) (*TaxonomyIDs, error)
+	CreateImportBatch(ctx context.Context, batch *InventoryImportBatch) (*InventoryImportBatch, error)
+	GetImportBatch(ctx context.Context, batchID string) (*InventoryImportBatch, error)
+	SetImportBatchStatus(ctx context.Context, batchID, status string) error
+	SetImportRowResult(ctx context.Context, rowID, status string, jobID, errMsg *string) error
+	GetUnfinishedImportBatches(ctx context.Context) ([]string, error)
+
+	ExportMyInventories(ctx context.Context, filter ExportFilter, fn func(InventoryExportRow) error) error
+	ExportBookings(ctx context.Context, filter ExportFilter, asOwner bool, fn func(BookingExportRow) error) error
+	ExportSales(ctx context.Context, filter ExportFilter, asSeller bool, fn func(SaleExportRow) error) error
+
+	GetDuplicateCandidates(ctx context.Context, userID, excludeID string) ([]DuplicateCandidate, error)
+	GetInventoryImageHashes(ctx context.Context, inventoryID string) ([]int64, error)
+	FlagInventoryDuplicates(ctx context.Context, inventoryID string, matches []DuplicateMatch) error
+
+	GetInventoryHistory(ctx context.Context, inventoryID string) ([]InventoryHistoryEntry, error)
+	GetInventoryOwner(ctx context.Context, inventoryID string) (string, error)
+
+	GetDeletedInventories(ctx context.Context, userID string, window time.Duration) ([]DeletedInventory, error)
+	RestoreInventory(ctx context.Context, userID, inventoryID string, window time.Duration) error
+	GetPurgeableInventories(ctx context.Context, window time.Duration, limit int) ([]string, error)
+	PurgeInventory(ctx context.Context, inventoryID string) ([]InventoryImage, error)
+
+	GetListingLifetimeDays(ctx context.Context, userID string) (*int32, error)
+	ScheduleInventory(ctx context.Context, userID, inventoryID string, publishAt, expiresAt *time.Time) error
+	RenewInventory(ctx context.Context, userID, inventoryID string, expiresAt *time.Time) error
+	GetInventorySchedule(ctx context.Context, inventoryID string) (publishAt, expiresAt *time.Time, err error)
+	QueueExpiryReminders(ctx context.Context, within time.Duration) (int64, error)
+	BackfillListingExpiry(ctx context.Context, defaultDays int) (int64, error)
+	GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit int32) ([]Notification, error)
+	MarkNotificationsRead(ctx context.Context, userID string, ids []string) error
+
+	GetPostingQuota(ctx context.Context, userID string) (*PostingQuota, error)
+
+	GetPromotionPrice(ctx context.Context, scope string, days int) (float64, error)
+	CreateInventoryPromotion(ctx context.Context, promotion *InventoryPromotion) (*InventoryPromotion, error)
+	GetInventoryPromotions(ctx context.Context, inventoryID string) ([]InventoryPromotion, error)
+	RecordPromotionImpressions(ctx context.Context, promotionIDs []string) error
+	RecordPromotionClick(ctx context.Context, inventoryID, scope, visitorKey string) error
+
+	GetSubcategoryAttributes(ctx context.Context, subcategoryID string) ([]SubcategoryAttribute, error)
+	SaveSubcategoryAttribute(ctx context.Context, attribute *SubcategoryAttribute) (*SubcategoryAttribute, error)
+	DeleteSubcategoryAttribute(ctx context.Context, subcategoryID, name string) error
+
+	GetPopularTags(ctx context.Context, categoryID string, limit int) ([]TagCount, error)
+	SetTagSynonym(ctx context.Context, synonym, canonical string) error
+
+	ResolveSlugRedirect(ctx context.Context, kind, slug string) (string, error)
+
+	RecordInventoryEvents(ctx context.Context, kind, visitorKey string, inventoryIDs []string) error
+	PruneInventoryEvents(ctx context.Context) (int64, error)
+	GetInventoryDailyStats(ctx context.Context, inventoryID string, from time.Time) ([]InventoryDailyStats, error)
+	GetUserInventoryStats(ctx context.Context, userID string, from time.Time) ([]InventoryStatsSummary, error)
+
+	AskInventoryQuestion(ctx context.Context, question *InventoryQuestion) (*InventoryQuestion, error)
+	AnswerInventoryQuestion(ctx context.Context, ownerID, questionID, answer string) (*InventoryQuestion, error)
+	GetInventoryQuestions(ctx context.Context, inventoryID string, all bool) ([]InventoryQuestion, error)
+	ReportInventoryQuestion(ctx context.Context, userID, questionID string) error
+	SetInventoryQuestionHidden(ctx context.Context, ownerID, questionID string, hidden bool) error
+
+	SetBusinessLocation(ctx context.Context, userID string, point *GeoPoint) error
+	SetLgaCentroid(ctx context.Context, lgaID string, point *GeoPoint) error
+
+	GetSitemapPages(ctx context.Context, section string, size int) ([]SitemapPage, error)
+	StreamSitemapEntries(ctx context.Context, section, from string, limit int, fn func(SitemapEntry) error) error
+	StreamProductFeed(ctx context.Context, fn func(ProductFeedItem) error) error
+
+	CreateInventoryUnit(ctx context.Context, unit *InventoryUnit) (*InventoryUnit, error)
+	UpdateInventoryUnit(ctx context.Context, params UpdateInventoryUnitParams) (*InventoryUnit, error)
+	GetInventoryUnits(ctx context.Context, inventoryID string) ([]InventoryUnit, error)
+	GetInventoryUnitRentals(ctx context.Context, inventoryID, unitID string) ([]InventoryUnitRental, error)
+	HandOverBookingUnits(ctx context.Context, ownerID, bookingID string, handovers []UnitHandover) ([]InventoryUnitRental, error)
+	ReturnBookingUnits(ctx context.Context, ownerID, bookingID string, returns []UnitReturn) ([]InventoryUnitRental, error)
+
+	GetInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error)
+	CheckInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error)
+	SetLowStockThreshold(ctx context.Context, userID, inventoryID string, threshold *float64) error
+
+	GetBundleComponents(ctx context.Context, bundleID string) ([]BundleComponent, error)
+	SetBundleComponents(ctx context.Context, ownerID, bundleID string, components []BundleComponent) ([]BundleComponent, error)
+	GetBundleAvailability(ctx context.Context, bundleID string, start, end time.Time) (*BundleAvailability, error)
+	CreateBundleBooking(ctx context.Context, p *CreateBookingPayload) (*InventoryBooking, error)
+
 	DeleteChat(ctx context.Context, id, userId string) error
 	GetSavedInventoryByUserIDAndInventoryID(ctx context.Context, userId, inventoryId string) (*SavedInventory, error)
 	GetInventoryWithSuppliedID(ctx context.Context, inventoryId string) (*Inventory, error)
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSearchOrderBy(t *testing.T) {
+	near := &GeoPoint{Latitude: 6.45, Longitude: 3.39}
+
+	tests := []struct {
+		name    string
+		payload SearchPayload
+		textIdx int
+		prefix  string
+		err     error
+	}{
+		{"promoted by default", SearchPayload{}, 0, "pr.id IS NOT NULL DESC, ", nil},
+		{"nearest by default near a point", SearchPayload{Near: near}, 0, "distance, ", nil},
+		{"relevance", SearchPayload{Sort: SortRelevance}, 3, "ts_rank(", nil},
+		{"relevance without text", SearchPayload{Sort: SortRelevance}, 0, "", ErrInvalidSearchSort},
+		{"price ascending", SearchPayload{Sort: SortPriceAsc}, 0, "l.offer_price ASC NULLS LAST, ", nil},
+		{"price descending", SearchPayload{Sort: SortPriceDesc}, 0, "l.offer_price DESC NULLS LAST, ", nil},
+		{"rating", SearchPayload{Sort: SortRating}, 0, "COALESCE(rs.rating_average, 0) DESC, ", nil},
+		{"rating count", SearchPayload{Sort: SortRatingCount}, 0, "COALESCE(rs.rating_count, 0) DESC, ", nil},
+		{"newest", SearchPayload{Sort: SortNewest}, 0, "l.created_at DESC", nil},
+		{"nearest without a point", SearchPayload{Sort: SortNearest}, 0, "", ErrInvalidSearchSort},
+		{"unknown", SearchPayload{Sort: "cheapest"}, 0, "", ErrInvalidSearchSort},
+	}
+
+	for _, tt := range tests {
+		got, err := searchOrderBy(&tt.payload, tt.textIdx)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got error %v; want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		// every order ends with the same tie-breakers, so pages are stable
+		if !strings.HasPrefix(got, tt.prefix) || !strings.HasSuffix(got, "l.created_at DESC, l.id DESC") {
+			t.Errorf("%s: got %q; want it to start with %q", tt.name, got, tt.prefix)
+		}
+	}
+}
@@ -4,11 +4,47 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 )
 
 type PostgresTestRepository struct {
 	Conn *sql.DB
+
+	// Attributes are returned by GetSubcategoryAttributes for every subcategory
+	Attributes []SubcategoryAttribute
+	// Searches records the payload of every SearchInventory call
+	Searches []*SearchPayload
+	// DailyStats are returned by GetInventoryDailyStats for every listing
+	DailyStats []InventoryDailyStats
+
+	// Handovers records the units of every HandOverBookingUnits call, which fails with UnitErr when it is set
+	Handovers [][]UnitHandover
+	UnitErr   error
+
+	mu sync.Mutex
+	// Events records every RecordInventoryEvents call
+	Events []TestInventoryEvents
+	// Postings is what is left on the user's subscription. Queueing or retrying a creation job takes
+	// one and failing the job gives it back, as reservePosting and FailInventoryJob do.
+	Postings int32
+	// Jobs are the creation jobs by id; holding marks the ones that hold a posting
+	Jobs    map[string]*InventoryJob
+	holding map[string]bool
+}
+
+// TestInventoryEvents is one RecordInventoryEvents call on the test repository
+type TestInventoryEvents struct {
+	Kind         string
+	VisitorKey   string
+	InventoryIDs []string
+}
+
+// RecordedEvents returns the events recorded so far, which handlers may record in the background
+func (u *PostgresTestRepository) RecordedEvents() []TestInventoryEvents {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]TestInventoryEvents(nil), u.Events...)
 }
 
 func NewPostgresTestRepository(db *sql.DB) *PostgresTestRepository {
@@ -136,9 +172,9 @@ func (u *PostgresTestRepository) GetSubcategoryByID(ctx context.Context, id stri
 	return &subCategory, nil
 }
 
-func (u *PostgresTestRepository) CreateInventory(tx *sql.Tx, ctx context.Context, name string, description string, userId string, categoryId string, subcategoryId string, urls []string) error {
+func (u *PostgresTestRepository) CreateInventory(req *CreateInventoryParams) (*Inventory, error) {
 
-	return nil
+	return u.GetInventoryByID(context.Background(), "")
 }
 
 func (u *PostgresTestRepository) GetInventoryByID(ctx context.Context, id string) (*Inventory, error) {
@@ -191,10 +227,10 @@ func (u *PostgresTestRepository) CreateUserRating(
 
 	userRating := UserRating{
 		ID:        "6a7b83f0-30cb-4854-a32e-3576bf491858",
-		UserId:    "827db1c7-cb77-4663-9153-9e1efc722eec",
-		RaterId:   "7a937e9d-1dc2-4e6d-ba38-d1648b05730c",
-		Rating:    5,
-		Comment:   "nice response",
+		UserId:    userId,
+		RaterId:   raterId,
+		Rating:    rating,
+		Comment:   comment,
 		UpdatedAt: time.Now(),
 		CreatedAt: time.Now(),
 	}
@@ -216,3 +252,758 @@ func (u *PostgresTestRepository) GetUserByID(ctx context.Context, id string) (*U
 
 	return &user, nil
 }
+
+func (u *PostgresTestRepository) GetSubcategoryAttributes(ctx context.Context, subcategoryID string) ([]SubcategoryAttribute, error) {
+	return u.Attributes, nil
+}
+
+func (u *PostgresTestRepository) SearchInventory(ctx context.Context, param *SearchPayload) (*InventoryCollection, error) {
+	u.Searches = append(u.Searches, param)
+
+	// the order is checked as SearchInventory checks it
+	textIdx := 0
+	if param.Text != "" {
+		textIdx = 1
+	}
+	if _, err := searchOrderBy(param, textIdx); err != nil {
+		return nil, err
+	}
+	return &InventoryCollection{}, nil
+}
+
+func (u *PostgresTestRepository) RecordInventoryEvents(ctx context.Context, kind, visitorKey string, inventoryIDs []string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Events = append(u.Events, TestInventoryEvents{Kind: kind, VisitorKey: visitorKey, InventoryIDs: inventoryIDs})
+	return nil
+}
+
+func (u *PostgresTestRepository) GetInventoryDailyStats(ctx context.Context, inventoryID string, from time.Time) ([]InventoryDailyStats, error) {
+	return u.DailyStats, nil
+}
+
+func (u *PostgresTestRepository) HandOverBookingUnits(ctx context.Context, ownerID, bookingID string, handovers []UnitHandover) ([]InventoryUnitRental, error) {
+	if u.UnitErr != nil {
+		return nil, u.UnitErr
+	}
+	u.Handovers = append(u.Handovers, handovers)
+
+	rentals := make([]InventoryUnitRental, 0, len(handovers))
+	for _, h := range handovers {
+		rentals = append(rentals, InventoryUnitRental{UnitID: h.UnitID, BookingID: bookingID, ConditionOut: h.Condition, Notes: h.Notes, HandedOverAt: time.Now()})
+	}
+	return rentals, nil
+}
+
+func (u *PostgresTestRepository) GetAvailablePostings(ctx context.Context, userID string) (int32, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Postings, nil
+}
+
+func (u *PostgresTestRepository) CreateInventoryJob(ctx context.Context, job *InventoryJob) (*InventoryJob, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.Postings <= 0 {
+		return nil, ErrPostingQuotaExceeded
+	}
+	u.Postings--
+
+	if u.Jobs == nil {
+		u.Jobs = make(map[string]*InventoryJob)
+		u.holding = make(map[string]bool)
+	}
+	created := *job
+	created.ID = fmt.Sprintf("job-%d", len(u.Jobs)+1)
+	created.Status = InventoryJobQueued
+	created.Images = nil
+	for idx, img := range job.Images {
+		img.ID = fmt.Sprintf("%s-image-%d", created.ID, idx+1)
+		img.JobID = created.ID
+		img.Status = InventoryJobImagePending
+		created.Images = append(created.Images, img)
+	}
+	u.Jobs[created.ID] = &created
+	u.holding[created.ID] = true
+
+	return copyTestJob(&created), nil
+}
+
+func (u *PostgresTestRepository) GetInventoryJob(ctx context.Context, jobID string) (*InventoryJob, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	job, ok := u.Jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("%w with ID %s", ErrInventoryJobNotFound, jobID)
+	}
+	return copyTestJob(job), nil
+}
+
+func (u *PostgresTestRepository) GetInventoryJobImageData(ctx context.Context, imageID string) ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, job := range u.Jobs {
+		for _, img := range job.Images {
+			if img.ID == imageID {
+				return img.ImageData, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("image data for %s is no longer available", imageID)
+}
+
+func (u *PostgresTestRepository) SetInventoryJobStatus(ctx context.Context, jobID, status string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if job, ok := u.Jobs[jobID]; ok {
+		job.Status = status
+	}
+	return nil
+}
+
+func (u *PostgresTestRepository) SetInventoryJobImageResult(ctx context.Context, imageID, status string, urls *ImageURLs, errMsg *string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, job := range u.Jobs {
+		for idx := range job.Images {
+			if job.Images[idx].ID == imageID {
+				job.Images[idx].Status = status
+				job.Images[idx].Error = errMsg
+				if urls != nil {
+					job.Images[idx].URL = &urls.Full
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (u *PostgresTestRepository) FailInventoryJob(ctx context.Context, jobID, message string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	job, ok := u.Jobs[jobID]
+	if !ok || job.Status == InventoryJobSucceeded {
+		return nil
+	}
+	job.Status = InventoryJobFailed
+	job.Error = &message
+	if u.holding[jobID] {
+		u.holding[jobID] = false
+		u.Postings++
+	}
+	return nil
+}
+
+func (u *PostgresTestRepository) RetryInventoryJob(ctx context.Context, jobID string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	job, ok := u.Jobs[jobID]
+	if !ok || job.Status != InventoryJobFailed {
+		return fmt.Errorf("only failed jobs can be retried")
+	}
+	if !u.holding[jobID] {
+		if u.Postings <= 0 {
+			return ErrPostingQuotaExceeded
+		}
+		u.Postings--
+		u.holding[jobID] = true
+	}
+	job.Status = InventoryJobQueued
+	job.Error = nil
+	job.Attempts++
+	return nil
+}
+
+// copyTestJob copies a job so callers can not change the stored one
+func copyTestJob(job *InventoryJob) *InventoryJob {
+	copied := *job
+	copied.Images = append([]InventoryJobImage(nil), job.Images...)
+	return &copied
+}
+
+// The taxonomy fixtures below form one valid hierarchy: the Boats subcategory of its category,
+// and an lga of a state of a country.
+
+func (u *PostgresTestRepository) GetCategoryByID(ctx context.Context, p *GetCategoryByIDPayload) (*Category, error) {
+	return &Category{
+		ID:           "9c47613d-0beb-4f46-91e0-14ad5fb88548",
+		Name:         "Vehicles",
+		CategorySlug: "vehicles",
+		UpdatedAt:    time.Now(),
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+func (u *PostgresTestRepository) GetCountryByID(ctx context.Context, country_id string) (*Country, error) {
+	return &Country{ID: "d922a911-2d03-4479-9170-4bd44e68b5f2", Name: "Nigeria", Code: "NG"}, nil
+}
+
+func (u *PostgresTestRepository) GetStateByID(ctx context.Context, state_id string) (*State, error) {
+	return &State{
+		ID:        "bfd2d6b0-2ed3-4274-9950-a26db49f606a",
+		Name:      "Lagos",
+		StateSlug: "lagos",
+		CountryID: "d922a911-2d03-4479-9170-4bd44e68b5f2",
+	}, nil
+}
+
+func (u *PostgresTestRepository) GetLgaByID(ctx context.Context, lga_id string) (*Lga, error) {
+	return &Lga{
+		ID:      "bc82cf4e-7809-484f-aa43-8a988922bbfc",
+		Name:    "Ikeja",
+		LgaSlug: "ikeja",
+		StateID: "bfd2d6b0-2ed3-4274-9950-a26db49f606a",
+	}, nil
+}
+
+func (u *PostgresTestRepository) ResolveTaxonomySlugs(ctx context.Context, categorySlug, subcategorySlug, stateSlug, lgaSlug string) (*TaxonomyIDs, error) {
+	return &TaxonomyIDs{
+		CategoryID:    "9c47613d-0beb-4f46-91e0-14ad5fb88548",
+		SubcategoryID: "1bd9364a-c5ed-4d13-b32c-3dbab9f64972",
+		CountryID:     "d922a911-2d03-4479-9170-4bd44e68b5f2",
+		StateID:       "bfd2d6b0-2ed3-4274-9950-a26db49f606a",
+		LgaID:         "bc82cf4e-7809-484f-aa43-8a988922bbfc",
+	}, nil
+}
+
+// The methods below have no fixtures yet; they return empty results so the test repository
+// satisfies Repository.
+
+func (u *PostgresTestRepository) GetAll(ctx context.Context) ([]*User, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryByIDOrSlug(ctx context.Context, slug_ulid, inventory_id string) (*Inventory, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetUserBySlug(ctx context.Context, slug string) (*User, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetUserWithSuppliedSlug(ctx context.Context, slug string) (*User, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryRatings(ctx context.Context, id string, page int32, limit int32) ([]*InventoryRating, int32, error) {
+	return nil, 0, nil
+}
+
+func (u *PostgresTestRepository) GetUserRatings(ctx context.Context, id string, page int32, limit int32) ([]*UserRating, int32, error) {
+	return nil, 0, nil
+}
+
+func (u *PostgresTestRepository) GetUserRatingSummary(ctx context.Context, userID string) (*RatingSummary, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryRatingSummary(ctx context.Context, inventoryID string) (*RatingSummary, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreateInventoryRatingReply(ctx context.Context, param *ReplyRatingPayload) (*InventoryRatingReply, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreateUserRatingReply(ctx context.Context, param *ReplyRatingPayload) (*UserRatingReply, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreateBooking(ctx context.Context, param *CreateBookingPayload) (*InventoryBooking, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreatePurchaseOrder(ctx context.Context, param *CreatePurchaseOrderPayload) (*InventorySale, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SubmitChat(ctx context.Context, param *Message) (*Chat, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetChatList(ctx context.Context, userID string) ([]ChatSummary, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetChatHistory(ctx context.Context, userA, userB string) ([]Chat, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetUnreadChat(ctx context.Context, userID string) (int32, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) MarkChatAsRead(ctx context.Context, userID, senderID string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetPremiumPartners(ctx context.Context, req SearchPremiumPartnerPayload) (*BusinessCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetPremiumUsersExtras(ctx context.Context) (PremiumExtrasPayload, error) {
+	return PremiumExtrasPayload{}, nil
+}
+
+func (u *PostgresTestRepository) UploadProfileImage(ctx context.Context, img, userId string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) UploadShopBanner(ctx context.Context, img, userId string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) UserRatingAndCount(ctx context.Context, userID string) (UserRatingAndCountReturn, error) {
+	return UserRatingAndCountReturn{}, nil
+}
+
+func (u *PostgresTestRepository) TotalUserInventoryListing(ctx context.Context, userID string) (TotalUserListingReturn, error) {
+	return TotalUserListingReturn{}, nil
+}
+
+func (u *PostgresTestRepository) SaveInventory(ctx context.Context, userId, inventoryId string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) DeleteSaveInventory(ctx context.Context, id, userId, inventoryId string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) DeleteInventory(ctx context.Context, detail DeleteInventoryPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) UpdateInventory(ctx context.Context, detail UpdateInventoryParams) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetInventoryImages(ctx context.Context, inventoryID string) ([]InventoryImage, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AddInventoryImages(ctx context.Context, inventoryID string, uploads []ImageURLs) ([]InventoryImage, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) DeleteInventoryImage(ctx context.Context, inventoryID, imageID string) (*InventoryImage, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ReorderInventoryImages(ctx context.Context, inventoryID string, imageIDs []string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetPrimaryInventoryImage(ctx context.Context, inventoryID, imageID string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetUnfinishedInventoryJobs(ctx context.Context) ([]InventoryJob, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryVariants(ctx context.Context, inventoryID string) ([]InventoryVariant, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryVariant(ctx context.Context, inventoryID, variantID string) (*InventoryVariant, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreateInventoryVariant(ctx context.Context, variant *InventoryVariant) (*InventoryVariant, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) UpdateInventoryVariant(ctx context.Context, p UpdateInventoryVariantParams) (*InventoryVariant, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) DeleteInventoryVariant(ctx context.Context, inventoryID, variantID string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) IsBusinessAccount(ctx context.Context, userID string) (bool, error) {
+	return false, nil
+}
+
+func (u *PostgresTestRepository) CreateImportBatch(ctx context.Context, batch *InventoryImportBatch) (*InventoryImportBatch, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetImportBatch(ctx context.Context, batchID string) (*InventoryImportBatch, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetImportBatchStatus(ctx context.Context, batchID, status string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetImportRowResult(ctx context.Context, rowID, status string, jobID, errMsg *string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetUnfinishedImportBatches(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ExportMyInventories(ctx context.Context, filter ExportFilter, fn func(InventoryExportRow) error) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) ExportBookings(ctx context.Context, filter ExportFilter, asOwner bool, fn func(BookingExportRow) error) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) ExportSales(ctx context.Context, filter ExportFilter, asSeller bool, fn func(SaleExportRow) error) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetDuplicateCandidates(ctx context.Context, userID, excludeID string) ([]DuplicateCandidate, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryImageHashes(ctx context.Context, inventoryID string) ([]int64, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) FlagInventoryDuplicates(ctx context.Context, inventoryID string, matches []DuplicateMatch) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetInventoryHistory(ctx context.Context, inventoryID string) ([]InventoryHistoryEntry, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryOwner(ctx context.Context, inventoryID string) (string, error) {
+	return "", nil
+}
+
+func (u *PostgresTestRepository) GetDeletedInventories(ctx context.Context, userID string, window time.Duration) ([]DeletedInventory, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) RestoreInventory(ctx context.Context, userID, inventoryID string, window time.Duration) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetPurgeableInventories(ctx context.Context, window time.Duration, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) PurgeInventory(ctx context.Context, inventoryID string) ([]InventoryImage, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetListingLifetimeDays(ctx context.Context, userID string) (*int32, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ScheduleInventory(ctx context.Context, userID, inventoryID string, publishAt, expiresAt *time.Time) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) RenewInventory(ctx context.Context, userID, inventoryID string, expiresAt *time.Time) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetInventorySchedule(ctx context.Context, inventoryID string) (publishAt, expiresAt *time.Time, err error) {
+	return nil, nil, nil
+}
+
+func (u *PostgresTestRepository) QueueExpiryReminders(ctx context.Context, within time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) BackfillListingExpiry(ctx context.Context, defaultDays int) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit int32) ([]Notification, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) MarkNotificationsRead(ctx context.Context, userID string, ids []string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetPostingQuota(ctx context.Context, userID string) (*PostingQuota, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetPromotionPrice(ctx context.Context, scope string, days int) (float64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) CreateInventoryPromotion(ctx context.Context, promotion *InventoryPromotion) (*InventoryPromotion, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryPromotions(ctx context.Context, inventoryID string) ([]InventoryPromotion, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) RecordPromotionImpressions(ctx context.Context, promotionIDs []string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) RecordPromotionClick(ctx context.Context, inventoryID, scope, visitorKey string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SaveSubcategoryAttribute(ctx context.Context, attribute *SubcategoryAttribute) (*SubcategoryAttribute, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) DeleteSubcategoryAttribute(ctx context.Context, subcategoryID, name string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetPopularTags(ctx context.Context, categoryID string, limit int) ([]TagCount, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetTagSynonym(ctx context.Context, synonym, canonical string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) ResolveSlugRedirect(ctx context.Context, kind, slug string) (string, error) {
+	return "", nil
+}
+
+func (u *PostgresTestRepository) PruneInventoryEvents(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) GetUserInventoryStats(ctx context.Context, userID string, from time.Time) ([]InventoryStatsSummary, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AskInventoryQuestion(ctx context.Context, question *InventoryQuestion) (*InventoryQuestion, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AnswerInventoryQuestion(ctx context.Context, ownerID, questionID, answer string) (*InventoryQuestion, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryQuestions(ctx context.Context, inventoryID string, all bool) ([]InventoryQuestion, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ReportInventoryQuestion(ctx context.Context, userID, questionID string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetInventoryQuestionHidden(ctx context.Context, ownerID, questionID string, hidden bool) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetBusinessLocation(ctx context.Context, userID string, point *GeoPoint) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetLgaCentroid(ctx context.Context, lgaID string, point *GeoPoint) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetSitemapPages(ctx context.Context, section string, size int) ([]SitemapPage, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) StreamSitemapEntries(ctx context.Context, section, from string, limit int, fn func(SitemapEntry) error) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) StreamProductFeed(ctx context.Context, fn func(ProductFeedItem) error) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) CreateInventoryUnit(ctx context.Context, unit *InventoryUnit) (*InventoryUnit, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) UpdateInventoryUnit(ctx context.Context, params UpdateInventoryUnitParams) (*InventoryUnit, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryUnits(ctx context.Context, inventoryID string) ([]InventoryUnit, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryUnitRentals(ctx context.Context, inventoryID, unitID string) ([]InventoryUnitRental, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ReturnBookingUnits(ctx context.Context, ownerID, bookingID string, returns []UnitReturn) ([]InventoryUnitRental, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CheckInventoryStock(ctx context.Context, inventoryID string) (*StockLevel, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetLowStockThreshold(ctx context.Context, userID, inventoryID string, threshold *float64) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetBundleComponents(ctx context.Context, bundleID string) ([]BundleComponent, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetBundleComponents(ctx context.Context, ownerID, bundleID string, components []BundleComponent) ([]BundleComponent, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetBundleAvailability(ctx context.Context, bundleID string, start, end time.Time) (*BundleAvailability, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) CreateBundleBooking(ctx context.Context, p *CreateBookingPayload) (*InventoryBooking, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) DeleteChat(ctx context.Context, id, userId string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetSavedInventoryByUserIDAndInventoryID(ctx context.Context, userId, inventoryId string) (*SavedInventory, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryWithSuppliedID(ctx context.Context, inventoryId string) (*Inventory, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetUserSavedInventory(ctx context.Context, userId string) ([]*SavedInventory, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetBusinessKycByUserID(ctx context.Context, userID string) (*BusinessKyc, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetRenterKycByUserID(ctx context.Context, userID string) (*RenterKyc, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryRatingReplies(ctx context.Context, ratingID string) ([]*InventoryRatingReply, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetUserRatingReplies(ctx context.Context, ratingID string) ([]*UserRatingReply, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetBusinessBySubdomain(ctx context.Context, domain string) (*BusinessKyc, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetMyBookings(ctx context.Context, detail MyBookingPayload) (*MyBookingCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetBookingRequest(ctx context.Context, detail MyBookingPayload) (*MyBookingCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetMyPurchases(ctx context.Context, detail MyPurchasePayload) (*MyPurchaseCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetPurchaseRequest(ctx context.Context, detail MyPurchasePayload) (*MyPurchaseCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetMyInventories(ctx context.Context, detail MyInventoryPayload) (*MyInventoryCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetMySubscriptionHistory(ctx context.Context, detail MySubscriptionHistoryPayload) (*MySubscriptionHistoryCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) ReportUserRating(ctx context.Context, detail RatingReportHelpfulPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) UserRatingHelpful(ctx context.Context, detail RatingReportHelpfulPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) ReportInventoryRating(ctx context.Context, detail RatingReportHelpfulPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) InventoryRatingHelpful(ctx context.Context, detail RatingReportHelpfulPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) MarkInventoryAvailability(ctx context.Context, detail MarkInventoryAvailabilityPayload) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetPendingBookingCount(ctx context.Context, userId string) (int32, int32, error) {
+	return 0, 0, nil
+}
+
+func (u *PostgresTestRepository) GetPendingPurchaseCount(ctx context.Context, userId string) (int32, int32, error) {
+	return 0, 0, nil
+}
+
+func (u *PostgresTestRepository) GetAdminGetInventoryPending(ctx context.Context, detail AdminPendingInventoryPayload) (*InventoryCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AdminApproveInventory(ctx context.Context, id string) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) AdminGetActiveSubscriptions(ctx context.Context, detail AdminGetActiveSubscriptionPayload) (*UserSubscriptionCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetAllUsers(ctx context.Context, detail AdminGetUsersPayload) (*UsersCollection, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AdminGetDashboardCard(ctx context.Context) (*DashboardCardPayload, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) AdminGetAmountMadeByDate(ctx context.Context, date string) (float64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) AdminGetUsersJoinedByDate(ctx context.Context, date string) (int32, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) AdminGetInventoryCreatedByDate(ctx context.Context, date string) (int32, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) GetUserRegistrationStats(ctx context.Context, req RegistrationStatsRequest) ([]RegistrationStatsResponse, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetInventoryCreationStats(ctx context.Context, req RegistrationStatsRequest) ([]RegistrationStatsResponse, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetSubscriptionAmountStats(ctx context.Context, req SubscriptionStatsRequest) ([]SubscriptionStatsResponse, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) GetBusinesses(ctx context.Context, detail AdminGetBusinessPayload) (*AdminGetBusinnessCollection, error) {
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cloudinary/cloudinary-go"
+	"github.com/cloudinary/cloudinary-go/api/uploader"
+)
+
+// CloudinaryStore keeps files on Cloudinary
+type CloudinaryStore struct {
+	cld *cloudinary.Cloudinary
+}
+
+func NewCloudinaryStore(cloudName, apiKey, apiSecret string) (*CloudinaryStore, error) {
+	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Cloudinary: %w", err)
+	}
+	return &CloudinaryStore{cld: cld}, nil
+}
+
+func (s *CloudinaryStore) Put(ctx context.Context, key, contentType string, body io.Reader) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	uploadResult, err := s.cld.Upload.Upload(ctx, body, uploader.UploadParams{
+		PublicID:     key,
+		ResourceType: resourceType(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	if uploadResult.Error.Message != "" {
+		return "", fmt.Errorf("cloudinary: %s", uploadResult.Error.Message)
+	}
+
+	return uploadResult.SecureURL, nil
+}
+
+// Delete removes an image asset; raw files and videos are not deleted by key alone
+func (s *CloudinaryStore) Delete(ctx context.Context, key string) error {
+	result, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: key})
+	if err != nil {
+		return err
+	}
+	if result.Error.Message != "" {
+		return fmt.Errorf("cloudinary: %s", result.Error.Message)
+	}
+	return nil
+}
+
+func (s *CloudinaryStore) URL(key string) string {
+	img, err := s.cld.Image(key)
+	if err != nil {
+		return ""
+	}
+	url, err := img.String()
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+var cloudinaryVersion = regexp.MustCompile(`^v[0-9]+/`)
+
+// Key strips the delivery prefix, version and extension from a Cloudinary URL
+// e.g. https://res.cloudinary.com/demo/image/upload/v1712/rentalsolution/inventories/1712.jpg
+func (s *CloudinaryStore) Key(url string) string {
+	idx := strings.Index(url, "/upload/")
+	if idx == -1 {
+		return ""
+	}
+
+	key := cloudinaryVersion.ReplaceAllString(url[idx+len("/upload/"):], "")
+	return strings.TrimSuffix(key, path.Ext(key))
+}
+
+// resourceType maps a MIME type to the Cloudinary resource type it is uploaded as
+func resourceType(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	default:
+		return "raw"
+	}
+}
@@ -0,0 +1,306 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxImagePixels caps the decoded size of an upload so a small file cannot expand into gigabytes of pixels
+const MaxImagePixels = 40_000_000
+
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+// ImageSize is one of the derivatives produced for every upload, bounded by MaxEdge on its longest side
+type ImageSize struct {
+	Name    string
+	MaxEdge int
+}
+
+var (
+	ThumbnailSize = ImageSize{Name: "thumbnail", MaxEdge: 200}
+	CardSize      = ImageSize{Name: "card", MaxEdge: 600}
+	FullSize      = ImageSize{Name: "full", MaxEdge: 1600}
+)
+
+// Derivative is a re-encoded copy of an upload. Re-encoding drops EXIF, GPS and any other metadata.
+type Derivative struct {
+	Size        ImageSize
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// ProcessedImage holds the derivatives of one upload
+type ProcessedImage struct {
+	SourceType string
+	Thumbnail  Derivative
+	Card       Derivative
+	Full       Derivative
+	Hash       uint64 // perceptual hash of the upright image, see PerceptualHash
+}
+
+// SniffImageType identifies an image by its magic bytes, ignoring whatever type the client claimed
+func SniffImageType(content []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg", nil
+	case bytes.HasPrefix(content, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", nil
+	case bytes.HasPrefix(content, []byte("GIF87a")), bytes.HasPrefix(content, []byte("GIF89a")):
+		return "image/gif", nil
+	case len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WEBP":
+		return "image/webp", nil
+	}
+	return "", ErrUnsupportedImage
+}
+
+// CheckImage sniffs an upload and checks its dimensions from the header without decoding the pixels
+func CheckImage(content []byte) (string, error) {
+	sourceType, err := SniffImageType(content)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxImagePixels {
+		return "", fmt.Errorf("image is %dx%d, the limit is %d pixels", cfg.Width, cfg.Height, MaxImagePixels)
+	}
+
+	return sourceType, nil
+}
+
+// ProcessImage validates an upload and renders its thumbnail, card and full derivatives.
+// JPEG orientation is applied before the metadata is dropped. Animated GIFs keep their first frame.
+func ProcessImage(content []byte) (*ProcessedImage, error) {
+	sourceType, err := CheckImage(content)
+	if err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	// the smaller sizes are rendered from the full one, which is already upright
+	full := fit(src, FullSize.MaxEdge)
+	if sourceType == "image/jpeg" {
+		full = orient(full, jpegOrientation(content))
+	}
+
+	processed := &ProcessedImage{SourceType: sourceType, Hash: PerceptualHash(fit(full, hashEdge))}
+	for _, d := range []struct {
+		size ImageSize
+		out  *Derivative
+	}{
+		{FullSize, &processed.Full},
+		{CardSize, &processed.Card},
+		{ThumbnailSize, &processed.Thumbnail},
+	} {
+		rendered := fit(full, d.size.MaxEdge)
+		contentType, data, err := encode(rendered)
+		if err != nil {
+			return nil, err
+		}
+		*d.out = Derivative{
+			Size:        d.size,
+			ContentType: contentType,
+			Data:        data,
+			Width:       rendered.Bounds().Dx(),
+			Height:      rendered.Bounds().Dy(),
+		}
+	}
+
+	return processed, nil
+}
+
+// hashEdge is the size images are shrunk to before hashing; the hash only needs a coarse picture
+const hashEdge = 64
+
+// HashImage returns the perceptual hash of an upload without rendering its derivatives. It gives
+// the same hash ProcessImage records for the image.
+func HashImage(content []byte) (uint64, error) {
+	sourceType, err := CheckImage(content)
+	if err != nil {
+		return 0, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return 0, fmt.Errorf("invalid image: %w", err)
+	}
+
+	small := fit(src, hashEdge)
+	if sourceType == "image/jpeg" {
+		small = orient(small, jpegOrientation(content))
+	}
+	return PerceptualHash(small), nil
+}
+
+// PerceptualHash is a 64 bit difference hash: the image is shrunk to 9x8 grey pixels and each bit
+// records whether a pixel is brighter than its right neighbour. Re-encoded, resized or lightly edited
+// copies of a photo hash within a few bits of each other, see HashDistance.
+func PerceptualHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance is the number of bits two perceptual hashes differ in
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// fit scales src down so its longest side is at most maxEdge. Images are never enlarged.
+func fit(src image.Image, maxEdge int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxEdge || h > maxEdge {
+		if w >= h {
+			w, h = maxEdge, max(1, h*maxEdge/b.Dx())
+		} else {
+			w, h = max(1, w*maxEdge/b.Dy()), maxEdge
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == b.Dx() && h == b.Dy() {
+		draw.Copy(dst, image.Point{}, src, b, draw.Src, nil)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	}
+	return dst
+}
+
+// encode writes opaque images as JPEG and anything with transparency as PNG
+func encode(img *image.RGBA) (string, []byte, error) {
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return "", nil, err
+		}
+		return "image/jpeg", buf.Bytes(), nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return "", nil, err
+	}
+	return "image/png", buf.Bytes(), nil
+}
+
+// orient applies an EXIF orientation (1-8) so the pixels are stored upright
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // flipped vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, or returns 1 when there is none
+func jpegOrientation(content []byte) int {
+	pos := 2 // skip SOI
+	for pos+4 <= len(content) {
+		if content[pos] != 0xFF {
+			return 1
+		}
+		marker := content[pos+1]
+		length := int(binary.BigEndian.Uint16(content[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(content) { // image data starts, nothing more to find
+			return 1
+		}
+
+		segment := content[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,169 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffImageType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+		err     error
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "image/jpeg", nil},
+		{"png", []byte("\x89PNG\r\n\x1a\n...."), "image/png", nil},
+		{"gif", []byte("GIF89a...."), "image/gif", nil},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp", nil},
+		{"pdf", []byte("%PDF-1.7"), "", ErrUnsupportedImage},
+	}
+
+	for _, tt := range tests {
+		got, err := SniffImageType(tt.content)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestProcessImage_Derivatives(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2000, 1000))
+	for y := 0; y < 1000; y++ {
+		for x := 0; x < 2000; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	processed, err := ProcessImage(encodePNG(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []Derivative{processed.Full, processed.Card, processed.Thumbnail} {
+		if d.Width != d.Size.MaxEdge || d.Height != d.Size.MaxEdge/2 {
+			t.Errorf("%s: got %dx%d", d.Size.Name, d.Width, d.Height)
+		}
+		// an opaque source is re-encoded as JPEG
+		if d.ContentType != "image/jpeg" {
+			t.Errorf("%s: got content type %s", d.Size.Name, d.ContentType)
+		}
+	}
+}
+
+func TestProcessImage_KeepsSmallImages(t *testing.T) {
+	processed, err := ProcessImage(encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 100, 50))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Full.Width != 100 || processed.Full.Height != 50 {
+		t.Errorf("expected no upscaling, got %dx%d", processed.Full.Width, processed.Full.Height)
+	}
+	// a transparent source stays PNG
+	if processed.Full.ContentType != "image/png" {
+		t.Errorf("got content type %s", processed.Full.ContentType)
+	}
+}
+
+func TestProcessImage_RejectsOversizedImages(t *testing.T) {
+	// a PNG header claiming 10000x10000 pixels
+	header := encodePNG(t, image.NewGray(image.Rect(0, 0, 1, 1)))
+	header[16], header[17], header[18], header[19] = 0, 0, 0x27, 0x10
+	header[20], header[21], header[22], header[23] = 0, 0, 0x27, 0x10
+
+	if _, err := ProcessImage(header); err == nil {
+		t.Error("expected oversized image to be rejected")
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 2x1 image, red on the left, blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	// rotated 90 clockwise the left pixel ends up on top
+	got := orient(src, 6)
+	if got.Bounds().Dx() != 1 || got.Bounds().Dy() != 2 {
+		t.Fatalf("got %v", got.Bounds())
+	}
+	if got.RGBAAt(0, 0) != red || got.RGBAAt(0, 1) != blue {
+		t.Errorf("unexpected pixels %v %v", got.RGBAAt(0, 0), got.RGBAAt(0, 1))
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	exif := []byte("Exif\x00\x00" +
+		"MM\x00\x2a\x00\x00\x00\x08" + // big endian TIFF header, IFD0 at offset 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00") // orientation = 6
+	content := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, byte(len(exif) + 2)}, exif...)
+	content = append(content, 0xFF, 0xDA, 0x00, 0x02)
+
+	if got := jpegOrientation(content); got != 6 {
+		t.Errorf("got orientation %d", got)
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	gradient := func(w, h int, invert bool) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := uint8(x * 255 / w)
+				if invert {
+					v = 255 - v
+				}
+				img.SetRGBA(x, y, color.RGBA{R: v, G: v, B: uint8(y * 255 / h), A: 255})
+			}
+		}
+		return img
+	}
+
+	original := PerceptualHash(gradient(800, 600, false))
+	resized := PerceptualHash(gradient(200, 150, false))
+	different := PerceptualHash(gradient(800, 600, true))
+
+	if d := HashDistance(original, resized); d > 4 {
+		t.Errorf("a resized copy is %d bits away", d)
+	}
+	if d := HashDistance(original, different); d < 32 {
+		t.Errorf("a different image is only %d bits away", d)
+	}
+}
+
+func TestHashImage_MatchesProcessImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	for y := 0; y < 900; y++ {
+		for x := 0; x < 1200; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x / 5), G: uint8(y / 4), B: 90, A: 255})
+		}
+	}
+	content := encodePNG(t, src)
+
+	processed, err := ProcessImage(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashImage(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := HashDistance(hash, processed.Hash); d > 2 {
+		t.Errorf("HashImage is %d bits away from ProcessImage", d)
+	}
+}
@@ -0,0 +1,121 @@
+package media
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore_PutServeDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := store.Put(context.Background(), "rentalsolution/inventories/1", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://localhost/media/rentalsolution/inventories/1" {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	key := store.Key(url)
+	if key != "rentalsolution/inventories/1" {
+		t.Errorf("unexpected key %s", key)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix(store.Path(), store))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/media/" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("expected stored file to be served, got %d %q", resp.StatusCode, body)
+	}
+
+	if err := store.Delete(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = http.Get(srv.URL + "/media/" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected deleted file to be gone, got %d", resp.StatusCode)
+	}
+}
+
+func TestLocalStore_DoesNotListDirectories(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(context.Background(), "rentalsolution/inventories/1", "text/plain", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix(store.Path(), store))
+	defer srv.Close()
+
+	for _, path := range []string{"/media/", "/media/rentalsolution/", "/media/rentalsolution/inventories"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected %s to be not found, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestLocalStore_RejectsTraversal(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Put(context.Background(), "../escape", "text/plain", strings.NewReader("x")); err == nil {
+		t.Error("expected a key containing .. to be rejected")
+	}
+}
+
+func TestCloudinaryStore_Key(t *testing.T) {
+	store := &CloudinaryStore{}
+	got := store.Key("https://res.cloudinary.com/demo/image/upload/v1712/rentalsolution/inventories/1712.jpg")
+	if got != "rentalsolution/inventories/1712" {
+		t.Errorf("unexpected key %s", got)
+	}
+}
+
+func TestMemoryStore_DeleteCleansKey(t *testing.T) {
+	store := NewMemoryStore()
+
+	if _, err := store.Put(context.Background(), "/rentalsolution/inventories/1/", "text/plain", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("/rentalsolution/inventories/1"); !ok {
+		t.Fatal("expected stored file under the cleaned key")
+	}
+
+	if err := store.Delete(context.Background(), "rentalsolution/inventories/1/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("rentalsolution/inventories/1"); ok {
+		t.Error("expected file to be deleted")
+	}
+
+	if err := store.Delete(context.Background(), "../1"); err == nil {
+		t.Error("expected traversal key to be rejected")
+	}
+}
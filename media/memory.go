@@ -0,0 +1,74 @@
+package media
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+const memoryURLPrefix = "memory://"
+
+// MemoryStore keeps files in memory. It is meant for tests and local runs without storage.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{files: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key, contentType string, body io.Reader) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.files[key] = content
+	s.mu.Unlock()
+
+	return s.URL(key), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.files, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) URL(key string) string {
+	return memoryURLPrefix + key
+}
+
+func (s *MemoryStore) Key(url string) string {
+	if !strings.HasPrefix(url, memoryURLPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(url, memoryURLPrefix)
+}
+
+// Get returns the stored content of key
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	content, ok := s.files[key]
+	return content, ok
+}
@@ -0,0 +1,62 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// MediaStore persists uploaded files. Keys are slash separated paths without an
+// extension, e.g. "rentalsolution/inventories/1712345678".
+type MediaStore interface {
+	// Put stores body under key and returns the URL it is served from
+	Put(ctx context.Context, key, contentType string, body io.Reader) (string, error)
+	// Delete removes the file stored under key
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of key
+	URL(key string) string
+	// Key recovers the key from a URL returned by Put, or "" when the URL belongs to another store
+	Key(url string) string
+}
+
+// NewFromEnv builds the store selected by MEDIA_BACKEND: cloudinary (the default), local or memory
+func NewFromEnv() (MediaStore, error) {
+	switch backend := strings.ToLower(os.Getenv("MEDIA_BACKEND")); backend {
+	case "", "cloudinary":
+		return NewCloudinaryStore(
+			os.Getenv("CLOUDINARY_CLOUD_NAME"),
+			os.Getenv("CLOUDINARY_API_KEY"),
+			os.Getenv("CLOUDINARY_API_SECRET"),
+		)
+	case "local":
+		root := os.Getenv("MEDIA_LOCAL_DIR")
+		if root == "" {
+			root = "./uploads"
+		}
+		baseURL := os.Getenv("MEDIA_BASE_URL")
+		if baseURL == "" {
+			baseURL = "/media"
+		}
+		return NewLocalStore(root, baseURL)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", backend)
+	}
+}
+
+// cleanKey rejects keys that could escape the store's namespace
+func cleanKey(key string) (string, error) {
+	key = strings.Trim(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("media key is empty")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid media key %q", key)
+		}
+	}
+	return key, nil
+}
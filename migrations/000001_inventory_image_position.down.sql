@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_inventory_images_inventory_position;
+ALTER TABLE inventory_images DROP COLUMN IF EXISTS position;
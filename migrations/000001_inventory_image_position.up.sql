@@ -0,0 +1,37 @@
+ALTER TABLE inventory_images ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+
+-- number the existing gallery images by upload order, leaving 0 free for the primary image
+UPDATE inventory_images ii
+SET position = ranked.rn
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY inventory_id ORDER BY created_at, id) AS rn
+    FROM inventory_images
+) ranked
+WHERE ii.id = ranked.id;
+
+-- the primary image used to be uploaded on its own; file it as the first gallery image
+INSERT INTO inventory_images (live_url, local_url, inventory_id, position, created_at, updated_at)
+SELECT i.primary_image, i.primary_image, i.id, 0, i.created_at, NOW()
+FROM inventories i
+WHERE i.primary_image IS NOT NULL
+  AND i.primary_image <> ''
+  AND NOT EXISTS (
+      SELECT 1 FROM inventory_images x
+      WHERE x.inventory_id = i.id AND x.live_url = i.primary_image
+  );
+
+-- compact to 0..n-1 with the primary image first
+UPDATE inventory_images ii
+SET position = ranked.rn - 1
+FROM (
+    SELECT im.id,
+           ROW_NUMBER() OVER (
+               PARTITION BY im.inventory_id
+               ORDER BY (im.live_url = inv.primary_image) DESC, im.position, im.created_at
+           ) AS rn
+    FROM inventory_images im
+    JOIN inventories inv ON inv.id = im.inventory_id
+) ranked
+WHERE ii.id = ranked.id;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_images_inventory_position ON inventory_images (inventory_id, position);
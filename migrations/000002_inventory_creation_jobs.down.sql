@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS inventory_creation_job_images;
+DROP TABLE IF EXISTS inventory_creation_jobs;
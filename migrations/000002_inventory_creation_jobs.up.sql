@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS inventory_creation_jobs (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status       VARCHAR(20) NOT NULL DEFAULT 'queued'
+                 CHECK (status IN ('queued', 'uploading', 'saving', 'succeeded', 'failed')),
+    request      JSONB NOT NULL,
+    error        TEXT,
+    inventory_id UUID REFERENCES inventories (id) ON DELETE SET NULL,
+    attempts     INTEGER NOT NULL DEFAULT 1,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_creation_jobs_user ON inventory_creation_jobs (user_id, created_at DESC);
+
+-- image bytes are kept until the job succeeds so a failed job can be retried without a re-upload
+CREATE TABLE IF NOT EXISTS inventory_creation_job_images (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id     UUID NOT NULL REFERENCES inventory_creation_jobs (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT false,
+    image_type VARCHAR(50) NOT NULL,
+    image_data BYTEA,
+    status     VARCHAR(20) NOT NULL DEFAULT 'pending'
+               CHECK (status IN ('pending', 'uploaded', 'failed')),
+    url        TEXT,
+    error      TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_creation_job_images_job ON inventory_creation_job_images (job_id, position);
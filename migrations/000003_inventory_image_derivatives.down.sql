@@ -0,0 +1,8 @@
+ALTER TABLE inventory_creation_job_images
+    DROP COLUMN IF EXISTS card_url,
+    DROP COLUMN IF EXISTS thumbnail_url;
+
+ALTER TABLE inventory_images
+    DROP COLUMN IF EXISTS full_url,
+    DROP COLUMN IF EXISTS card_url,
+    DROP COLUMN IF EXISTS thumbnail_url;
@@ -0,0 +1,14 @@
+-- every upload is stored as thumbnail, card and full derivatives; live_url keeps pointing at the full one
+ALTER TABLE inventory_images
+    ADD COLUMN IF NOT EXISTS thumbnail_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS card_url      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS full_url      TEXT NOT NULL DEFAULT '';
+
+-- images uploaded before processing existed only have the original
+UPDATE inventory_images
+SET thumbnail_url = live_url, card_url = live_url, full_url = live_url
+WHERE full_url = '';
+
+ALTER TABLE inventory_creation_job_images
+    ADD COLUMN IF NOT EXISTS thumbnail_url TEXT,
+    ADD COLUMN IF NOT EXISTS card_url      TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE inventory_sales DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE inventory_bookings DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS inventory_variants;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS inventory_variants (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id  UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    name          VARCHAR(255) NOT NULL,
+    attributes    JSONB NOT NULL DEFAULT '{}'::jsonb, -- e.g. {"size": "large", "colour": "green"}
+    quantity      NUMERIC NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    offer_price   NUMERIC NOT NULL CHECK (offer_price >= 0),
+    minimum_price NUMERIC NOT NULL DEFAULT 0 CHECK (minimum_price >= 0),
+    image_id      UUID REFERENCES inventory_images (id) ON DELETE SET NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (minimum_price <= offer_price)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_variants_inventory ON inventory_variants (inventory_id);
+
+ALTER TABLE inventory_bookings
+    ADD COLUMN IF NOT EXISTS variant_id UUID REFERENCES inventory_variants (id) ON DELETE SET NULL;
+
+ALTER TABLE inventory_sales
+    ADD COLUMN IF NOT EXISTS variant_id UUID REFERENCES inventory_variants (id) ON DELETE SET NULL;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS inventory_import_batches (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    filename   TEXT NOT NULL,
+    status     VARCHAR(20) NOT NULL DEFAULT 'queued'
+               CHECK (status IN ('queued', 'running', 'completed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_import_batches_user ON inventory_import_batches (user_id, created_at DESC);
+
+-- each imported row becomes an inventory creation job once its images have been fetched
+CREATE TABLE IF NOT EXISTS inventory_import_rows (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    batch_id   UUID NOT NULL REFERENCES inventory_import_batches (id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    name       TEXT NOT NULL,
+    request    JSONB NOT NULL,
+    image_urls TEXT[] NOT NULL,
+    status     VARCHAR(20) NOT NULL DEFAULT 'queued'
+               CHECK (status IN ('queued', 'submitted', 'failed')),
+    error      TEXT,
+    job_id     UUID REFERENCES inventory_creation_jobs (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_import_rows_batch ON inventory_import_rows (batch_id, row_number);
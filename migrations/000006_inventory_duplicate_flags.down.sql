@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inventory_duplicate_flags;
+ALTER TABLE inventory_creation_job_images DROP COLUMN IF EXISTS phash;
+ALTER TABLE inventory_images DROP COLUMN IF EXISTS phash;
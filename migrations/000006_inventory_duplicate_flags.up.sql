@@ -0,0 +1,18 @@
+-- perceptual hash of each image, compared against the seller's other listings
+ALTER TABLE inventory_images ADD COLUMN IF NOT EXISTS phash BIGINT;
+ALTER TABLE inventory_creation_job_images ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+-- a listing that looks like another live listing of the same seller, waiting for an admin
+CREATE TABLE IF NOT EXISTS inventory_duplicate_flags (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id    UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    duplicate_of    UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    text_similarity DOUBLE PRECISION NOT NULL,
+    image_distance  INTEGER,
+    status          VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cleared')),
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    reviewed_at     TIMESTAMP,
+    UNIQUE (inventory_id, duplicate_of)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_duplicate_flags_open ON inventory_duplicate_flags (inventory_id) WHERE status = 'open';
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS inventory_history_no_update ON inventory_history;
+DROP FUNCTION IF EXISTS inventory_history_append_only();
+DROP TABLE IF EXISTS inventory_history;
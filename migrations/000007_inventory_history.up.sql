@@ -0,0 +1,23 @@
+-- append-only record of every change to a listing. There is no foreign key so the history
+-- outlives the listing itself.
+CREATE TABLE IF NOT EXISTS inventory_history (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id UUID NOT NULL,
+    action       VARCHAR(50) NOT NULL,
+    changes      JSONB NOT NULL,
+    actor_id     UUID,
+    source       VARCHAR(20) NOT NULL CHECK (source IN ('http', 'grpc', 'admin', 'system')),
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_history_inventory ON inventory_history (inventory_id, created_at);
+
+CREATE OR REPLACE FUNCTION inventory_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'inventory_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_history_no_update
+    BEFORE UPDATE OR DELETE ON inventory_history
+    FOR EACH ROW EXECUTE FUNCTION inventory_history_append_only();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_inventories_pending_purge;
+ALTER TABLE inventories DROP COLUMN IF EXISTS purged_at;
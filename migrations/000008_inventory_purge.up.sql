@@ -0,0 +1,5 @@
+-- a purged listing that bookings, sales or ratings still point at is kept as a tombstone
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS purged_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_inventories_pending_purge ON inventories (deleted_at)
+    WHERE deleted = true AND purged_at IS NULL;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS inventory_expiry_backfill;
+DROP INDEX IF EXISTS idx_inventories_expires_at;
+ALTER TABLE plans DROP COLUMN IF EXISTS listing_lifetime_days;
+ALTER TABLE inventories
+    DROP COLUMN IF EXISTS expiry_reminded_at,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS publish_at;
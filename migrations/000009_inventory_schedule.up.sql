@@ -0,0 +1,33 @@
+-- a listing is live between publish_at and expires_at; either may be unset
+ALTER TABLE inventories
+    ADD COLUMN IF NOT EXISTS publish_at         TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS expires_at         TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS expiry_reminded_at TIMESTAMPTZ;
+
+-- NULL uses the service default, 0 means listings on the plan never expire
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS listing_lifetime_days INTEGER CHECK (listing_lifetime_days >= 0);
+
+-- existing listings are queued for an expiry date; the service sets it from its configured lifetime
+-- on the next startup, starting then, so nobody loses a listing without a reminder
+CREATE TABLE IF NOT EXISTS inventory_expiry_backfill (
+    inventory_id UUID PRIMARY KEY REFERENCES inventories (id) ON DELETE CASCADE
+);
+
+INSERT INTO inventory_expiry_backfill (inventory_id)
+SELECT id FROM inventories WHERE deleted = false AND expires_at IS NULL
+ON CONFLICT DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_inventories_expires_at ON inventories (expires_at)
+    WHERE deleted = false AND expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind         VARCHAR(50) NOT NULL,
+    inventory_id UUID REFERENCES inventories (id) ON DELETE CASCADE,
+    message      TEXT NOT NULL,
+    read_at      TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at DESC);
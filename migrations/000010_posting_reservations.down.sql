@@ -0,0 +1 @@
+ALTER TABLE inventory_creation_jobs DROP COLUMN IF EXISTS posting_subscription_id;
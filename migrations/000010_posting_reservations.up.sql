@@ -0,0 +1,6 @@
+-- the subscription a job took its posting slot from; cleared when the slot is refunded
+ALTER TABLE inventory_creation_jobs
+    ADD COLUMN IF NOT EXISTS posting_subscription_id UUID REFERENCES user_subscriptions (id) ON DELETE SET NULL;
+
+-- postings used to be decremented without a floor
+UPDATE user_subscriptions SET available_postings = 0 WHERE available_postings < 0;
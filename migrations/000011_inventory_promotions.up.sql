@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS inventory_promotions (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id      UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    user_id           UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scope             VARCHAR(20) NOT NULL CHECK (scope IN ('category', 'state', 'homepage')),
+    -- the category or state slug the listing had when the promotion was bought
+    scope_value       VARCHAR(255),
+    starts_at         TIMESTAMPTZ NOT NULL,
+    ends_at           TIMESTAMPTZ NOT NULL,
+    amount            NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    payment_reference VARCHAR(255) NOT NULL UNIQUE,
+    impressions       BIGINT NOT NULL DEFAULT 0,
+    clicks            BIGINT NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_promotions_inventory ON inventory_promotions (inventory_id, ends_at DESC);
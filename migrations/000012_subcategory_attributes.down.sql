@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS typed_attribute_value(JSONB, TEXT, TEXT[]);
+DROP INDEX IF EXISTS idx_inventories_attributes;
+ALTER TABLE inventories DROP COLUMN IF EXISTS attributes;
+DROP TABLE IF EXISTS subcategory_attributes;
@@ -0,0 +1,94 @@
+CREATE TABLE IF NOT EXISTS subcategory_attributes (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subcategory_id UUID NOT NULL REFERENCES subcategories (id) ON DELETE CASCADE,
+    name           VARCHAR(100) NOT NULL, -- the key in a listing's metadata, e.g. bedrooms
+    label          VARCHAR(255) NOT NULL,
+    type           VARCHAR(20) NOT NULL CHECK (type IN ('text', 'number', 'boolean', 'enum')),
+    unit           VARCHAR(50),
+    allowed_values TEXT[] NOT NULL DEFAULT '{}',
+    required       BOOLEAN NOT NULL DEFAULT false,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subcategory_id, name)
+);
+
+-- the validated attributes of a listing's metadata, typed so they can be searched
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_inventories_attributes ON inventories USING GIN (attributes);
+
+-- typed_attribute_value converts a metadata value to the type of its attribute the way the
+-- service's attributeValue does: numbers and booleans may be strings, yes/no are booleans and enum
+-- values take the casing of the allowed value. Without a type (attr_type NULL) the value is typed
+-- by its form. NULL is returned for empty values and values that do not fit the type.
+CREATE OR REPLACE FUNCTION typed_attribute_value(value JSONB, attr_type TEXT, allowed TEXT[]) RETURNS JSONB AS $$
+DECLARE
+    kind TEXT := jsonb_typeof(value);
+    t    TEXT := btrim(value #>> '{}');
+BEGIN
+    IF value IS NULL OR kind IN ('null', 'object', 'array') OR t = '' THEN
+        RETURN NULL;
+    END IF;
+
+    IF attr_type IS NULL OR attr_type = 'number' THEN
+        IF kind = 'number' THEN
+            RETURN value;
+        END IF;
+        IF kind = 'string' AND t ~ '^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$' THEN
+            RETURN to_jsonb(t::numeric);
+        END IF;
+        IF attr_type = 'number' THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    IF attr_type IS NULL OR attr_type = 'boolean' THEN
+        IF kind = 'boolean' THEN
+            RETURN value;
+        END IF;
+        IF kind = 'string' AND lower(t) IN ('true', 'yes') THEN
+            RETURN 'true'::jsonb;
+        END IF;
+        IF kind = 'string' AND lower(t) IN ('false', 'no') THEN
+            RETURN 'false'::jsonb;
+        END IF;
+        IF attr_type = 'boolean' THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    IF attr_type = 'enum' OR cardinality(allowed) > 0 THEN
+        RETURN (SELECT to_jsonb(a) FROM unnest(allowed) a WHERE lower(a) = lower(t) LIMIT 1);
+    END IF;
+    IF attr_type IS NULL THEN
+        RETURN CASE WHEN kind = 'string' THEN to_jsonb(t) ELSE value END;
+    END IF;
+    -- text; values typed by their form before the attribute was defined are turned back into text
+    RETURN to_jsonb(t);
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- existing metadata is copied over where it is a json object; anything else is skipped. Keys with
+-- an attribute definition are typed by it and dropped when they do not fit; the others by form.
+DO $$
+DECLARE
+    r RECORD;
+BEGIN
+    FOR r IN SELECT id, subcategory_id, metadata FROM inventories WHERE metadata IS NOT NULL AND metadata <> '' LOOP
+        BEGIN
+            IF jsonb_typeof(r.metadata::jsonb) <> 'object' THEN
+                CONTINUE;
+            END IF;
+
+            UPDATE inventories SET attributes = COALESCE((
+                SELECT jsonb_object_agg(e.key, typed_attribute_value(e.value, sa.type, sa.allowed_values))
+                FROM jsonb_each(r.metadata::jsonb) e
+                LEFT JOIN subcategory_attributes sa ON sa.subcategory_id = r.subcategory_id AND sa.name = e.key
+                WHERE typed_attribute_value(e.value, sa.type, sa.allowed_values) IS NOT NULL
+            ), '{}'::jsonb)
+            WHERE id = r.id;
+        EXCEPTION WHEN others THEN
+            NULL;
+        END;
+    END LOOP;
+END $$;
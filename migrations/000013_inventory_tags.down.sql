@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inventory_tags_tag;
+DROP TABLE IF EXISTS inventory_tags;
+DROP TABLE IF EXISTS tags;
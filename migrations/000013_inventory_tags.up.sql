@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name         VARCHAR(50) NOT NULL UNIQUE, -- lowercase, see utility.NormalizeTags
+    -- set when the tag is a synonym; listings are tagged with the canonical tag instead
+    canonical_id UUID REFERENCES tags (id) ON DELETE SET NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (canonical_id IS NULL OR canonical_id <> id)
+);
+
+CREATE TABLE IF NOT EXISTS inventory_tags (
+    inventory_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    tag_id       UUID NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    position     INT NOT NULL, -- the order the owner gave the tags in
+    PRIMARY KEY (inventory_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_tags_tag ON inventory_tags (tag_id);
+
+-- existing tags are normalised like utility.NormalizeTags: split on commas and whitespace,
+-- lowercased and trimmed of anything but letters and digits at either end. Tags longer than 50
+-- characters, which the service rejects, are dropped, and the first 10 of each listing are kept.
+CREATE TEMP TABLE existing_tags AS
+SELECT i.id AS inventory_id, t.name, MIN(t.position) AS position
+FROM inventories i
+CROSS JOIN LATERAL (
+    SELECT regexp_replace(lower(part), '^[^[:alpha:][:digit:]]+|[^[:alpha:][:digit:]]+$', '', 'g') AS name, position
+    FROM regexp_split_to_table(i.tags, '[,[:space:]]+') WITH ORDINALITY AS s(part, position)
+) t
+WHERE i.tags IS NOT NULL AND t.name <> '' AND char_length(t.name) <= 50
+GROUP BY i.id, t.name;
+
+INSERT INTO tags (name)
+SELECT DISTINCT name FROM existing_tags
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO inventory_tags (inventory_id, tag_id, position)
+SELECT e.inventory_id, t.id, e.rank
+FROM (
+    SELECT inventory_id, name, ROW_NUMBER() OVER (PARTITION BY inventory_id ORDER BY position) AS rank
+    FROM existing_tags
+) e
+JOIN tags t ON t.name = e.name
+WHERE e.rank <= 10;
+
+UPDATE inventories i
+SET tags = (
+    SELECT string_agg(t.name, ', ' ORDER BY it.position)
+    FROM inventory_tags it
+    JOIN tags t ON t.id = it.tag_id
+    WHERE it.inventory_id = i.id
+)
+WHERE i.tags IS NOT NULL;
+
+DROP TABLE existing_tags;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS business_kycs_slug_redirect ON business_kycs;
+DROP TRIGGER IF EXISTS inventories_slug_redirect ON inventories;
+DROP FUNCTION IF EXISTS record_slug_redirect();
+DROP INDEX IF EXISTS idx_slug_redirects_resource;
+DROP TABLE IF EXISTS slug_redirects;
@@ -0,0 +1,46 @@
+-- slugs and subdomains that used to point at a listing or business, so shared links to them
+-- can be redirected. There is no foreign key as resource_id is a listing or a business.
+CREATE TABLE IF NOT EXISTS slug_redirects (
+    kind        VARCHAR(20) NOT NULL CHECK (kind IN ('inventory', 'business')),
+    old_slug    VARCHAR(255) NOT NULL,
+    resource_id UUID NOT NULL, -- inventories.id or business_kycs.id
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, old_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_resource ON slug_redirects (kind, resource_id);
+
+-- recorded by triggers, as subdomains are changed outside this service
+CREATE OR REPLACE FUNCTION record_slug_redirect() RETURNS trigger AS $$
+DECLARE
+    previous_slug TEXT;
+    current_slug  TEXT;
+BEGIN
+    IF TG_ARGV[0] = 'business' THEN
+        previous_slug := OLD.subdomain;
+        current_slug := NEW.subdomain;
+    ELSE
+        previous_slug := OLD.slug;
+        current_slug := NEW.slug;
+    END IF;
+
+    IF previous_slug IS NOT NULL AND previous_slug <> '' THEN
+        INSERT INTO slug_redirects (kind, old_slug, resource_id) VALUES (TG_ARGV[0], previous_slug, OLD.id)
+        ON CONFLICT (kind, old_slug) DO UPDATE SET resource_id = EXCLUDED.resource_id, created_at = NOW();
+    END IF;
+
+    -- a slug that is in use again no longer redirects
+    DELETE FROM slug_redirects WHERE kind = TG_ARGV[0] AND old_slug = current_slug;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventories_slug_redirect
+    AFTER UPDATE OF slug ON inventories
+    FOR EACH ROW WHEN (OLD.slug IS DISTINCT FROM NEW.slug)
+    EXECUTE FUNCTION record_slug_redirect('inventory');
+
+CREATE TRIGGER business_kycs_slug_redirect
+    AFTER UPDATE OF subdomain ON business_kycs
+    FOR EACH ROW WHEN (OLD.subdomain IS DISTINCT FROM NEW.subdomain)
+    EXECUTE FUNCTION record_slug_redirect('business');
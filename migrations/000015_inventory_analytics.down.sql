@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inventory_daily_stats;
+DROP INDEX IF EXISTS idx_inventory_events_day;
+DROP TABLE IF EXISTS inventory_events;
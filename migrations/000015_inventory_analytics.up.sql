@@ -0,0 +1,23 @@
+-- one row per listing, event kind and visitor per day, so repeat events are counted once.
+-- Rows from earlier days are only kept until the next cleanup.
+CREATE TABLE IF NOT EXISTS inventory_events (
+    inventory_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    kind         VARCHAR(20) NOT NULL CHECK (kind IN ('view', 'impression', 'save', 'chat', 'booking')),
+    visitor_key  VARCHAR(64) NOT NULL, -- a hash of the visitor id
+    day          DATE NOT NULL,
+    PRIMARY KEY (inventory_id, kind, visitor_key, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_events_day ON inventory_events (day);
+
+-- the counted events of each listing per day
+CREATE TABLE IF NOT EXISTS inventory_daily_stats (
+    inventory_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    day          DATE NOT NULL,
+    views        INT NOT NULL DEFAULT 0,
+    impressions  INT NOT NULL DEFAULT 0,
+    saves        INT NOT NULL DEFAULT 0,
+    chats        INT NOT NULL DEFAULT 0,
+    bookings     INT NOT NULL DEFAULT 0, -- booking and purchase attempts
+    PRIMARY KEY (inventory_id, day)
+);
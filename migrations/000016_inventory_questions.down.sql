@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS track_reported_inventory_questions;
+DROP INDEX IF EXISTS idx_inventory_questions_inventory;
+DROP TABLE IF EXISTS inventory_questions;
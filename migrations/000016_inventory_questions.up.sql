@@ -0,0 +1,27 @@
+-- public questions on a listing; only the owner answers them
+CREATE TABLE IF NOT EXISTS inventory_questions (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    asker_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    question     TEXT NOT NULL,
+    answer       TEXT,
+    answered_at  TIMESTAMPTZ,
+    report_count INT NOT NULL DEFAULT 0,
+    -- hidden questions are only shown to the owner; set by the owner, an admin or enough reports
+    hidden       BOOLEAN NOT NULL DEFAULT false,
+    -- who hid the question: owner, or moderation for an admin or reports. Only the owner's own
+    -- hides can be undone by the owner.
+    hidden_by    VARCHAR(20) CHECK (hidden_by IN ('owner', 'moderation')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_questions_inventory ON inventory_questions (inventory_id, created_at DESC);
+
+-- one report per user per question, like track_reported_inventory_ratings
+CREATE TABLE IF NOT EXISTS track_reported_inventory_questions (
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    question_id UUID NOT NULL REFERENCES inventory_questions (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, question_id)
+);
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_lgas_earth;
+DROP INDEX IF EXISTS idx_business_kycs_earth;
+DROP INDEX IF EXISTS idx_inventories_earth;
+DROP FUNCTION IF EXISTS haversine_distance(DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION);
+
+ALTER TABLE lgas DROP CONSTRAINT IF EXISTS lgas_coordinates_check;
+ALTER TABLE business_kycs DROP CONSTRAINT IF EXISTS business_kycs_coordinates_check;
+ALTER TABLE inventories DROP CONSTRAINT IF EXISTS inventories_coordinates_check;
+
+ALTER TABLE lgas DROP COLUMN IF EXISTS latitude, DROP COLUMN IF EXISTS longitude;
+ALTER TABLE business_kycs DROP COLUMN IF EXISTS latitude, DROP COLUMN IF EXISTS longitude;
+ALTER TABLE inventories DROP COLUMN IF EXISTS latitude, DROP COLUMN IF EXISTS longitude;
@@ -0,0 +1,57 @@
+-- optional coordinates of listings and businesses. A listing without its own uses its owner's
+-- business location, then the centroid of its LGA.
+ALTER TABLE inventories
+    ADD COLUMN IF NOT EXISTS latitude  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE business_kycs
+    ADD COLUMN IF NOT EXISTS latitude  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE lgas
+    ADD COLUMN IF NOT EXISTS latitude  DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- both or neither, so a fallback never mixes the latitude of one place with the longitude of another
+ALTER TABLE inventories ADD CONSTRAINT inventories_coordinates_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude IS NOT NULL AND longitude IS NOT NULL
+        AND latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+ALTER TABLE business_kycs ADD CONSTRAINT business_kycs_coordinates_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude IS NOT NULL AND longitude IS NOT NULL
+        AND latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+ALTER TABLE lgas ADD CONSTRAINT lgas_coordinates_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude IS NOT NULL AND longitude IS NOT NULL
+        AND latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+-- great-circle distance in metres, used when the earthdistance extension is not available
+CREATE OR REPLACE FUNCTION haversine_distance(lat1 DOUBLE PRECISION, lon1 DOUBLE PRECISION,
+                                              lat2 DOUBLE PRECISION, lon2 DOUBLE PRECISION)
+RETURNS DOUBLE PRECISION
+LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE AS $$
+    SELECT 2 * 6371008.8 * asin(sqrt(
+        power(sin(radians(lat2 - lat1) / 2), 2)
+        + cos(radians(lat1)) * cos(radians(lat2)) * power(sin(radians(lon2 - lon1) / 2), 2)
+    ))
+$$;
+
+-- radius search is indexed with earthdistance where the database allows the extensions. Without
+-- them the service falls back to haversine_distance and these indexes are not created.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS cube;
+    CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+    CREATE INDEX IF NOT EXISTS idx_inventories_earth ON inventories USING gist (ll_to_earth(latitude, longitude))
+        WHERE latitude IS NOT NULL;
+    CREATE INDEX IF NOT EXISTS idx_business_kycs_earth ON business_kycs USING gist (ll_to_earth(latitude, longitude))
+        WHERE latitude IS NOT NULL;
+    CREATE INDEX IF NOT EXISTS idx_lgas_earth ON lgas USING gist (ll_to_earth(latitude, longitude))
+        WHERE latitude IS NOT NULL;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'earthdistance is not available, radius search uses haversine_distance: %', SQLERRM;
+END
+$$;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_inventory_unit_rentals_open;
+DROP INDEX IF EXISTS idx_inventory_unit_rentals_booking;
+DROP INDEX IF EXISTS idx_inventory_unit_rentals_unit;
+DROP TABLE IF EXISTS inventory_unit_rentals;
+
+DROP INDEX IF EXISTS idx_inventory_units_asset_tag;
+DROP INDEX IF EXISTS idx_inventory_units_serial;
+DROP INDEX IF EXISTS idx_inventory_units_inventory;
+DROP TABLE IF EXISTS inventory_units;
@@ -0,0 +1,38 @@
+-- the physical units of a listing, for owners who track which one goes to which renter. They are
+-- optional and do not change the listing's quantity.
+CREATE TABLE IF NOT EXISTS inventory_units (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inventory_id  UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    variant_id    UUID REFERENCES inventory_variants (id) ON DELETE SET NULL,
+    serial_number VARCHAR(100),
+    asset_tag     VARCHAR(100),
+    condition     VARCHAR(100) NOT NULL DEFAULT '',
+    status        VARCHAR(20) NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'out', 'maintenance', 'retired')),
+    notes         TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_units_inventory ON inventory_units (inventory_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_units_serial ON inventory_units (inventory_id, serial_number)
+    WHERE serial_number IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_units_asset_tag ON inventory_units (inventory_id, asset_tag)
+    WHERE asset_tag IS NOT NULL;
+
+-- each handover of a unit for a booking, open until the unit is returned
+CREATE TABLE IF NOT EXISTS inventory_unit_rentals (
+    id                 UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    unit_id            UUID NOT NULL REFERENCES inventory_units (id) ON DELETE CASCADE,
+    booking_id         UUID NOT NULL REFERENCES inventory_bookings (id) ON DELETE CASCADE,
+    condition_out      VARCHAR(100) NOT NULL DEFAULT '',
+    condition_returned VARCHAR(100),
+    notes              TEXT NOT NULL DEFAULT '',
+    handed_over_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    returned_at        TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_unit_rentals_unit ON inventory_unit_rentals (unit_id, handed_over_at DESC);
+CREATE INDEX IF NOT EXISTS idx_inventory_unit_rentals_booking ON inventory_unit_rentals (booking_id);
+-- a unit is out on at most one booking at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_unit_rentals_open ON inventory_unit_rentals (unit_id)
+    WHERE returned_at IS NULL;
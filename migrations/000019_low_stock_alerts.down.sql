@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_inventory_bookings_inventory_status;
+DROP INDEX IF EXISTS idx_inventory_sales_inventory_status;
+
+ALTER TABLE inventories
+    DROP COLUMN IF EXISTS sold_out_hidden,
+    DROP COLUMN IF EXISTS sold_out_at,
+    DROP COLUMN IF EXISTS low_stock_alerted_at,
+    DROP COLUMN IF EXISTS low_stock_threshold;
@@ -0,0 +1,13 @@
+-- owners are alerted when a listing's available stock drops below its threshold. low_stock_alerted_at
+-- keeps the alert to once until stock is back at or above the threshold.
+ALTER TABLE inventories
+    ADD COLUMN IF NOT EXISTS low_stock_threshold  NUMERIC CHECK (low_stock_threshold >= 0),
+    ADD COLUMN IF NOT EXISTS low_stock_alerted_at TIMESTAMP,
+    -- sold_out_at is set while a sale listing has nothing left, and sold_out_hidden when that is what
+    -- marked it unavailable, so it is made available again once stock is back
+    ADD COLUMN IF NOT EXISTS sold_out_at          TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS sold_out_hidden      BOOLEAN NOT NULL DEFAULT false;
+
+-- stock is counted against the open orders and bookings of a listing
+CREATE INDEX IF NOT EXISTS idx_inventory_sales_inventory_status ON inventory_sales (inventory_id, status);
+CREATE INDEX IF NOT EXISTS idx_inventory_bookings_inventory_status ON inventory_bookings (inventory_id, status, end_date);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_inventory_booking_components_inventory;
+DROP TABLE IF EXISTS inventory_booking_components;
+
+DROP INDEX IF EXISTS idx_inventory_bundle_components_component;
+DROP TABLE IF EXISTS inventory_bundle_components;
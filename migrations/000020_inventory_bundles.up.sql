@@ -0,0 +1,22 @@
+-- a bundle is a rental listing made up of other listings of the same owner, booked as one with its
+-- own price. It is searched like any other listing; its stock is that of its components.
+CREATE TABLE IF NOT EXISTS inventory_bundle_components (
+    bundle_id    UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    component_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    quantity     INT NOT NULL CHECK (quantity > 0),
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (bundle_id, component_id),
+    CHECK (bundle_id <> component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_bundle_components_component ON inventory_bundle_components (component_id);
+
+-- the component stock a bundle booking holds, as components may change after it is made
+CREATE TABLE IF NOT EXISTS inventory_booking_components (
+    booking_id   UUID NOT NULL REFERENCES inventory_bookings (id) ON DELETE CASCADE,
+    inventory_id UUID NOT NULL REFERENCES inventories (id) ON DELETE CASCADE,
+    quantity     INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (booking_id, inventory_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_booking_components_inventory ON inventory_booking_components (inventory_id);
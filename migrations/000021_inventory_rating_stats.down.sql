@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_inventories_offer_price;
+
+DROP TRIGGER IF EXISTS inventory_ratings_stats ON inventory_ratings;
+DROP FUNCTION IF EXISTS track_inventory_rating_stats();
+
+DROP INDEX IF EXISTS idx_inventory_rating_stats_count;
+DROP INDEX IF EXISTS idx_inventory_rating_stats_average;
+DROP TABLE IF EXISTS inventory_rating_stats;
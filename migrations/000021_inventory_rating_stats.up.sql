@@ -0,0 +1,50 @@
+-- the rating count and average of each listing, kept up to date by a trigger so search can sort
+-- by them without aggregating inventory_ratings for every query
+CREATE TABLE IF NOT EXISTS inventory_rating_stats (
+    inventory_id   UUID PRIMARY KEY REFERENCES inventories (id) ON DELETE CASCADE,
+    rating_count   INT NOT NULL DEFAULT 0,
+    rating_sum     BIGINT NOT NULL DEFAULT 0,
+    rating_average NUMERIC GENERATED ALWAYS AS (
+        CASE WHEN rating_count > 0 THEN rating_sum::NUMERIC / rating_count ELSE 0 END
+    ) STORED,
+    updated_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_rating_stats_average ON inventory_rating_stats (rating_average DESC, rating_count DESC);
+CREATE INDEX IF NOT EXISTS idx_inventory_rating_stats_count ON inventory_rating_stats (rating_count DESC, rating_average DESC);
+
+-- counts are changed in place rather than recomputed, so concurrent ratings of a listing queue on
+-- its stats row instead of missing each other
+CREATE OR REPLACE FUNCTION track_inventory_rating_stats() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE inventory_rating_stats
+        SET rating_count = rating_count - 1, rating_sum = rating_sum - OLD.rating, updated_at = NOW()
+        WHERE inventory_id = OLD.inventory_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO inventory_rating_stats (inventory_id, rating_count, rating_sum) VALUES (NEW.inventory_id, 1, NEW.rating)
+        ON CONFLICT (inventory_id) DO UPDATE
+        SET rating_count = inventory_rating_stats.rating_count + 1,
+            rating_sum = inventory_rating_stats.rating_sum + EXCLUDED.rating_sum,
+            updated_at = NOW();
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER inventory_ratings_stats
+    AFTER INSERT OR DELETE OR UPDATE OF rating, inventory_id ON inventory_ratings
+    FOR EACH ROW EXECUTE FUNCTION track_inventory_rating_stats();
+
+INSERT INTO inventory_rating_stats (inventory_id, rating_count, rating_sum)
+SELECT r.inventory_id, COUNT(*), SUM(r.rating)
+FROM inventory_ratings r
+JOIN inventories i ON i.id = r.inventory_id
+GROUP BY r.inventory_id
+ON CONFLICT (inventory_id) DO NOTHING;
+
+-- for sorting by price
+CREATE INDEX IF NOT EXISTS idx_inventories_offer_price ON inventories (offer_price, created_at DESC, id DESC);
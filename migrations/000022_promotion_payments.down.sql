@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_promotion_clicks_day;
+DROP TABLE IF EXISTS promotion_clicks;
+DROP TABLE IF EXISTS promotion_payments;
+DROP TABLE IF EXISTS promotion_rates;
@@ -0,0 +1,27 @@
+-- the price per day of each placement; a placement without a rate can not be bought
+CREATE TABLE IF NOT EXISTS promotion_rates (
+    scope      VARCHAR(20) PRIMARY KEY CHECK (scope IN ('category', 'state', 'homepage')),
+    daily_rate NUMERIC(12, 2) NOT NULL CHECK (daily_rate > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- payments for promotions, recorded by the payments service once the provider has confirmed them.
+-- A promotion is only created from a successful payment of its owner that covers its price.
+CREATE TABLE IF NOT EXISTS promotion_payments (
+    reference   VARCHAR(255) PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount      NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    status      VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'success', 'failed')),
+    verified_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- one click per visitor per promotion per day, like inventory_events
+CREATE TABLE IF NOT EXISTS promotion_clicks (
+    promotion_id UUID NOT NULL REFERENCES inventory_promotions (id) ON DELETE CASCADE,
+    visitor_key  VARCHAR(64) NOT NULL, -- a hash of the visitor id
+    day          DATE NOT NULL,
+    PRIMARY KEY (promotion_id, visitor_key, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_clicks_day ON promotion_clicks (day);
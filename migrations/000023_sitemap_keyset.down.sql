@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_inventories_created_id;
@@ -0,0 +1,2 @@
+-- sitemap files page through listings by (created_at, id) instead of an offset
+CREATE INDEX IF NOT EXISTS idx_inventories_created_id ON inventories (created_at, id);
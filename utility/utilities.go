@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/oklog/ulid/v2"
 )
@@ -14,6 +15,17 @@ import (
 // GenerateSlug creates a URL-friendly slug from the given name, removing special characters,
 // converting spaces to dashes, normalizing case, and appending a ULID
 func GenerateSlug(name string) (string, string) {
+	// Generate a ULID for uniqueness
+	now := time.Now().UTC()
+	entropy := ulid.Monotonic(rand.New(rand.NewSource(now.UnixNano())), 0)
+	id := ulid.MustNew(ulid.Timestamp(now), entropy)
+
+	return SlugWithULID(name, id.String()), id.String()
+}
+
+// SlugWithULID builds the slug for name around an existing ULID, so a renamed
+// listing keeps the identifier it was created with
+func SlugWithULID(name, id string) string {
 	// Lowercase and replace spaces with dashes
 	base := strings.ToLower(strings.ReplaceAll(name, " ", "-"))
 
@@ -21,13 +33,17 @@ func GenerateSlug(name string) (string, string) {
 	re := regexp.MustCompile(`[^a-z0-9-]+`)
 	base = re.ReplaceAllString(base, "")
 
-	// Generate a ULID for uniqueness
-	now := time.Now().UTC()
-	entropy := ulid.Monotonic(rand.New(rand.NewSource(now.UnixNano())), 0)
-	id := ulid.MustNew(ulid.Timestamp(now), entropy)
+	return fmt.Sprintf("%s-%s.html", base, id)
+}
 
-	slug := fmt.Sprintf("%s-%s.html", base, id.String())
-	return slug, id.String()
+// ULIDFromSlug returns the ULID at the end of a slug built by SlugWithULID, or "" when there is none
+func ULIDFromSlug(slug string) string {
+	base := strings.TrimSuffix(slug, ".html")
+	id := base[strings.LastIndex(base, "-")+1:]
+	if _, err := ulid.ParseStrict(id); err != nil {
+		return ""
+	}
+	return id
 }
 
 // TextToLower normalizes a description by converting it to lowercase
@@ -54,3 +70,64 @@ func ValidateBookingDates(startDate, endDate time.Time) error {
 
 	return nil
 }
+
+// NormalizeText lowercases text and reduces it to words separated by single spaces, so
+// punctuation, emoji and repeated whitespace do not affect comparisons
+func NormalizeText(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// NormalizeTags splits comma- or space-separated tags, lowercases them and trims any # or
+// punctuation around them. Empty and repeated tags are dropped; the rest keep their order.
+func NormalizeTags(tags string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(tags), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	seen := make(map[string]bool, len(fields))
+	normalized := []string{}
+	for _, field := range fields {
+		tag := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// TrigramSimilarity compares the three-character sequences of two normalized texts and returns
+// their Jaccard similarity, from 0 for nothing in common to 1 for the same text. Texts without any
+// trigrams, such as empty ones, are similar to nothing, as with pg_trgm.
+func TrigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(NormalizeText(a)), trigrams(NormalizeText(b))
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams pads each word the way PostgreSQL's pg_trgm does, so short words still contribute
+func trigrams(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+	}
+	return set
+}
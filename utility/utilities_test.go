@@ -0,0 +1,51 @@
+package utility
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags string
+		want []string
+	}{
+		{"empty", "", []string{}},
+		{"commas and spaces", "Sofa, leather  chair,,table", []string{"sofa", "leather", "chair", "table"}},
+		{"hashes and punctuation", "#Vintage! (retro) mid-century.", []string{"vintage", "retro", "mid-century"}},
+		{"repeats keep the first", "red RED #red blue", []string{"red", "blue"}},
+		{"only punctuation", "#, !!, --", []string{}},
+		{"letters of other scripts", "Café, ÉTÉ", []string{"café", "été"}},
+	}
+
+	for _, tt := range tests {
+		got := NormalizeTags(tt.tags)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestULIDFromSlug(t *testing.T) {
+	const id = "01J9ZK3X4V5W6Y7Z8A9B0C1D2E"
+
+	tests := []struct {
+		name string
+		slug string
+		want string
+	}{
+		{"slug from SlugWithULID", SlugWithULID("Red Leather Sofa", id), id},
+		{"without .html", "red-leather-sofa-" + id, id},
+		{"only the ulid", id, id},
+		{"no ulid", "red-leather-sofa.html", ""},
+		{"not a valid ulid", "red-sofa-01J9ZK3X4V5W6Y7Z8A9B0C1D2!.html", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ULIDFromSlug(tt.slug); got != tt.want {
+			t.Errorf("%s: got %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}